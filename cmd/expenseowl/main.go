@@ -13,6 +13,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/tanq16/expenseowl/internal/api"
 	"github.com/tanq16/expenseowl/internal/auth"
+	"github.com/tanq16/expenseowl/internal/blobstore"
 	"github.com/tanq16/expenseowl/internal/integrations/telegram"
 	"github.com/tanq16/expenseowl/internal/storage"
 	"github.com/tanq16/expenseowl/internal/user"
@@ -41,7 +42,12 @@ func runServer() {
 
 	telegramService := telegram.NewService(dbProvider.DB())
 
-	handler := api.NewHandler(store, userService, jwtManager, telegramService)
+	blobStore, err := blobstore.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+
+	handler := api.NewHandler(store, userService, jwtManager, telegramService, telegram.NewBotFromEnv(), blobStore)
 
 	mux := http.NewServeMux()
 
@@ -88,6 +94,8 @@ func runServer() {
 	mux.HandleFunc("/currency/edit", handler.RequireAPIAuth(handler.UpdateCurrency))
 	mux.HandleFunc("/startdate", handler.RequireAPIAuth(handler.GetStartDate))
 	mux.HandleFunc("/startdate/edit", handler.RequireAPIAuth(handler.UpdateStartDate))
+	mux.HandleFunc("/duplicatepolicy", handler.RequireAPIAuth(handler.GetDuplicatePolicy))
+	mux.HandleFunc("/duplicatepolicy/edit", handler.RequireAPIAuth(handler.UpdateDuplicatePolicy))
 
 	// Expenses
 	mux.HandleFunc("/expense", handler.RequireAPIAuth(handler.AddExpense))
@@ -96,16 +104,116 @@ func runServer() {
 	mux.HandleFunc("/expense/delete", handler.RequireAPIAuth(handler.DeleteExpense))
 	mux.HandleFunc("/expenses/delete", handler.RequireAPIAuth(handler.DeleteMultipleExpenses))
 
+	// Attachments
+	mux.HandleFunc("/expense/attachment", handler.RequireAPIAuth(handler.UploadAttachment))
+	mux.HandleFunc("/expense/attachments", handler.RequireAPIAuth(handler.GetAttachments))
+	mux.HandleFunc("/attachment", handler.RequireAPIAuth(handler.DownloadAttachment))
+	mux.HandleFunc("/attachment/delete", handler.RequireAPIAuth(handler.DeleteAttachment))
+
+	// Duplicates
+	mux.HandleFunc("/duplicates", handler.RequireAPIAuth(handler.GetDuplicates))
+	mux.HandleFunc("/duplicate/resolve", handler.RequireAPIAuth(handler.ResolveDuplicate))
+
+	// Categorization rules
+	mux.HandleFunc("/rules", handler.RequireAPIAuth(handler.GetCategorizationRules))
+	mux.HandleFunc("/rule", handler.RequireAPIAuth(handler.AddCategorizationRule))
+	mux.HandleFunc("/rule/edit", handler.RequireAPIAuth(handler.EditCategorizationRule))
+	mux.HandleFunc("/rule/delete", handler.RequireAPIAuth(handler.DeleteCategorizationRule))
+	mux.HandleFunc("/rules/reorder", handler.RequireAPIAuth(handler.ReorderCategorizationRules))
+	mux.HandleFunc("/rules/test", handler.RequireAPIAuth(handler.TestCategorizationRules))
+
+	// Payees
+	mux.HandleFunc("/payees", handler.RequireAPIAuth(handler.GetPayees))
+	mux.HandleFunc("/payee", handler.RequireAPIAuth(handler.AddPayee))
+	mux.HandleFunc("/payee/edit", handler.RequireAPIAuth(handler.EditPayee))
+	mux.HandleFunc("/payee/delete", handler.RequireAPIAuth(handler.DeletePayee))
+
 	// Recurring Expenses
 	mux.HandleFunc("/recurring-expense", handler.RequireAPIAuth(handler.AddRecurringExpense))
 	mux.HandleFunc("/recurring-expenses", handler.RequireAPIAuth(handler.GetRecurringExpenses))
 	mux.HandleFunc("/recurring-expense/edit", handler.RequireAPIAuth(handler.UpdateRecurringExpense))
 	mux.HandleFunc("/recurring-expense/delete", handler.RequireAPIAuth(handler.DeleteRecurringExpense))
+	mux.HandleFunc("/installments", handler.RequireAPIAuth(handler.GetInstallments))
+	mux.HandleFunc("/subscriptions/suggestions", handler.RequireAPIAuth(handler.GetSubscriptionSuggestions))
+	mux.HandleFunc("/subscriptions/accept", handler.RequireAPIAuth(handler.AcceptSubscription))
+
+	// Contacts and IOUs
+	mux.HandleFunc("/contacts", handler.RequireAPIAuth(handler.GetContacts))
+	mux.HandleFunc("/contacts/balances", handler.RequireAPIAuth(handler.GetContactBalances))
+	mux.HandleFunc("/contact", handler.RequireAPIAuth(handler.AddContact))
+	mux.HandleFunc("/contact/edit", handler.RequireAPIAuth(handler.EditContact))
+	mux.HandleFunc("/contact/delete", handler.RequireAPIAuth(handler.DeleteContact))
+	mux.HandleFunc("/debts", handler.RequireAPIAuth(handler.GetDebts))
+	mux.HandleFunc("/debts/overdue", handler.RequireAPIAuth(handler.GetOverdueDebts))
+	mux.HandleFunc("/debt", handler.RequireAPIAuth(handler.AddDebt))
+	mux.HandleFunc("/debt/edit", handler.RequireAPIAuth(handler.EditDebt))
+	mux.HandleFunc("/debt/delete", handler.RequireAPIAuth(handler.DeleteDebt))
+	mux.HandleFunc("/debt/repayment", handler.RequireAPIAuth(handler.AddDebtRepayment))
+	mux.HandleFunc("/debt/repayment/delete", handler.RequireAPIAuth(handler.DeleteDebtRepayment))
+
+	// Reports
+	mux.HandleFunc("/reports/categories", handler.RequireAPIAuth(handler.CategoryReport))
+	mux.HandleFunc("/reports/tags", handler.RequireAPIAuth(handler.TagReport))
+	mux.HandleFunc("/reports/payees", handler.RequireAPIAuth(handler.PayeeReport))
+	mux.HandleFunc("/reports/months", handler.RequireAPIAuth(handler.MonthReport))
+	mux.HandleFunc("/reports/periods", handler.RequireAPIAuth(handler.PeriodReport))
+	mux.HandleFunc("/reports/yoy", handler.RequireAPIAuth(handler.YearOverYearReport))
+	mux.HandleFunc("/reports/heatmap", handler.RequireAPIAuth(handler.HeatmapReport))
+	mux.HandleFunc("/reports/summary", handler.RequireAPIAuth(handler.SummaryReport))
+	mux.HandleFunc("/reports/tax", handler.RequireAPIAuth(handler.TaxReport))
+	mux.HandleFunc("/tax/classes", handler.RequireAPIAuth(handler.GetTaxClasses))
+	mux.HandleFunc("/forecast", handler.RequireAPIAuth(handler.Forecast))
+
+	// Anomalies
+	mux.HandleFunc("/anomalies", handler.RequireAPIAuth(handler.GetAnomalies))
+	mux.HandleFunc("/anomalies/scan", handler.RequireAPIAuth(handler.ScanAnomalies))
+	mux.HandleFunc("/anomaly/dismiss", handler.RequireAPIAuth(handler.DismissAnomaly))
+
+	// Refunds and reimbursements
+	mux.HandleFunc("/refunds", handler.RequireAPIAuth(handler.GetRefundLinks))
+	mux.HandleFunc("/refund", handler.RequireAPIAuth(handler.AddRefundLink))
+	mux.HandleFunc("/refund/delete", handler.RequireAPIAuth(handler.DeleteRefundLink))
+	mux.HandleFunc("/reimbursements/outstanding", handler.RequireAPIAuth(handler.GetOutstandingReimbursements))
+
+	// Statement reconciliation
+	mux.HandleFunc("/reconciliations", handler.RequireAPIAuth(handler.GetReconciliations))
+	mux.HandleFunc("/reconciliation", handler.RequireAPIAuth(handler.StartReconciliation))
+	mux.HandleFunc("/reconciliation/summary", handler.RequireAPIAuth(handler.GetReconciliationSummary))
+	mux.HandleFunc("/reconciliation/candidates", handler.RequireAPIAuth(handler.GetReconciliationCandidates))
+	mux.HandleFunc("/reconciliation/tick", handler.RequireAPIAuth(handler.TickReconciliationExpense))
+	mux.HandleFunc("/reconciliation/untick", handler.RequireAPIAuth(handler.UntickReconciliationExpense))
+	mux.HandleFunc("/reconciliation/complete", handler.RequireAPIAuth(handler.CompleteReconciliation))
+	mux.HandleFunc("/reconciliation/delete", handler.RequireAPIAuth(handler.DeleteReconciliation))
+
+	// Period close
+	mux.HandleFunc("/periods/closed", handler.RequireAPIAuth(handler.GetClosedPeriods))
+	mux.HandleFunc("/period/close", handler.RequireAPIAuth(handler.ClosePeriod))
+	mux.HandleFunc("/period/reopen", handler.RequireAPIAuth(handler.ReopenPeriod))
 
 	// Import/Export
 	mux.HandleFunc("/export/csv", handler.RequireAPIAuth(handler.ExportCSV))
+	mux.HandleFunc("/export/archive", handler.RequireAPIAuth(handler.ExportArchive))
+	mux.HandleFunc("/export/qif", handler.RequireAPIAuth(handler.ExportQIF))
+	mux.HandleFunc("/export/json", handler.RequireAPIAuth(handler.ExportJSON))
 	mux.HandleFunc("/import/csv", handler.RequireAPIAuth(handler.ImportCSV))
 	mux.HandleFunc("/import/csvold", handler.RequireAPIAuth(handler.ImportOldCSV))
+	mux.HandleFunc("/import/ofx", handler.RequireAPIAuth(handler.ImportOFX))
+	mux.HandleFunc("/import/camt053", handler.RequireAPIAuth(handler.ImportCAMT053))
+	mux.HandleFunc("/import/mt940", handler.RequireAPIAuth(handler.ImportMT940))
+	mux.HandleFunc("/import/qif", handler.RequireAPIAuth(handler.ImportQIF))
+	mux.HandleFunc("/import/json", handler.RequireAPIAuth(handler.ImportJSON))
+	mux.HandleFunc("/import/preview", handler.RequireAPIAuth(handler.PreviewImport))
+	mux.HandleFunc("/import/commit", handler.RequireAPIAuth(handler.CommitImport))
+	mux.HandleFunc("/import/job", handler.RequireAPIAuth(handler.GetImportJob))
+	mux.HandleFunc("/statements", handler.RequireAPIAuth(handler.GetStatements))
+	mux.HandleFunc("/importprofiles", handler.RequireAPIAuth(handler.GetImportProfiles))
+	mux.HandleFunc("/importprofile", handler.RequireAPIAuth(handler.AddImportProfile))
+	mux.HandleFunc("/importprofile/edit", handler.RequireAPIAuth(handler.EditImportProfile))
+	mux.HandleFunc("/importprofile/delete", handler.RequireAPIAuth(handler.DeleteImportProfile))
+	mux.HandleFunc("/importlocale", handler.RequireAPIAuth(handler.GetImportLocale))
+	mux.HandleFunc("/importlocale/edit", handler.RequireAPIAuth(handler.UpdateImportLocale))
+	mux.HandleFunc("/imports", handler.RequireAPIAuth(handler.GetImports))
+	mux.HandleFunc("/imports/{id}", handler.RequireAPIAuth(handler.UndoImport))
 
 	// Integrations
 	mux.HandleFunc("/api/v1/integrations/telegram/links", handler.RequireAPIAuth(handler.TelegramLinks))
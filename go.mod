@@ -8,10 +8,10 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/redis/go-redis/v9 v9.5.1
 	golang.org/x/crypto v0.28.0
+	gopkg.in/square/go-jose.v2 v2.6.0
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	gopkg.in/square/go-jose.v2 v2.6.0 // indirect
 )
@@ -0,0 +1,245 @@
+// Package anomaly flags unusual spending against the user's own history using
+// robust statistics (median and median absolute deviation).
+package anomaly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+const (
+	KindAmountOutlier = "amount_outlier"
+	KindCategorySpike = "category_spike"
+	KindDuplicate     = "duplicate"
+)
+
+// Tunables. A robust z-score above zThreshold and a ratio of at least
+// minRatio to the median are both required, so small absolute swings in
+// very regular categories are not reported.
+const (
+	zThreshold      = 3.5
+	minRatio        = 2.0
+	minHistory      = 5 // expenses per category before amounts are judged
+	minPeriods      = 3 // past periods before category totals are judged
+	lookbackPeriods = 12
+	duplicateWindow = 3 * 24 * time.Hour
+)
+
+// Finding is one flagged expense or period total.
+type Finding struct {
+	Kind        string     `json:"kind"`
+	ExpenseID   string     `json:"expenseId,omitempty"`
+	RelatedID   string     `json:"relatedId,omitempty"` // the earlier expense a duplicate resembles
+	Category    string     `json:"category"`
+	PeriodStart *time.Time `json:"periodStart,omitempty"`
+	Amount      float64    `json:"amount"`
+	Baseline    float64    `json:"baseline"` // median of the history it was compared with
+	Ratio       float64    `json:"ratio"`
+	Score       float64    `json:"score"` // robust z-score, 0 when the history has no spread
+	Message     string     `json:"message"`
+}
+
+// Fingerprint identifies a finding so repeated scans don't flag it twice.
+func (f Finding) Fingerprint() string {
+	key := f.Kind + "|" + f.ExpenseID
+	if f.PeriodStart != nil {
+		key += "|" + strings.ToLower(f.Category) + "|" + f.PeriodStart.Format("2006-01-02")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckExpense compares one expense with the user's other expenses: its
+// amount against the category's earlier amounts, and whether it repeats an
+// earlier charge from a few days before.
+func CheckExpense(expense storage.Expense, history []storage.Expense) []Finding {
+	if expense.Amount >= 0 || expense.RecurringID != "" {
+		return nil
+	}
+	var findings []Finding
+	var amounts []float64
+	duplicate := false
+	for _, past := range history {
+		if past.ID == expense.ID || past.Amount >= 0 {
+			continue
+		}
+		earlier := past.Date.Before(expense.Date) || (past.Date.Equal(expense.Date) && past.ID < expense.ID)
+		if !duplicate && expense.DuplicateOf == "" && earlier && past.Amount == expense.Amount && looksDuplicate(expense, past) {
+			findings = append(findings, duplicateFinding(expense, past))
+			duplicate = true
+		}
+		if past.Date.Before(expense.Date) && strings.EqualFold(past.Category, expense.Category) {
+			amounts = append(amounts, -past.Amount)
+		}
+	}
+	if len(amounts) < minHistory {
+		return findings
+	}
+	if score, median, ok := outlier(-expense.Amount, amounts); ok {
+		ratio := -expense.Amount / median
+		findings = append(findings, Finding{
+			Kind:      KindAmountOutlier,
+			ExpenseID: expense.ID,
+			Category:  expense.Category,
+			Amount:    -expense.Amount,
+			Baseline:  round2(median),
+			Ratio:     round2(ratio),
+			Score:     round2(score),
+			Message:   fmt.Sprintf("%s (%s) is %.1fx the usual %.2f", expense.Name, expense.Category, ratio, median),
+		})
+	}
+	return findings
+}
+
+// HistoryStart returns the start of the oldest budgeting period the checks
+// look at; expenses before it don't change their outcome much and needn't be
+// passed in.
+func HistoryStart(startDay int, now time.Time) time.Time {
+	start, _ := storage.PeriodBounds(now, startDay)
+	for i := 0; i < lookbackPeriods; i++ {
+		start, _ = storage.PeriodBounds(start.AddDate(0, 0, -1), startDay)
+	}
+	return start
+}
+
+// CheckPeriods compares the category totals of the budgeting period
+// containing now with the same category in previous periods.
+func CheckPeriods(expenses []storage.Expense, startDay int, now time.Time) []Finding {
+	start, end := storage.PeriodBounds(now, startDay)
+	starts := []time.Time{start}
+	for i := 0; i < lookbackPeriods; i++ {
+		prev, _ := storage.PeriodBounds(starts[len(starts)-1].AddDate(0, 0, -1), startDay)
+		starts = append(starts, prev)
+	}
+	oldest := starts[len(starts)-1]
+
+	// totals[category][period index], index 0 being the current period
+	totals := make(map[string][]float64)
+	names := make(map[string]string)
+	for _, expense := range expenses {
+		if expense.Date.Before(oldest) || !expense.Date.Before(end) {
+			continue
+		}
+		idx := 0
+		for expense.Date.Before(starts[idx]) {
+			idx++
+		}
+		for _, portion := range expense.Allocations() {
+			if portion.Amount >= 0 {
+				continue
+			}
+			key := strings.ToLower(portion.Category)
+			if totals[key] == nil {
+				totals[key] = make([]float64, len(starts))
+				names[key] = portion.Category
+			}
+			totals[key][idx] -= portion.Amount
+		}
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var findings []Finding
+	for _, key := range keys {
+		series := totals[key]
+		var history []float64
+		for _, v := range series[1:] {
+			if v > 0 {
+				history = append(history, v)
+			}
+		}
+		if len(history) < minPeriods || series[0] == 0 {
+			continue
+		}
+		score, median, ok := outlier(series[0], history)
+		if !ok {
+			continue
+		}
+		periodStart := start
+		ratio := series[0] / median
+		findings = append(findings, Finding{
+			Kind:        KindCategorySpike,
+			Category:    names[key],
+			PeriodStart: &periodStart,
+			Amount:      round2(series[0]),
+			Baseline:    round2(median),
+			Ratio:       round2(ratio),
+			Score:       round2(score),
+			Message:     fmt.Sprintf("%s spending this period is %.2f, %.1fx the usual %.2f", names[key], series[0], ratio, median),
+		})
+	}
+	return findings
+}
+
+// outlier reports whether value is unusually high for the sample, returning
+// the robust z-score and the sample median.
+func outlier(value float64, sample []float64) (float64, float64, bool) {
+	median := Median(sample)
+	if median <= 0 {
+		return 0, median, false
+	}
+	deviations := make([]float64, len(sample))
+	for i, v := range sample {
+		deviations[i] = math.Abs(v - median)
+	}
+	mad := Median(deviations)
+	ratio := value / median
+	if mad == 0 {
+		// perfectly regular history: any large jump is an outlier
+		return 0, median, ratio >= minRatio
+	}
+	score := 0.6745 * (value - median) / mad
+	return score, median, score > zThreshold && ratio >= minRatio
+}
+
+// Median returns the median of values without modifying them.
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func looksDuplicate(a, b storage.Expense) bool {
+	gap := a.Date.Sub(b.Date)
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > duplicateWindow || b.RecurringID != "" {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(a.Name), strings.TrimSpace(b.Name))
+}
+
+func duplicateFinding(expense, earlier storage.Expense) Finding {
+	return Finding{
+		Kind:      KindDuplicate,
+		ExpenseID: expense.ID,
+		RelatedID: earlier.ID,
+		Category:  expense.Category,
+		Amount:    -expense.Amount,
+		Baseline:  -earlier.Amount,
+		Ratio:     1,
+		Message:   fmt.Sprintf("%s (%.2f) looks like a duplicate of a charge on %s", expense.Name, -expense.Amount, earlier.Date.Format("2006-01-02")),
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/anomaly"
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// maxAlertLines caps a single alert so large imports stay readable.
+const maxAlertLines = 10
+
+// AnomalyResponse is a stored flag with its decrypted finding.
+type AnomalyResponse struct {
+	ID          string     `json:"id"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DismissedAt *time.Time `json:"dismissedAt,omitempty"`
+	anomaly.Finding
+}
+
+// GetAnomalies lists open anomaly flags, or all of them with ?all=true.
+func (h *Handler) GetAnomalies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	flags, err := h.storage.GetAnomalyFlags(userCtx.ID, r.URL.Query().Get("all") == "true")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve anomalies"})
+		log.Printf("API ERROR: Failed to retrieve anomalies: %v\n", err)
+		return
+	}
+	response := make([]AnomalyResponse, 0, len(flags))
+	for _, flag := range flags {
+		finding, err := decryptFinding(manager, flag.Blob)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		response = append(response, AnomalyResponse{ID: flag.ID, CreatedAt: flag.CreatedAt, DismissedAt: flag.DismissedAt, Finding: finding})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ScanAnomalies checks the expenses of the current period and the period's
+// category totals, and returns the newly flagged items.
+func (h *Handler) ScanAnomalies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	startDay, err := h.storage.GetStartDate(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve start date"})
+		log.Printf("API ERROR: Failed to retrieve start date: %v\n", err)
+		return
+	}
+	expenses, err := h.anomalyHistory(userCtx.ID, manager, startDay)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	from, to := storage.PeriodBounds(time.Now(), startDay)
+	var ids []string
+	for _, expense := range expenses {
+		if !expense.Date.Before(from) && expense.Date.Before(to) {
+			ids = append(ids, expense.ID)
+		}
+	}
+	created, err := h.detectAnomalies(userCtx.ID, manager, expenses, startDay, ids)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to scan for anomalies"})
+		log.Printf("API ERROR: Failed to scan for anomalies: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, created)
+}
+
+// DismissAnomaly hides a flag from the open list.
+func (h *Handler) DismissAnomaly(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.DismissAnomalyFlag(userCtx.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to dismiss anomaly"})
+		log.Printf("API ERROR: Failed to dismiss anomaly: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// checkNewExpenses runs anomaly detection for freshly added expenses in the
+// background so the request that added them isn't slowed down. There is
+// intentionally no periodic job: encrypted ledgers can only be analysed while
+// a request carries the key, so checks run when expenses are added and when
+// the user asks for a scan.
+func (h *Handler) checkNewExpenses(userID string, manager *encryption.Manager, ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+	go func() {
+		startDay, err := h.storage.GetStartDate(userID)
+		if err != nil {
+			log.Printf("Warning: Skipping anomaly check: %v\n", err)
+			return
+		}
+		expenses, err := h.anomalyHistory(userID, manager, startDay)
+		if err != nil {
+			log.Printf("Warning: Skipping anomaly check: %v\n", err)
+			return
+		}
+		if _, err := h.detectAnomalies(userID, manager, expenses, startDay, ids); err != nil {
+			log.Printf("Warning: Anomaly check failed: %v\n", err)
+		}
+	}()
+}
+
+// anomalyHistory returns the expenses within the anomaly checks' lookback
+// window. Dates live in the blobs, so each blob is still opened, but only the
+// window is kept and analysed.
+func (h *Handler) anomalyHistory(userID string, manager *encryption.Manager, startDay int) ([]storage.Expense, error) {
+	expenses, err := h.storage.GetAllExpenses(userID)
+	if err != nil {
+		return nil, err
+	}
+	since := anomaly.HistoryStart(startDay, time.Now())
+	recent := expenses[:0]
+	for _, expense := range expenses {
+		if err := decryptExpense(manager, &expense); err != nil {
+			return nil, err
+		}
+		if !expense.Date.Before(since) {
+			recent = append(recent, expense)
+		}
+	}
+	return recent, nil
+}
+
+// detectAnomalies checks the given expenses and the current period's totals,
+// stores findings that weren't flagged before and notifies the user of them.
+func (h *Handler) detectAnomalies(userID string, manager *encryption.Manager, expenses []storage.Expense, startDay int, ids []string) ([]AnomalyResponse, error) {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var findings []anomaly.Finding
+	for _, expense := range expenses {
+		if wanted[expense.ID] {
+			findings = append(findings, anomaly.CheckExpense(expense, expenses)...)
+		}
+	}
+	findings = append(findings, anomaly.CheckPeriods(expenses, startDay, time.Now())...)
+
+	created := []AnomalyResponse{}
+	for _, finding := range findings {
+		blob, err := encryptFinding(manager, finding)
+		if err != nil {
+			return nil, err
+		}
+		flag := storage.AnomalyFlag{
+			ID:          uuid.New().String(),
+			Kind:        finding.Kind,
+			ExpenseID:   finding.ExpenseID,
+			Fingerprint: finding.Fingerprint(),
+			Blob:        blob,
+			CreatedAt:   time.Now(),
+		}
+		isNew, err := h.storage.AddAnomalyFlag(userID, flag)
+		if err != nil {
+			return nil, err
+		}
+		if isNew {
+			created = append(created, AnomalyResponse{ID: flag.ID, CreatedAt: flag.CreatedAt, Finding: finding})
+		}
+	}
+	h.notifyAnomalies(userID, manager, created)
+	return created, nil
+}
+
+// notifyAnomalies sends new findings to the user's linked Telegram chats. For
+// an encrypted ledger the alert only says how many findings there are, so
+// nothing of the ledger leaves the server in plaintext.
+func (h *Handler) notifyAnomalies(userID string, manager *encryption.Manager, created []AnomalyResponse) {
+	if len(created) == 0 || h.bot == nil || h.telegram == nil {
+		return
+	}
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	chatIDs, err := h.telegram.ActiveChatIDs(ctx, uid)
+	if err != nil {
+		log.Printf("Warning: Failed to look up telegram chats: %v\n", err)
+		return
+	}
+	var text string
+	if manager != nil {
+		text = fmt.Sprintf("ExpenseOwl spotted %d unusual expenses, open the app to review them.", len(created))
+		if len(created) == 1 {
+			text = "ExpenseOwl spotted an unusual expense, open the app to review it."
+		}
+	} else {
+		lines := []string{"ExpenseOwl spotted unusual spending:"}
+		for i, item := range created {
+			if i == maxAlertLines {
+				lines = append(lines, fmt.Sprintf("...and %d more", len(created)-i))
+				break
+			}
+			lines = append(lines, "- "+item.Message)
+		}
+		text = strings.Join(lines, "\n")
+	}
+	for _, chatID := range chatIDs {
+		if err := h.bot.SendMessage(ctx, chatID, text); err != nil {
+			log.Printf("Warning: Failed to send anomaly alert: %v\n", err)
+		}
+	}
+}
+
+func encryptFinding(manager *encryption.Manager, finding anomaly.Finding) (string, error) {
+	if manager != nil {
+		blob, err := manager.Encrypt(finding)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt anomaly: %w", err)
+		}
+		return blob, nil
+	}
+	raw, err := json.Marshal(finding)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize anomaly: %w", err)
+	}
+	return string(raw), nil
+}
+
+func decryptFinding(manager *encryption.Manager, blob string) (anomaly.Finding, error) {
+	var finding anomaly.Finding
+	if err := json.Unmarshal([]byte(blob), &finding); err == nil {
+		return finding, nil
+	}
+	if manager == nil {
+		return finding, fmt.Errorf("encrypted anomaly provided without %s header", encryptionHeader)
+	}
+	if err := manager.Decrypt(blob, &finding); err != nil {
+		return finding, fmt.Errorf("failed to decrypt anomaly: %w", err)
+	}
+	return finding, nil
+}
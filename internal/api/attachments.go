@@ -0,0 +1,294 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+const maxAttachmentSize = 20 << 20 // 20MB per file
+
+// UploadAttachment stores a receipt or document for an expense.
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	if h.blobs == nil {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "attachment storage not configured"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	expenseID := r.URL.Query().Get("expenseId")
+	if expenseID == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expenseId parameter is required"})
+		return
+	}
+	if _, err := h.storage.GetExpense(userCtx.ID, expenseID); err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+(1<<20))
+	if err := r.ParseMultipartForm(maxAttachmentSize); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Could not parse multipart form (max 20MB)"})
+		return
+	}
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Error retrieving the file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read the file"})
+		return
+	}
+	if len(content) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "File is empty"})
+		return
+	}
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	if _, _, err := mime.ParseMediaType(contentType); err != nil || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(content)
+	}
+	attachment := storage.Attachment{
+		ID:          uuid.New().String(),
+		UserID:      userCtx.ID,
+		ExpenseID:   expenseID,
+		FileName:    storage.SanitizeFileName(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        int64(len(content)),
+	}
+	attachment.StorageKey = storage.AttachmentKey(userCtx.ID, attachment.ID)
+	payload := content
+	if manager != nil {
+		sealed, err := manager.EncryptBytes(content)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to encrypt attachment"})
+			log.Printf("API ERROR: Failed to encrypt attachment: %v\n", err)
+			return
+		}
+		payload = []byte(sealed)
+		attachment.Encrypted = true
+	}
+	if err := h.blobs.Put(r.Context(), attachment.StorageKey, payload, contentType); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to store attachment"})
+		log.Printf("API ERROR: Failed to store attachment: %v\n", err)
+		return
+	}
+	if err := h.storage.AddAttachment(userCtx.ID, attachment); err != nil {
+		if delErr := h.blobs.Delete(r.Context(), attachment.StorageKey); delErr != nil {
+			log.Printf("API ERROR: Failed to clean up attachment blob %s: %v\n", attachment.StorageKey, delErr)
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to save attachment"})
+		log.Printf("API ERROR: Failed to save attachment: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+// GetAttachments lists the attachments of an expense (or all, without expenseId).
+func (h *Handler) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	attachments, err := h.storage.GetAttachments(userCtx.ID, r.URL.Query().Get("expenseId"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get attachments"})
+		log.Printf("API ERROR: Failed to get attachments: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// DownloadAttachment streams attachment content back, decrypting it when needed.
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	if h.blobs == nil {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "attachment storage not configured"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	attachment, err := h.storage.GetAttachment(userCtx.ID, id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	content, err := h.readAttachment(r.Context(), attachment, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to read attachment %s: %v\n", attachment.ID, err)
+		return
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Write(content)
+}
+
+func (h *Handler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	if h.blobs == nil {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "attachment storage not configured"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	attachment, err := h.storage.GetAttachment(userCtx.ID, id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.RemoveAttachment(userCtx.ID, id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete attachment"})
+		log.Printf("API ERROR: Failed to delete attachment: %v\n", err)
+		return
+	}
+	if err := h.blobs.Delete(r.Context(), attachment.StorageKey); err != nil {
+		log.Printf("API ERROR: Failed to delete attachment blob %s: %v\n", attachment.StorageKey, err)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// readAttachment loads attachment content from the blob store and decrypts it
+// with the request's key when it was stored encrypted.
+func (h *Handler) readAttachment(ctx context.Context, attachment storage.Attachment, manager *encryption.Manager) ([]byte, error) {
+	if h.blobs == nil {
+		return nil, fmt.Errorf("attachment storage not configured")
+	}
+	content, err := h.blobs.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	if !attachment.Encrypted {
+		return content, nil
+	}
+	if manager == nil {
+		return nil, fmt.Errorf("encrypted attachment requested without %s header", encryptionHeader)
+	}
+	plain, err := manager.DecryptBytes(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt attachment: %w", err)
+	}
+	return plain, nil
+}
+
+// expenseAttachments collects attachment metadata of expenses that are about
+// to be removed, so their blobs can be cleaned up once the delete succeeded.
+func (h *Handler) expenseAttachments(userID string, expenseIDs ...string) []storage.Attachment {
+	if h.blobs == nil {
+		return nil
+	}
+	var attachments []storage.Attachment
+	for _, expenseID := range expenseIDs {
+		found, err := h.storage.GetAttachments(userID, expenseID)
+		if err != nil {
+			log.Printf("API ERROR: Failed to list attachments of expense %s: %v\n", expenseID, err)
+			continue
+		}
+		attachments = append(attachments, found...)
+	}
+	return attachments
+}
+
+// recurringAttachments collects attachment metadata of the expenses linked to
+// a recurring expense, which updating or removing the recurring expense may
+// delete and regenerate.
+func (h *Handler) recurringAttachments(userID, recurringID string) []storage.Attachment {
+	if h.blobs == nil {
+		return nil
+	}
+	ids, err := h.storage.GetRecurringExpenseIDs(userID, recurringID)
+	if err != nil {
+		log.Printf("API ERROR: Failed to list occurrences of recurring expense %s: %v\n", recurringID, err)
+		return nil
+	}
+	return h.expenseAttachments(userID, ids...)
+}
+
+// deleteRemovedRecurringBlobs removes the blobs of attachments whose expense
+// no longer exists after a recurring expense was updated or removed.
+func (h *Handler) deleteRemovedRecurringBlobs(ctx context.Context, userID, recurringID string, attachments []storage.Attachment) {
+	if len(attachments) == 0 {
+		return
+	}
+	ids, err := h.storage.GetRecurringExpenseIDs(userID, recurringID)
+	if err != nil {
+		log.Printf("API ERROR: Failed to list occurrences of recurring expense %s: %v\n", recurringID, err)
+		return
+	}
+	remaining := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	var removed []storage.Attachment
+	for _, attachment := range attachments {
+		if !remaining[attachment.ExpenseID] {
+			removed = append(removed, attachment)
+		}
+	}
+	h.deleteAttachmentBlobs(ctx, removed)
+}
+
+// deleteAttachmentBlobs removes blob content; the metadata rows follow their
+// expense via ON DELETE CASCADE.
+func (h *Handler) deleteAttachmentBlobs(ctx context.Context, attachments []storage.Attachment) {
+	for _, attachment := range attachments {
+		if err := h.blobs.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("API ERROR: Failed to delete attachment blob %s: %v\n", attachment.StorageKey, err)
+		}
+	}
+}
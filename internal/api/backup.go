@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// ExportJSON writes everything the user owns as a versioned JSON backup that
+// /import/json restores. With the encryption key expenses and recurring
+// expenses are written readable; without it encrypted blobs stay opaque.
+func (h *Handler) ExportJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	backup, err := h.storage.GetBackup(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to export backup"})
+		log.Printf("API ERROR: Failed to collect backup: %v\n", err)
+		return
+	}
+	if err := openBackup(&backup, manager); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=expenseowl-backup.json")
+	writeJSON(w, http.StatusOK, backup)
+	log.Printf("HTTP: Exported JSON backup with %d expenses", len(backup.Expenses))
+}
+
+// ImportJSON restores a backup written by /export/json from the "file" of a
+// multipart upload. The "mode" form value is restore (the default), which
+// needs an account without expenses and takes over the backup's settings, or
+// merge, which adds what the account doesn't have yet. Blobs the request's
+// key can't open are refused; without a key they are restored as they are.
+func (h *Handler) ImportJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	upload, err := receiveImportUpload(w, r)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer upload.release()
+
+	mode := strings.ToLower(strings.TrimSpace(upload.values.Get("mode")))
+	if mode == "" {
+		mode = "restore"
+	}
+	if mode != "restore" && mode != "merge" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "mode must be restore or merge"})
+		return
+	}
+	var backup storage.Backup
+	if err := json.NewDecoder(upload).Decode(&backup); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid backup file"})
+		return
+	}
+	if backup.Format != storage.BackupFormat {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "File is not an ExpenseOwl backup"})
+		return
+	}
+	if backup.Version < 1 || backup.Version > storage.BackupVersion {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Unsupported backup version %d", backup.Version)})
+		return
+	}
+	// With a key every blob is opened, so the restore stores it under that key.
+	if manager != nil {
+		if err := openBackup(&backup, manager); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	restored, err := h.storage.RestoreBackup(userCtx.ID, backup, mode == "merge", manager)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "already has expenses"):
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		case strings.Contains(err.Error(), "in backup"), strings.Contains(err.Error(), "in another account"):
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to restore backup, nothing was restored"})
+			log.Printf("API ERROR: Failed to restore backup: %v\n", err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "success",
+		"mode":    mode,
+		"added":   restored.Added,
+		"skipped": restored.Skipped,
+	})
+	log.Printf("HTTP: Restored JSON backup (%s), added %d expenses, skipped %d.", mode, restored.Added["expenses"], restored.Skipped["expenses"])
+}
+
+// openBackup replaces the blobs of a backup's expenses and recurring expenses
+// by their readable fields. Blobs that can't be read without a key are kept
+// and mark the backup as encrypted; with a key they are an error.
+func openBackup(backup *storage.Backup, manager *encryption.Manager) error {
+	backup.Encrypted = false
+	for i := range backup.Expenses {
+		expense := &backup.Expenses[i]
+		if expense.Blob == "" {
+			continue
+		}
+		stored := *expense
+		if err := decryptExpense(manager, expense); err != nil {
+			if manager != nil {
+				return fmt.Errorf("expense %s: %v", stored.ID, err)
+			}
+			backup.Encrypted = true
+			continue
+		}
+		// the columns are authoritative for what lives outside the blob
+		expense.ID, expense.UserID, expense.RecurringID = stored.ID, stored.UserID, stored.RecurringID
+		expense.ExternalRef, expense.ImportBatchID = stored.ExternalRef, stored.ImportBatchID
+		expense.Blob = ""
+	}
+	for i := range backup.RecurringExpenses {
+		recurring := &backup.RecurringExpenses[i]
+		if recurring.Blob == "" {
+			continue
+		}
+		stored := *recurring
+		if err := decryptRecurring(manager, recurring); err != nil {
+			if manager != nil {
+				return fmt.Errorf("recurring expense %s: %v", stored.ID, err)
+			}
+			backup.Encrypted = true
+			continue
+		}
+		recurring.ID, recurring.UserID = stored.ID, stored.UserID
+		recurring.Blob = ""
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// ------------------------------------------------------------
+// Contact Handlers
+// ------------------------------------------------------------
+
+func (h *Handler) GetContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	contacts, err := h.storage.GetContacts(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get contacts"})
+		log.Printf("API ERROR: Failed to get contacts: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, contacts)
+}
+
+func (h *Handler) AddContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var contact storage.Contact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := contact.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	created, err := h.storage.AddContact(userCtx.ID, contact)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to add contact"})
+		log.Printf("API ERROR: Failed to add contact: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) EditContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	var contact storage.Contact
+	if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := contact.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.UpdateContact(userCtx.ID, id, contact); err != nil {
+		writeDebtError(w, "Failed to edit contact", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveContact(userCtx.ID, id); err != nil {
+		writeDebtError(w, "Failed to delete contact", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// GetContactBalances returns the outstanding amount per contact.
+func (h *Handler) GetContactBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	contacts, err := h.storage.GetContacts(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get contacts"})
+		log.Printf("API ERROR: Failed to get contacts: %v\n", err)
+		return
+	}
+	debts, err := h.storage.GetDebts(userCtx.ID, "")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get debts"})
+		log.Printf("API ERROR: Failed to get debts: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, storage.ContactBalances(contacts, debts))
+}
+
+// ------------------------------------------------------------
+// Debt Handlers
+// ------------------------------------------------------------
+
+func (h *Handler) GetDebts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	debts, err := h.storage.GetDebts(userCtx.ID, r.URL.Query().Get("contactId"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get debts"})
+		log.Printf("API ERROR: Failed to get debts: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, debts)
+}
+
+// GetOverdueDebts returns the reminder list of open debts past their due date.
+func (h *Handler) GetOverdueDebts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	contacts, err := h.storage.GetContacts(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get contacts"})
+		log.Printf("API ERROR: Failed to get contacts: %v\n", err)
+		return
+	}
+	debts, err := h.storage.GetDebts(userCtx.ID, "")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get debts"})
+		log.Printf("API ERROR: Failed to get debts: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, storage.OverdueDebts(contacts, debts, time.Now()))
+}
+
+func (h *Handler) AddDebt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var debt storage.Debt
+	if err := json.NewDecoder(r.Body).Decode(&debt); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if debt.Date.IsZero() {
+		debt.Date = time.Now()
+	}
+	if err := debt.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if h.rejectUnknownExpense(w, userCtx.ID, debt.ExpenseID) {
+		return
+	}
+	created, err := h.storage.AddDebt(userCtx.ID, debt)
+	if err != nil {
+		writeDebtError(w, "Failed to add debt", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) EditDebt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	var debt storage.Debt
+	if err := json.NewDecoder(r.Body).Decode(&debt); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := debt.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if h.rejectUnknownExpense(w, userCtx.ID, debt.ExpenseID) {
+		return
+	}
+	if err := h.storage.UpdateDebt(userCtx.ID, id, debt); err != nil {
+		writeDebtError(w, "Failed to edit debt", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) DeleteDebt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveDebt(userCtx.ID, id); err != nil {
+		writeDebtError(w, "Failed to delete debt", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// AddDebtRepayment records a partial or full repayment against a debt.
+func (h *Handler) AddDebtRepayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	debtID := r.URL.Query().Get("debtId")
+	if debtID == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "debtId parameter is required"})
+		return
+	}
+	var repayment storage.DebtRepayment
+	if err := json.NewDecoder(r.Body).Decode(&repayment); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if repayment.Date.IsZero() {
+		repayment.Date = time.Now()
+	}
+	if err := repayment.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if h.rejectUnknownExpense(w, userCtx.ID, repayment.ExpenseID) {
+		return
+	}
+	created, err := h.storage.AddDebtRepayment(userCtx.ID, debtID, repayment)
+	if err != nil {
+		writeDebtError(w, "Failed to add debt repayment", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) DeleteDebtRepayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveDebtRepayment(userCtx.ID, id); err != nil {
+		writeDebtError(w, "Failed to delete debt repayment", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// rejectUnknownExpense answers 400 when a debt or repayment links an expense
+// the user doesn't own. It reports whether the request was rejected.
+func (h *Handler) rejectUnknownExpense(w http.ResponseWriter, userID, expenseID string) bool {
+	if expenseID == "" {
+		return false
+	}
+	if _, err := h.storage.GetExpense(userID, expenseID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return true
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to check linked expense"})
+		log.Printf("API ERROR: Failed to check linked expense: %v\n", err)
+		return true
+	}
+	return false
+}
+
+// writeDebtError answers 404 for unknown contacts, debts and repayments and
+// 400 for repayments above the outstanding amount or debts lowered below
+// their repayments; anything else is logged.
+func writeDebtError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+	case strings.Contains(err.Error(), "exceeds outstanding"), strings.Contains(err.Error(), "already repaid"):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: message})
+		log.Printf("API ERROR: %s: %v\n", message, err)
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// FlaggedDuplicate pairs an expense flagged for review with the one it
+// resembles. Original is nil when that expense has been deleted since.
+type FlaggedDuplicate struct {
+	Expense  storage.Expense  `json:"expense"`
+	Original *storage.Expense `json:"original,omitempty"`
+}
+
+func (h *Handler) GetDuplicatePolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	policy, err := h.storage.GetDuplicatePolicy(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get duplicate policy"})
+		log.Printf("API ERROR: Failed to get duplicate policy: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, policy)
+}
+
+func (h *Handler) UpdateDuplicatePolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	policy := storage.DefaultDuplicatePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.UpdateDuplicatePolicy(userCtx.ID, policy); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update duplicate policy"})
+		log.Printf("API ERROR: Failed to update duplicate policy: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// GetDuplicates lists expenses flagged as possible duplicates.
+func (h *Handler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	_, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	byID := make(map[string]storage.Expense, len(expenses))
+	for _, expense := range expenses {
+		byID[expense.ID] = expense
+	}
+	flagged := []FlaggedDuplicate{}
+	for _, expense := range expenses {
+		if expense.DuplicateOf == "" {
+			continue
+		}
+		item := FlaggedDuplicate{Expense: expense}
+		if original, ok := byID[expense.DuplicateOf]; ok {
+			item.Original = &original
+		}
+		flagged = append(flagged, item)
+	}
+	writeJSON(w, http.StatusOK, flagged)
+}
+
+// ResolveDuplicate settles a flagged expense: action=keep clears the flag,
+// action=remove deletes the expense.
+func (h *Handler) ResolveDuplicate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	expense, err := h.loadExpense(userCtx.ID, id, manager)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if expense.DuplicateOf == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expense is not flagged as a duplicate"})
+		return
+	}
+	switch r.URL.Query().Get("action") {
+	case "keep":
+		expense.DuplicateOf = ""
+		if err := h.saveExpense(userCtx.ID, expense, manager); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update expense"})
+			log.Printf("API ERROR: Failed to clear duplicate flag: %v\n", err)
+			return
+		}
+	case "remove":
+		if h.rejectLockedExpenses(w, userCtx.ID, id) || h.rejectClosedExpenses(w, userCtx.ID, manager, id) {
+			return
+		}
+		attachments := h.expenseAttachments(userCtx.ID, id)
+		if err := h.storage.RemoveExpense(userCtx.ID, id); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete expense"})
+			log.Printf("API ERROR: Failed to delete duplicate expense: %v\n", err)
+			return
+		}
+		h.deleteAttachmentBlobs(r.Context(), attachments)
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "action must be 'keep' or 'remove'"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// duplicateIndex loads the user's policy and, unless duplicates are allowed,
+// an index over their stored expenses. A nil index means no screening.
+func (h *Handler) duplicateIndex(userID string, manager *encryption.Manager) (*storage.DuplicateIndex, storage.DuplicatePolicy, error) {
+	policy, err := h.storage.GetDuplicatePolicy(userID)
+	if err != nil {
+		return nil, policy, err
+	}
+	if policy.Action == storage.DuplicateActionAllow {
+		return nil, policy, nil
+	}
+	expenses, err := h.decryptedExpenses(userID, manager)
+	if err != nil {
+		return nil, policy, err
+	}
+	return storage.NewDuplicateIndex(expenses, policy), policy, nil
+}
+
+// screenDuplicate applies the duplicate policy to a single incoming expense.
+// It flags the expense or writes a 409 and returns true when it must be
+// skipped. ?allowDuplicate=true bypasses the check, e.g. after the user
+// confirmed a genuine second charge. Lookup failures don't block the expense.
+// When the ledger can't be read, e.g. a Telegram message for an encrypted
+// ledger, only the external reference is compared and the response carries a
+// Warning header saying the full check was skipped.
+func (h *Handler) screenDuplicate(w http.ResponseWriter, r *http.Request, userID string, manager *encryption.Manager, expense *storage.Expense) bool {
+	if r.URL.Query().Get("allowDuplicate") == "true" {
+		return false
+	}
+	idx, policy, err := h.duplicateIndex(userID, manager)
+	if err != nil {
+		log.Printf("Warning: Skipping duplicate check: %v\n", err)
+		w.Header().Set("Warning", `199 - "duplicate check skipped, the ledger could not be read"`)
+		return h.screenExternalRef(w, userID, policy, expense)
+	}
+	if idx == nil {
+		return false
+	}
+	match, found := idx.Find(*expense)
+	if !found {
+		return false
+	}
+	return applyDuplicatePolicy(w, policy, expense, match.ID)
+}
+
+// screenExternalRef is the duplicate check that works without reading the
+// ledger: an expense whose external reference is already stored repeats it.
+func (h *Handler) screenExternalRef(w http.ResponseWriter, userID string, policy storage.DuplicatePolicy, expense *storage.Expense) bool {
+	if expense.ExternalRef == "" || policy.Action == storage.DuplicateActionAllow {
+		return false
+	}
+	refs, err := h.storage.GetExternalRefs(userID)
+	if err != nil {
+		log.Printf("Warning: Skipping external reference check: %v\n", err)
+		return false
+	}
+	matchID, found := refs[expense.ExternalRef]
+	if !found {
+		return false
+	}
+	return applyDuplicatePolicy(w, policy, expense, matchID)
+}
+
+func applyDuplicatePolicy(w http.ResponseWriter, policy storage.DuplicatePolicy, expense *storage.Expense, matchID string) bool {
+	if policy.Action == storage.DuplicateActionSkip {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("expense looks like a duplicate of %s", matchID)})
+		return true
+	}
+	expense.DuplicateOf = matchID
+	return false
+}
@@ -164,3 +164,40 @@ func ensureRecurringBlob(manager *encryption.Manager, recurring *storage.Recurri
     recurring.Blob = string(raw)
     return nil
 }
+
+// decryptedExpenses loads every expense of the user with readable fields for
+// server-side processing. Without a key only plaintext blobs can be read.
+func (h *Handler) decryptedExpenses(userID string, manager *encryption.Manager) ([]storage.Expense, error) {
+    expenses, err := h.storage.GetAllExpenses(userID)
+    if err != nil {
+        return nil, err
+    }
+    for i := range expenses {
+        if err := decryptExpense(manager, &expenses[i]); err != nil {
+            return nil, err
+        }
+    }
+    return expenses, nil
+}
+
+// loadExpense fetches a single expense and decrypts it with the request's key.
+func (h *Handler) loadExpense(userID, id string, manager *encryption.Manager) (storage.Expense, error) {
+	expense, err := h.storage.GetExpense(userID, id)
+	if err != nil {
+		return storage.Expense{}, err
+	}
+	if err := decryptExpense(manager, &expense); err != nil {
+		return storage.Expense{}, err
+	}
+	return expense, nil
+}
+
+// saveExpense re-serialises a decrypted expense, encrypting it again when a
+// key is present, and stores it.
+func (h *Handler) saveExpense(userID string, expense storage.Expense, manager *encryption.Manager) error {
+	expense.Blob = ""
+	if err := ensureExpenseBlob(manager, &expense); err != nil {
+		return err
+	}
+	return h.storage.UpdateExpense(userID, expense.ID, expense)
+}
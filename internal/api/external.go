@@ -45,6 +45,11 @@ func (h *Handler) CreateExpenseHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid user identifier"})
 		return
 	}
+	if expense.Source == "" {
+		expense.Source = storage.ExpenseSourceAPI
+	}
+	h.assignPayee(userID, &expense)
+	h.applyCategorizationRules(userID, &expense)
 
 	if err := expense.Validate(); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
@@ -54,8 +59,15 @@ func (h *Handler) CreateExpenseHandler(w http.ResponseWriter, r *http.Request) {
 	if expense.Date.IsZero() {
 		expense.Date = time.Now()
 	}
+	if h.rejectClosedDates(w, userID, expense.Date) || h.screenDuplicate(w, r, userID, manager, &expense) {
+		return
+	}
 
 	expense.UserID = userID
+	if expense.ID == "" {
+		expense.ID = uuid.New().String()
+	}
+	expense.Blob = "" // rebuilt below so it carries the server-side changes
 	if err := ensureExpenseBlob(manager, &expense); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
@@ -65,6 +77,7 @@ func (h *Handler) CreateExpenseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.checkNewExpenses(userID, manager, expense.ID)
 	writeJSON(w, http.StatusCreated, expense)
 }
 
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/reports"
+)
+
+const maxForecastMonths = 36
+
+// Forecast projects the running balance for the next months from recurring
+// rules and the trailing average of other spending. What-if toggles:
+// cancel=<recurringId,...> drops recurring rules and skipCategory=<name,...>
+// drops a category's average spend.
+func (h *Handler) Forecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	months, err := intParam(r, "months", 6, 1, maxForecastMonths)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	window, err := intParam(r, "window", 3, 1, 24)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	startDay, err := h.storage.GetStartDate(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get start date"})
+		log.Printf("API ERROR: Failed to get start date: %v\n", err)
+		return
+	}
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to load expenses for forecast: %v\n", err)
+		return
+	}
+	rules, err := h.storage.GetRecurringExpenses(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get recurring expenses"})
+		log.Printf("API ERROR: Failed to get recurring expenses: %v\n", err)
+		return
+	}
+	for i := range rules {
+		if err := decryptRecurring(manager, &rules[i]); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	opts := reports.ForecastOptions{
+		Now:            time.Now(),
+		StartDay:       startDay,
+		Periods:        months,
+		Window:         window,
+		Cancel:         listParam(r, "cancel", false),
+		SkipCategories: listParam(r, "skipCategory", true),
+	}
+	writeJSON(w, http.StatusOK, reports.BuildForecast(expenses, rules, opts))
+}
+
+// intParam reads an optional integer query parameter within [min, max].
+func intParam(r *http.Request, name string, def, min, max int) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(name))
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("'%s' must be a number between %d and %d", name, min, max)
+	}
+	return v, nil
+}
+
+// listParam collects comma separated values from repeated query parameters.
+func listParam(r *http.Request, name string, lower bool) map[string]bool {
+	set := make(map[string]bool)
+	for _, raw := range r.URL.Query()[name] {
+		for _, v := range strings.Split(raw, ",") {
+			v = strings.TrimSpace(v)
+			if lower {
+				v = strings.ToLower(v)
+			}
+			if v != "" {
+				set[v] = true
+			}
+		}
+	}
+	return set
+}
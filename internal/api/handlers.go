@@ -3,13 +3,17 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/tanq16/expenseowl/internal/auth"
+	"github.com/tanq16/expenseowl/internal/blobstore"
+	"github.com/tanq16/expenseowl/internal/encryption"
 	"github.com/tanq16/expenseowl/internal/integrations/telegram"
 	"github.com/tanq16/expenseowl/internal/storage"
 	"github.com/tanq16/expenseowl/internal/user"
@@ -22,15 +26,21 @@ type Handler struct {
 	users    *user.Service
 	auth     *auth.JWTManager
 	telegram *telegram.Service
+	bot      *telegram.Bot
+	blobs    blobstore.Store
+	imports  *importJobs
 }
 
 // NewHandler creates a new API handler.
-func NewHandler(s storage.Storage, userService *user.Service, authManager *auth.JWTManager, telegramService *telegram.Service) *Handler {
+func NewHandler(s storage.Storage, userService *user.Service, authManager *auth.JWTManager, telegramService *telegram.Service, bot *telegram.Bot, blobs blobstore.Store) *Handler {
 	return &Handler{
 		storage:  s,
 		users:    userService,
 		auth:     authManager,
 		telegram: telegramService,
+		bot:      bot,
+		blobs:    blobs,
+		imports:  newImportJobs(),
 	}
 }
 
@@ -275,14 +285,29 @@ func (h *Handler) AddExpense(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	if expense.Source == "" {
+		expense.Source = storage.ExpenseSourceManual
+	}
+	h.applyCategorizationRules(userCtx.ID, &expense)
 	if err := expense.Validate(); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	if expense.Status == storage.ExpenseStatusReconciled {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "only a completed reconciliation can mark an expense as reconciled"})
+		return
+	}
 	if expense.Date.IsZero() {
 		expense.Date = time.Now()
 	}
+	if h.rejectClosedDates(w, userCtx.ID, expense.Date) || h.screenDuplicate(w, r, userCtx.ID, manager, &expense) {
+		return
+	}
 	expense.UserID = userCtx.ID
+	if expense.ID == "" {
+		expense.ID = uuid.New().String()
+	}
+	expense.Blob = "" // rebuilt below so it carries the server-side changes
 	if err := ensureExpenseBlob(manager, &expense); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
@@ -292,6 +317,7 @@ func (h *Handler) AddExpense(w http.ResponseWriter, r *http.Request) {
 		log.Printf("API ERROR: Failed to save expense: %v\n", err)
 		return
 	}
+	h.checkNewExpenses(userCtx.ID, manager, expense.ID)
 	writeJSON(w, http.StatusOK, expense)
 }
 
@@ -342,8 +368,13 @@ func (h *Handler) EditExpense(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
 		return
 	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
 	var expense storage.Expense
-	if err := json.NewDecoder(r.Body).Decode(&expense); err != nil {
+	if err := json.Unmarshal(body, &expense); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
 		return
 	}
@@ -356,10 +387,31 @@ func (h *Handler) EditExpense(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	if h.rejectLockedExpenses(w, userCtx.ID, id) || h.rejectClosedExpenses(w, userCtx.ID, manager, id) {
+		return
+	}
+	existing, err := h.loadExpense(userCtx.ID, id, manager)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, ErrorResponse{Error: err.Error()})
+		return
+	}
+	keepUnsentExpenseFields(&expense, existing, sentExpenseFields(body, manager))
+	expense.Blob = "" // rebuilt below so it carries the kept values
 	if err := expense.Validate(); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	if expense.Status == storage.ExpenseStatusReconciled {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "only a completed reconciliation can mark an expense as reconciled"})
+		return
+	}
+	if h.rejectClosedDates(w, userCtx.ID, expense.Date) {
+		return
+	}
 	if expense.ID == "" {
 		expense.ID = id
 	}
@@ -375,6 +427,59 @@ func (h *Handler) EditExpense(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, expense)
 }
 
+// expenseCarryOver lists the fields an edit keeps from the stored expense
+// when the client doesn't send them, so clients unaware of a field don't
+// clear it.
+var expenseCarryOver = []struct {
+	key  string
+	keep func(expense *storage.Expense, existing storage.Expense)
+}{
+	{"recurringID", func(e *storage.Expense, x storage.Expense) { e.RecurringID = x.RecurringID }},
+	{"splits", func(e *storage.Expense, x storage.Expense) { e.Splits = x.Splits }},
+	{"reimbursable", func(e *storage.Expense, x storage.Expense) { e.Reimbursable = x.Reimbursable }},
+	{"installment", func(e *storage.Expense, x storage.Expense) { e.Installment = x.Installment }},
+	{"taxClass", func(e *storage.Expense, x storage.Expense) { e.TaxClass = x.TaxClass }},
+	{"status", func(e *storage.Expense, x storage.Expense) { e.Status = x.Status }},
+	{"account", func(e *storage.Expense, x storage.Expense) { e.Account = x.Account }},
+	{"source", func(e *storage.Expense, x storage.Expense) { e.Source = x.Source }},
+	{"payeeId", func(e *storage.Expense, x storage.Expense) { e.PayeeID = x.PayeeID }},
+	{"duplicateOf", func(e *storage.Expense, x storage.Expense) { e.DuplicateOf = x.DuplicateOf }},
+	{"externalRef", func(e *storage.Expense, x storage.Expense) { e.ExternalRef = x.ExternalRef }},
+	{"note", func(e *storage.Expense, x storage.Expense) { e.Note = x.Note }},
+	{"importBatchId", func(e *storage.Expense, x storage.Expense) { e.ImportBatchID = x.ImportBatchID }},
+}
+
+func keepUnsentExpenseFields(expense *storage.Expense, existing storage.Expense, sent map[string]bool) {
+	for _, field := range expenseCarryOver {
+		if !sent[field.key] {
+			field.keep(expense, existing)
+		}
+	}
+}
+
+// sentExpenseFields returns the JSON keys of an expense request body. A body
+// carrying a blob sends the blob's fields.
+func sentExpenseFields(body []byte, manager *encryption.Manager) map[string]bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+	var blob string
+	if raw, ok := fields["blob"]; ok && json.Unmarshal(raw, &blob) == nil && blob != "" {
+		var inner map[string]json.RawMessage
+		if manager != nil && manager.Decrypt(blob, &inner) == nil {
+			fields = inner
+		} else if json.Unmarshal([]byte(blob), &inner) == nil {
+			fields = inner
+		}
+	}
+	sent := make(map[string]bool, len(fields))
+	for key := range fields {
+		sent[key] = true
+	}
+	return sent
+}
+
 func (h *Handler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
@@ -390,11 +495,21 @@ func (h *Handler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
 		return
 	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if h.rejectLockedExpenses(w, userCtx.ID, id) || h.rejectClosedExpenses(w, userCtx.ID, manager, id) {
+		return
+	}
+	attachments := h.expenseAttachments(userCtx.ID, id)
 	if err := h.storage.RemoveExpense(userCtx.ID, id); err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete expense"})
 		log.Printf("API ERROR: Failed to delete expense: %v\n", err)
 		return
 	}
+	h.deleteAttachmentBlobs(r.Context(), attachments)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
@@ -415,11 +530,21 @@ func (h *Handler) DeleteMultipleExpenses(w http.ResponseWriter, r *http.Request)
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
 		return
 	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if h.rejectLockedExpenses(w, userCtx.ID, payload.IDs...) || h.rejectClosedExpenses(w, userCtx.ID, manager, payload.IDs...) {
+		return
+	}
+	attachments := h.expenseAttachments(userCtx.ID, payload.IDs...)
 	if err := h.storage.RemoveMultipleExpenses(userCtx.ID, payload.IDs); err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete multiple expenses"})
 		log.Printf("API ERROR: Failed to delete multiple expenses: %v\n", err)
 		return
 	}
+	h.deleteAttachmentBlobs(r.Context(), attachments)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
@@ -456,6 +581,9 @@ func (h *Handler) AddRecurringExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	re.UserID = userCtx.ID
+	if h.rejectClosedRecurring(w, userCtx.ID, re) {
+		return
+	}
 	if err := ensureRecurringBlob(manager, &re); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
@@ -539,15 +667,33 @@ func (h *Handler) UpdateRecurringExpense(w http.ResponseWriter, r *http.Request)
 	if re.ID == "" {
 		re.ID = id
 	}
+	// updateAll regenerates every occurrence from the start date, so both the
+	// old and the new schedule must stay clear of closed periods
+	if updateAll {
+		existing, err := h.storage.GetRecurringExpense(userCtx.ID, id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := decryptRecurring(manager, &existing); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if h.rejectClosedRecurring(w, userCtx.ID, existing, re) || h.rejectLockedRecurring(w, userCtx.ID, id) {
+			return
+		}
+	}
 	if err := ensureRecurringBlob(manager, &re); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	attachments := h.recurringAttachments(userCtx.ID, id)
     if err := h.storage.UpdateRecurringExpense(userCtx.ID, id, re, updateAll, manager); err != nil {
         writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update recurring expense"})
         log.Printf("API ERROR: Failed to update recurring expense: %v\n", err)
         return
     }
+	h.deleteRemovedRecurringBlobs(r.Context(), userCtx.ID, id, attachments)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
@@ -567,12 +713,33 @@ func (h *Handler) DeleteRecurringExpense(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	removeAll, _ := strconv.ParseBool(r.URL.Query().Get("removeAll"))
+	if removeAll {
+		manager, err := h.encryptionManagerFromRequest(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		existing, err := h.storage.GetRecurringExpense(userCtx.ID, id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := decryptRecurring(manager, &existing); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if h.rejectClosedRecurring(w, userCtx.ID, existing) || h.rejectLockedRecurring(w, userCtx.ID, id) {
+			return
+		}
+	}
 
+	attachments := h.recurringAttachments(userCtx.ID, id)
 	if err := h.storage.RemoveRecurringExpense(userCtx.ID, id, removeAll); err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete recurring expense"})
 		log.Printf("API ERROR: Failed to delete recurring expense: %v\n", err)
 		return
 	}
+	h.deleteRemovedRecurringBlobs(r.Context(), userCtx.ID, id, attachments)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
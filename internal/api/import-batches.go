@@ -0,0 +1,83 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// GetImports lists the import batches of the user, newest first.
+func (h *Handler) GetImports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	batches, err := h.storage.GetImportBatches(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve imports"})
+		log.Printf("API ERROR: Failed to retrieve imports: %v\n", err)
+		return
+	}
+	if batches == nil {
+		batches = []storage.ImportBatch{}
+	}
+	writeJSON(w, http.StatusOK, batches)
+}
+
+// UndoImport deletes every expense an import created that still exists. It
+// is refused, like deleting the expenses one by one, when any of them is
+// reconciled or falls in a closed period.
+func (h *Handler) UndoImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.PathValue("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	batch, err := h.storage.GetImportBatch(userCtx.ID, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve import"})
+		log.Printf("API ERROR: Failed to retrieve import batch: %v\n", err)
+		return
+	}
+	if len(batch.ExpenseIDs) > 0 && (h.rejectLockedExpenses(w, userCtx.ID, batch.ExpenseIDs...) || h.rejectClosedExpenses(w, userCtx.ID, manager, batch.ExpenseIDs...)) {
+		return
+	}
+	attachments := h.expenseAttachments(userCtx.ID, batch.ExpenseIDs...)
+	if err := h.storage.RemoveImportBatch(userCtx.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to undo import"})
+		log.Printf("API ERROR: Failed to undo import batch: %v\n", err)
+		return
+	}
+	h.deleteAttachmentBlobs(r.Context(), attachments)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "success", "removed": len(batch.ExpenseIDs)})
+	log.Printf("HTTP: Undid %s import %s, removed %d expenses.", batch.Format, batch.ID, len(batch.ExpenseIDs))
+}
@@ -1,19 +1,28 @@
 package api
 
 import (
+	"archive/zip"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"math"
 	"net/http"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/importer"
 	"github.com/tanq16/expenseowl/internal/storage"
 )
 
-// exports all expenses to CSV
+// exports expenses to CSV, optionally filtered by from/to, category, tag and
+// recurring=true, with a choice of columns and spreadsheet-friendly options
+// (see csvExportFromRequest)
 func (h *Handler) ExportCSV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
@@ -24,6 +33,17 @@ func (h *Handler) ExportCSV(w http.ResponseWriter, r *http.Request) {
 		unauthorized(w)
 		return
 	}
+	currency, err := h.storage.GetCurrency(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get currency"})
+		log.Printf("API ERROR: Failed to get currency for CSV export: %v\n", err)
+		return
+	}
+	layout, err := csvExportFromRequest(r, currency)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
     expenses, err := h.storage.GetAllExpenses(userCtx.ID)
     if err != nil {
         writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve expenses"})
@@ -38,40 +58,247 @@ func (h *Handler) ExportCSV(w http.ResponseWriter, r *http.Request) {
             }
         }
     }
+	records, err := layout.records(expenses, h.attachmentNames(userCtx.ID))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
 	w.Header().Set("Content-Type", "text/csv")
 	w.Header().Set("Content-Disposition", "attachment; filename=expenses.csv")
-	writer := csv.NewWriter(w)
-	defer writer.Flush()
-
-	// Write header
-	headers := []string{"ID", "Name", "Category", "Amount", "Date", "Tags"}
-	if err := writer.Write(headers); err != nil {
-		log.Printf("API ERROR: Failed to write CSV header: %v\n", err)
+	if err := layout.write(w, records); err != nil {
+		log.Printf("API ERROR: Failed to write CSV export: %v\n", err)
 		return
 	}
+	log.Printf("HTTP: Exported %d expense rows to CSV", len(records)-1)
+}
+
+// attachmentNames maps expense IDs to the file names of their attachments.
+func (h *Handler) attachmentNames(userID string) map[string][]string {
+	names := make(map[string][]string)
+	attachments, err := h.storage.GetAttachments(userID, "")
+	if err != nil {
+		log.Printf("API ERROR: Failed to retrieve attachments for CSV export: %v\n", err)
+		return names
+	}
+	for _, a := range attachments {
+		names[a.ExpenseID] = append(names[a.ExpenseID], a.FileName)
+	}
+	return names
+}
+
+// csvExportColumns are the columns the CSV export can write.
+var csvExportColumns = []string{"ID", "Name", "Category", "Amount", "Currency", "Date", "Tags", "Attachments", "ParentID", "Note", "TaxClass", "Account", "Status", "RecurringID", "BaseAmount"}
+
+// defaultCSVColumns is the layout written without a column choice, which the
+// CSV import reads back.
+var defaultCSVColumns = []string{"ID", "Name", "Category", "Amount", "Currency", "Date", "Tags", "Attachments", "ParentID", "Note", "TaxClass", "Account", "Status"}
+
+// csvExport is the layout and the filters of a CSV export.
+type csvExport struct {
+	columns       []string
+	delimiter     rune
+	decimal       string
+	bom           bool
+	from, to      time.Time       // to is exclusive
+	categories    map[string]bool // lowercase, empty for all
+	tags          map[string]bool // lowercase, empty for all
+	recurringOnly bool
+	currency      string             // the user's currency
+	rates         map[string]float64 // units of the user's currency per unit of another
+}
+
+// newCSVExport is the unfiltered export in the default layout.
+func newCSVExport(currency string) csvExport {
+	return csvExport{columns: defaultCSVColumns, delimiter: ',', decimal: ".", currency: strings.ToLower(currency)}
+}
+
+// csvExportFromRequest reads the query parameters of a CSV export: from/to,
+// category, tag, recurring, columns, delimiter (comma, semicolon, pipe or
+// tab), decimalSeparator, bom and rate=eur:1.08 for the BaseAmount column
+// (units of the user's currency per unit of the expense's currency).
+func csvExportFromRequest(r *http.Request, currency string) (csvExport, error) {
+	layout := newCSVExport(currency)
+	query := r.URL.Query()
+	var err error
+	if layout.from, layout.to, err = dateRangeFromRequest(r); err != nil {
+		return layout, err
+	}
+	layout.categories = listParam(r, "category", true)
+	layout.tags = listParam(r, "tag", true)
+	if raw := query.Get("recurring"); raw != "" {
+		if layout.recurringOnly, err = strconv.ParseBool(raw); err != nil {
+			return layout, fmt.Errorf("'recurring' must be true or false")
+		}
+	}
+	if raw := query.Get("bom"); raw != "" {
+		if layout.bom, err = strconv.ParseBool(raw); err != nil {
+			return layout, fmt.Errorf("'bom' must be true or false")
+		}
+	}
+	var columns []string
+	for _, raw := range query["columns"] {
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			i := slices.IndexFunc(csvExportColumns, func(column string) bool { return strings.EqualFold(column, name) })
+			if i < 0 {
+				return layout, fmt.Errorf("unknown column: %s (valid columns: %s)", name, strings.Join(csvExportColumns, ", "))
+			}
+			if !slices.Contains(columns, csvExportColumns[i]) {
+				columns = append(columns, csvExportColumns[i])
+			}
+		}
+	}
+	if len(columns) > 0 {
+		layout.columns = columns
+	}
+	switch strings.ToLower(query.Get("delimiter")) {
+	case "", ",", "comma":
+	case ";", "semicolon":
+		layout.delimiter = ';'
+	case "|", "pipe":
+		layout.delimiter = '|'
+	case "\t", "tab":
+		layout.delimiter = '\t'
+	default:
+		return layout, fmt.Errorf("'delimiter' must be comma, semicolon, pipe or tab")
+	}
+	switch decimal := query.Get("decimalSeparator"); decimal {
+	case "", ".":
+	case ",":
+		if layout.delimiter == ',' {
+			return layout, fmt.Errorf("the decimal separator must differ from the delimiter")
+		}
+		layout.decimal = decimal
+	default:
+		return layout, fmt.Errorf("'decimalSeparator' must be '.' or ','")
+	}
+	for rate := range listParam(r, "rate", true) {
+		code, value, ok := strings.Cut(rate, ":")
+		factor, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || factor <= 0 {
+			return layout, fmt.Errorf("invalid rate %q, expected currency:rate such as eur:1.08", rate)
+		}
+		if layout.rates == nil {
+			layout.rates = make(map[string]float64)
+		}
+		layout.rates[strings.TrimSpace(code)] = factor
+	}
+	return layout, nil
+}
 
-	// Write records
+// includes reports whether an expense passes the filters other than
+// category and tags, which apply per split.
+func (c csvExport) includes(expense storage.Expense) bool {
+	if c.recurringOnly && expense.RecurringID == "" {
+		return false
+	}
+	if !c.from.IsZero() && expense.Date.Before(c.from) {
+		return false
+	}
+	if !c.to.IsZero() && !expense.Date.Before(c.to) {
+		return false
+	}
+	return true
+}
+
+// matches reports whether a category and its tags pass the filters.
+func (c csvExport) matches(category string, tags []string) bool {
+	if len(c.categories) > 0 && !c.categories[strings.ToLower(category)] {
+		return false
+	}
+	if len(c.tags) > 0 && !slices.ContainsFunc(tags, func(tag string) bool { return c.tags[strings.ToLower(tag)] }) {
+		return false
+	}
+	return true
+}
+
+// records builds the header and the rows of the expenses that pass the
+// filters. Split expenses produce one row per split sharing the parent ID.
+// BaseAmount needs a rate for every other currency among the rows.
+func (c csvExport) records(expenses []storage.Expense, attachmentNames map[string][]string) ([][]string, error) {
+	records := [][]string{c.columns}
+	missing := make(map[string]bool)
 	for _, expense := range expenses {
-		record := []string{
-			expense.ID,
-			expense.Name,
-			expense.Category,
-			// expense.Currency,
-			strconv.FormatFloat(expense.Amount, 'f', 2, 64),
-			expense.Date.Format(time.RFC3339),
-			strings.Join(expense.Tags, ","),
-		}
-		if err := writer.Write(record); err != nil {
-			log.Printf("API ERROR: Failed to write CSV record for expense ID %s: %v\n", expense.ID, err)
+		if !c.includes(expense) {
 			continue
 		}
+		currency := strings.ToLower(expense.Currency)
+		if currency == "" {
+			currency = c.currency
+		}
+		rate := 1.0
+		if currency != c.currency {
+			var ok bool
+			if rate, ok = c.rates[currency]; !ok && slices.Contains(c.columns, "BaseAmount") {
+				missing[currency] = true
+			}
+		}
+		values := map[string]string{
+			"Name":        expense.Name,
+			"Currency":    currency,
+			"Date":        expense.Date.Format(time.RFC3339),
+			"Attachments": strings.Join(attachmentNames[expense.ID], ","),
+			"TaxClass":    expense.TaxClass,
+			"Account":     expense.Account,
+			"Status":      expense.Status,
+			"RecurringID": expense.RecurringID,
+		}
+		row := func(id, parentID string, portion storage.ExpenseSplit, note string) []string {
+			values["ID"], values["ParentID"] = id, parentID
+			values["Category"], values["Tags"], values["Note"] = portion.Category, strings.Join(portion.Tags, ","), note
+			values["Amount"] = c.formatAmount(portion.Amount)
+			values["BaseAmount"] = c.formatAmount(math.Round(portion.Amount*rate*100) / 100)
+			record := make([]string, len(c.columns))
+			for i, column := range c.columns {
+				record[i] = values[column]
+			}
+			return record
+		}
+		if len(expense.Splits) > 0 {
+			for _, split := range expense.Splits {
+				if c.matches(split.Category, split.Tags) {
+					records = append(records, row("", expense.ID, split, split.Note))
+				}
+			}
+			continue
+		}
+		if c.matches(expense.Category, expense.Tags) {
+			portion := storage.ExpenseSplit{Category: expense.Category, Amount: expense.Amount, Tags: expense.Tags}
+			records = append(records, row(expense.ID, "", portion, expense.Note))
+		}
 	}
-	log.Println("HTTP: Exported expenses to CSV")
+	if len(missing) > 0 {
+		currencies := slices.Sorted(maps.Keys(missing))
+		return nil, fmt.Errorf("BaseAmount needs a rate for %s, e.g. rate=%s:1.0", strings.Join(currencies, ", "), currencies[0])
+	}
+	return records, nil
 }
 
-// imports expenses from CSV
-func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+func (c csvExport) formatAmount(amount float64) string {
+	return strings.Replace(strconv.FormatFloat(amount, 'f', 2, 64), ".", c.decimal, 1)
+}
+
+// write writes the records with the export's delimiter, after a byte order
+// mark when asked for one so spreadsheet programs detect UTF-8.
+func (c csvExport) write(out io.Writer, records [][]string) error {
+	if c.bom {
+		if _, err := io.WriteString(out, "\ufeff"); err != nil {
+			return fmt.Errorf("failed to write byte order mark: %v", err)
+		}
+	}
+	writer := csv.NewWriter(out)
+	writer.Comma = c.delimiter
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	return nil
+}
+
+// exports all expenses as QIF for Quicken, GnuCash and other QIF tools
+func (h *Handler) ExportQIF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
 		return
 	}
@@ -85,28 +312,196 @@ func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
-	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10MB max file size
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Could not parse multipart form"})
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve expenses"})
+		log.Printf("API ERROR: Failed to retrieve expenses for QIF export: %v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/qif")
+	w.Header().Set("Content-Disposition", "attachment; filename=expenses.qif")
+	if err := writeExpensesQIF(w, expenses); err != nil {
+		log.Printf("API ERROR: Failed to write QIF export: %v\n", err)
 		return
 	}
-	file, _, err := r.FormFile("file")
+	log.Println("HTTP: Exported expenses to QIF")
+}
+
+// writeExpensesQIF writes one bank register per account, named by an
+// !Account block. Expenses without an account come first, before any
+// !Account block switches away from the register being imported into. Tags
+// travel in the QIF class field after the category.
+func writeExpensesQIF(out io.Writer, expenses []storage.Expense) error {
+	var accounts []string
+	byAccount := make(map[string][]storage.Expense)
+	for _, expense := range expenses {
+		if _, ok := byAccount[expense.Account]; !ok {
+			accounts = append(accounts, expense.Account)
+		}
+		byAccount[expense.Account] = append(byAccount[expense.Account], expense)
+	}
+	if i := slices.Index(accounts, ""); i > 0 {
+		accounts = append([]string{""}, slices.Delete(accounts, i, i+1)...)
+	}
+	var b strings.Builder
+	b.WriteString("!Option:AutoSwitch\n")
+	for _, account := range accounts {
+		if account != "" {
+			fmt.Fprintf(&b, "!Account\nN%s\nTBank\n^\n", qifText(account))
+		}
+		b.WriteString("!Type:Bank\n")
+		for _, expense := range byAccount[account] {
+			fmt.Fprintf(&b, "D%s\n", expense.Date.Format("01/02/2006"))
+			fmt.Fprintf(&b, "T%s\n", strconv.FormatFloat(expense.Amount, 'f', 2, 64))
+			fmt.Fprintf(&b, "P%s\n", qifText(expense.Name))
+			if expense.Note != "" {
+				fmt.Fprintf(&b, "M%s\n", qifText(expense.Note))
+			}
+			switch expense.Status {
+			case storage.ExpenseStatusCleared:
+				b.WriteString("C*\n")
+			case storage.ExpenseStatusReconciled:
+				b.WriteString("CX\n")
+			}
+			fmt.Fprintf(&b, "L%s\n", qifCategoryField(expense.Category, expense.Tags))
+			for _, split := range expense.Splits {
+				fmt.Fprintf(&b, "S%s\n", qifCategoryField(split.Category, split.Tags))
+				if split.Note != "" {
+					fmt.Fprintf(&b, "E%s\n", qifText(split.Note))
+				}
+				fmt.Fprintf(&b, "$%s\n", strconv.FormatFloat(split.Amount, 'f', 2, 64))
+			}
+			b.WriteString("^\n")
+		}
+	}
+	if _, err := io.WriteString(out, b.String()); err != nil {
+		return fmt.Errorf("failed to write QIF: %v", err)
+	}
+	return nil
+}
+
+// qifCategoryField builds an L or S field: "/" separates the class, so it
+// can't appear in the category itself.
+func qifCategoryField(category string, tags []string) string {
+	field := strings.ReplaceAll(qifText(category), "/", "-")
+	var classes []string
+	for _, tag := range tags {
+		if tag = strings.NewReplacer("/", "-", ":", "-").Replace(qifText(tag)); tag != "" {
+			classes = append(classes, tag)
+		}
+	}
+	if len(classes) > 0 {
+		field += "/" + strings.Join(classes, ":")
+	}
+	return field
+}
+
+// qifText keeps a value on its single QIF line.
+func qifText(value string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(value))
+}
+
+// exports all expenses as a ZIP archive containing the CSV export and every
+// attachment, decrypted when the client supplies its encryption key
+func (h *Handler) ExportArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Error retrieving the file"})
+		unauthorized(w)
 		return
 	}
-	defer file.Close()
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	expenses, err := h.storage.GetAllExpenses(userCtx.ID)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read CSV file"})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve expenses"})
+		log.Printf("API ERROR: Failed to retrieve expenses for archive export: %v\n", err)
 		return
 	}
-	if len(records) < 2 {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "CSV file must have a header and at least one data row"})
+	if manager != nil {
+		for i := range expenses {
+			if err := decryptExpense(manager, &expenses[i]); err != nil {
+				log.Printf("API ERROR: Failed to decrypt expense %s for archive export: %v\n", expenses[i].ID, err)
+			}
+		}
+	}
+	attachments, err := h.storage.GetAttachments(userCtx.ID, "")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve attachments"})
+		log.Printf("API ERROR: Failed to retrieve attachments for archive export: %v\n", err)
+		return
+	}
+	currency, err := h.storage.GetCurrency(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get currency"})
+		log.Printf("API ERROR: Failed to get currency for archive export: %v\n", err)
 		return
 	}
 
-	header := records[0]
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=expenses.zip")
+	archive := zip.NewWriter(w)
+	defer archive.Close()
+
+	attachmentNames := make(map[string][]string)
+	for _, a := range attachments {
+		path := fmt.Sprintf("attachments/%s/%s-%s", a.ExpenseID, a.ID[:8], a.FileName)
+		content, err := h.readAttachment(r.Context(), a, manager)
+		if err != nil {
+			log.Printf("API ERROR: Skipping attachment %s in archive export: %v\n", a.ID, err)
+			continue
+		}
+		entry, err := archive.Create(path)
+		if err != nil {
+			log.Printf("API ERROR: Failed to add attachment %s to archive: %v\n", a.ID, err)
+			return
+		}
+		if _, err := entry.Write(content); err != nil {
+			log.Printf("API ERROR: Failed to write attachment %s to archive: %v\n", a.ID, err)
+			return
+		}
+		attachmentNames[a.ExpenseID] = append(attachmentNames[a.ExpenseID], path)
+	}
+	entry, err := archive.Create("expenses.csv")
+	if err != nil {
+		log.Printf("API ERROR: Failed to add CSV to archive: %v\n", err)
+		return
+	}
+	layout := newCSVExport(currency)
+	records, err := layout.records(expenses, attachmentNames)
+	if err == nil {
+		err = layout.write(entry, records)
+	}
+	if err != nil {
+		log.Printf("API ERROR: Failed to write CSV to archive: %v\n", err)
+		return
+	}
+	log.Println("HTTP: Exported expenses and attachments as archive")
+}
+
+// imports expenses from CSV, either in ExpenseOwl's own layout or, with the
+// profile form value, in a bank's layout described by an import profile
+func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "csv", false)
+}
+
+// readExpenseCSV reads the header of a CSV file in ExpenseOwl's layout (or
+// the layout of releases before v4.0) and returns the reader positioned at
+// the first data row, so large files are read record by record.
+func readExpenseCSV(file io.Reader) (*csv.Reader, []string, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // rows with a wrong column count are reported one by one
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSV file must have a header and at least one data row")
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // written by spreadsheet programs and the bom export option
 	colMap := make(map[string]int)
 	for i, col := range header {
 		colMap[strings.ToLower(strings.TrimSpace(col))] = i
@@ -115,80 +510,101 @@ func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 	requiredCols := []string{"name", "category", "amount", "date"}
 	for _, col := range requiredCols {
 		if _, ok := colMap[col]; !ok {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Missing required column: %s", col)})
-			return
+			return nil, nil, fmt.Errorf("Missing required column: %s", col)
 		}
 	}
+	return reader, header, nil
+}
+
+// nextCSVRecord reads the next data row of a streamed CSV file. ok is false
+// at the end of the file, when the file can't be read any further or when
+// the import was aborted. A malformed row is reported and returned as nil.
+func nextCSVRecord(pipeline *importPipeline, reader *csv.Reader, label string) (record []string, ok bool) {
+	for pipeline.err == nil {
+		record, err := reader.Read()
+		if err == nil {
+			return record, true
+		}
+		if err == io.EOF {
+			return nil, false
+		}
+		var parseErr *csv.ParseError
+		if !errors.As(err, &parseErr) {
+			pipeline.fail(label, nil, fmt.Errorf("failed to read CSV file: %v", err), 1)
+			return nil, false
+		}
+		pipeline.fail(label, nil, fmt.Errorf("malformed row: %v", parseErr.Err), 1)
+		return nil, true
+	}
+	return nil, false
+}
+
+// feedCSV runs the rows of a CSV file in ExpenseOwl's own layout through
+// the pipeline. Rows sharing a parent ID are merged into one split expense.
+func (h *Handler) feedCSV(pipeline *importPipeline, reader *csv.Reader, header []string, locale importer.Locale) int {
+	colMap := make(map[string]int)
+	for i, col := range header {
+		colMap[strings.ToLower(strings.TrimSpace(col))] = i
+	}
 	// Get optional column indices
 	idIdx, idExists := colMap["id"]
 	tagsIdx, tagsExists := colMap["tags"]
 	currencyIdx, currencyExists := colMap["currency"]
+	parentIdx, parentExists := colMap["parentid"]
+	noteIdx, noteExists := colMap["note"]
+	taxClassIdx, taxClassExists := colMap["taxclass"]
+	accountIdx, accountExists := colMap["account"]
+	// rows sharing a parent ID are collected and stored as one split expense
+	splitGroups := make(map[string]*storage.Expense)
+	var splitOrder []string
 
-	currentCategories, err := h.storage.GetCategories(userCtx.ID)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Could not retrieve current categories"})
-		return
-	}
-	categorySet := make(map[string]bool)
-	for _, cat := range currentCategories {
-		categorySet[strings.ToLower(cat)] = true
-	}
-	var newCategories []string
-	var importedCount, skippedCount int
-	// TODO: might be worth setting default currency when we have currency updation behavior
-	currencyVal, err := h.storage.GetCurrency(userCtx.ID)
-	if err != nil {
-		log.Printf("Error: Could not retrieve currency, shutting down import: %v\n", err)
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Could not retrieve currency"})
-		return
-	}
-
-	for i, record := range records[1:] {
+	rows := 0
+	for {
+		label := fmt.Sprintf("row %d", rows+2)
+		record, ok := nextCSVRecord(pipeline, reader, label)
+		if !ok {
+			break
+		}
+		rows++
+		if record == nil {
+			continue
+		}
 		if len(record) != len(header) {
-			log.Printf("Warning: Skipping row %d due to incorrect column count\n", i+2)
-			skippedCount++
+			pipeline.fail(label, nil, fmt.Errorf("incorrect column count"), 1)
 			continue
 		}
 
 		// Check if expense exists by ID, if provided - without doing a clash resolution
 		if idExists {
 			id := record[idIdx]
-			if _, err := h.storage.GetExpense(userCtx.ID, id); err == nil {
-				log.Printf("Info: Skipping row %d because expense with ID '%s' already exists\n", i+2, id)
-				skippedCount++
+			if _, err := h.storage.GetExpense(pipeline.userID, id); err == nil {
+				pipeline.skip(label, nil, fmt.Sprintf("expense with ID '%s' already exists", id), 1)
 				continue
 			}
 		}
 
 		// Check for currency field, if provided - default is retrieved
-		localCurrency := currencyVal
+		localCurrency := pipeline.currency
 		if currencyExists {
 			currency := record[currencyIdx]
 			if !slices.Contains(storage.SupportedCurrencies, currency) {
-				log.Printf("Warning: Skipping row %d due to invalid currency: %s\n", i+2, currency)
-				skippedCount++
+				pipeline.fail(label, nil, fmt.Errorf("invalid currency: %s", currency), 1)
 				continue
 			}
 			localCurrency = strings.TrimSpace(currency)
 		}
 
-		amount, err := strconv.ParseFloat(record[colMap["amount"]], 64)
+		amount, err := locale.ParseAmount(record[colMap["amount"]])
 		if err != nil {
-			log.Printf("Warning: Skipping row %d due to invalid amount: %s\n", i+2, record[colMap["amount"]])
-			skippedCount++
+			pipeline.fail(label, nil, err, 1)
 			continue
 		}
-		date, err := parseDate(record[colMap["date"]])
+		date, err := locale.ParseDate(record[colMap["date"]])
 		if err != nil {
-			log.Printf("Warning: Skipping row %d due to invalid date: %v\n", i+2, err)
-			skippedCount++
+			pipeline.fail(label, nil, fmt.Errorf("invalid date: %v", err), 1)
 			continue
 		}
 		category := strings.TrimSpace(record[colMap["category"]])
-		if _, ok := categorySet[strings.ToLower(category)]; !ok {
-			newCategories = append(newCategories, category)
-			categorySet[strings.ToLower(category)] = true // Add to set to handle duplicates in the same file
-		}
 		var tags []string
 		if tagsExists {
 			tagsStr := record[tagsIdx]
@@ -199,6 +615,40 @@ func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
+		var taxClass string
+		if taxClassExists {
+			taxClass = record[taxClassIdx]
+		}
+		var account string
+		if accountExists {
+			account = record[accountIdx]
+		}
+
+		if parentExists && strings.TrimSpace(record[parentIdx]) != "" {
+			parentID := strings.TrimSpace(record[parentIdx])
+			group, ok := splitGroups[parentID]
+			if !ok {
+				group = &storage.Expense{
+					ID:       parentID,
+					Name:     strings.TrimSpace(record[colMap["name"]]),
+					Currency: localCurrency,
+					Date:     date,
+					TaxClass: taxClass,
+					Account:  account,
+					Status:   storage.ExpenseStatusCleared, // bank rows are cleared
+					Source:   storage.ExpenseSourceImport,
+				}
+				splitGroups[parentID] = group
+				splitOrder = append(splitOrder, parentID)
+			}
+			split := storage.ExpenseSplit{Category: category, Amount: amount, Tags: tags}
+			if noteExists {
+				split.Note = strings.TrimSpace(record[noteIdx])
+			}
+			group.Splits = append(group.Splits, split)
+			group.Amount += amount
+			continue
+		}
 
 		expense := storage.Expense{
 			Name:     strings.TrimSpace(record[colMap["name"]]),
@@ -207,171 +657,84 @@ func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 			Currency: localCurrency,
 			Date:     date,
 			Tags:     tags,
+			TaxClass: taxClass,
+			Account:  account,
+			Status:   storage.ExpenseStatusCleared, // bank rows are cleared
 		}
-		if err := expense.Validate(); err != nil {
-			log.Printf("Warning: Skipping row %d due to validation error: %v\n", i+2, err)
-			skippedCount++
-			continue
-		}
-		if err := ensureExpenseBlob(manager, &expense); err != nil {
-			log.Printf("Warning: Skipping row %d due to encryption error: %v\n", i+2, err)
-			skippedCount++
-			continue
-		}
-		if err := h.storage.AddExpense(userCtx.ID, expense); err != nil {
-			log.Printf("Error: Could not add expense from row %d: %v\n", i+2, err)
-			skippedCount++
-			continue
+		if noteExists {
+			expense.Note = strings.TrimSpace(record[noteIdx])
 		}
-		importedCount++
-		time.Sleep(10 * time.Millisecond) // Throttle to reduce storage overhead
+		pipeline.add(expense, label)
 	}
 
-	if len(newCategories) > 0 {
-		if err := h.storage.UpdateCategories(userCtx.ID, append(currentCategories, newCategories...)); err != nil {
-			log.Printf("Warning: Failed to add new categories to config: %v\n", err)
+	for _, parentID := range splitOrder {
+		expense := *splitGroups[parentID]
+		label := fmt.Sprintf("split expense '%s'", parentID)
+		if _, err := uuid.Parse(parentID); err != nil {
+			expense.ID = "" // foreign parent IDs only group rows, a new ID is generated
+		} else if _, err := h.storage.GetExpense(pipeline.userID, parentID); err == nil {
+			pipeline.skip(label, &expense, "it already exists", len(expense.Splits))
+			continue
 		}
+		pipeline.addRows(expense, label, len(expense.Splits))
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
-		"status":          "success",
-		"total_processed": len(records) - 1,
-		"imported":        importedCount,
-		"skipped":         skippedCount,
-		"new_categories":  newCategories,
-	})
-	log.Printf("HTTP: Imported %d expenses from CSV file. Skipped %d records.", importedCount, skippedCount)
+	return rows
 }
 
 // handles importing from ExpenseOwl < v4.0
 // TODO: remove this in the future
 func (h *Handler) ImportOldCSV(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
-		return
-	}
-	userCtx, err := h.userFromRequest(r)
-	if err != nil {
-		unauthorized(w)
-		return
-	}
-	manager, err := h.encryptionManagerFromRequest(r)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
-		return
-	}
-	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10MB max file size
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Could not parse multipart form"})
-		return
-	}
-	file, _, err := r.FormFile("file")
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Error retrieving the file"})
-		return
-	}
-	defer file.Close()
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read CSV file"})
-		return
-	}
-	if len(records) < 2 {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "CSV file must have a header and at least one data row"})
-		return
-	}
+	h.runImport(w, r, "csvold", false)
+}
 
-	header := records[0]
+// feedOldCSV runs the rows of a CSV file exported by ExpenseOwl < v4.0
+// through the pipeline. Those releases stored spending as positive amounts.
+func feedOldCSV(pipeline *importPipeline, reader *csv.Reader, header []string, locale importer.Locale) int {
 	colMap := make(map[string]int)
 	for i, col := range header {
 		colMap[strings.ToLower(strings.TrimSpace(col))] = i
 	}
-	requiredCols := []string{"name", "category", "amount", "date"}
-	for _, col := range requiredCols {
-		if _, ok := colMap[col]; !ok {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Missing required column: %s", col)})
-			return
+	rows := 0
+	for {
+		label := fmt.Sprintf("row %d", rows+2)
+		record, ok := nextCSVRecord(pipeline, reader, label)
+		if !ok {
+			break
+		}
+		rows++
+		if record == nil {
+			continue
 		}
-	}
-
-	currentCategories, err := h.storage.GetCategories(userCtx.ID)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Could not retrieve current categories"})
-		return
-	}
-	categorySet := make(map[string]bool)
-	for _, cat := range currentCategories {
-		categorySet[strings.ToLower(cat)] = true
-	}
-	var newCategories []string
-	var importedCount, skippedCount int
-
-	for i, record := range records[1:] {
 		if len(record) != len(header) {
-			log.Printf("Warning: Skipping row %d due to incorrect column count\n", i+2)
-			skippedCount++
+			pipeline.fail(label, nil, fmt.Errorf("incorrect column count"), 1)
 			continue
 		}
-		amount, err := strconv.ParseFloat(record[colMap["amount"]], 64)
+		amount, err := locale.ParseAmount(record[colMap["amount"]])
 		if err != nil {
-			log.Printf("Warning: Skipping row %d due to invalid amount: %s\n", i+2, record[colMap["amount"]])
-			skippedCount++
+			pipeline.fail(label, nil, err, 1)
 			continue
 		}
-		date, err := parseDate(record[colMap["date"]])
+		date, err := locale.ParseDate(record[colMap["date"]])
 		if err != nil {
-			log.Printf("Warning: Skipping row %d due to invalid date: %v\n", i+2, err)
-			skippedCount++
+			pipeline.fail(label, nil, fmt.Errorf("invalid date: %v", err), 1)
 			continue
 		}
 		category := strings.TrimSpace(record[colMap["category"]])
-		if _, ok := categorySet[strings.ToLower(category)]; !ok {
-			newCategories = append(newCategories, category)
-			categorySet[strings.ToLower(category)] = true // Add to set to handle duplicates in the same file
-		}
 
 		// switches sign for new expenseowl
 		amountUpdated := amount
 		if category != "Income" {
 			amountUpdated = amount * -1
 		}
-		expense := storage.Expense{
+		pipeline.add(storage.Expense{
 			Name:     strings.TrimSpace(record[colMap["name"]]),
 			Category: category,
 			Amount:   amountUpdated,
 			Date:     date,
-		}
-		if err := expense.Validate(); err != nil {
-			log.Printf("Warning: Skipping row %d due to validation error: %v\n", i+2, err)
-			skippedCount++
-			continue
-		}
-		if err := ensureExpenseBlob(manager, &expense); err != nil {
-			log.Printf("Warning: Skipping row %d due to encryption error: %v\n", i+2, err)
-			skippedCount++
-			continue
-		}
-		if err := h.storage.AddExpense(userCtx.ID, expense); err != nil {
-			log.Printf("Error: Could not add expense from row %d: %v\n", i+2, err)
-			skippedCount++
-			continue
-		}
-		importedCount++
-		time.Sleep(10 * time.Millisecond)
-	}
-
-	if len(newCategories) > 0 {
-		if err := h.storage.UpdateCategories(userCtx.ID, append(currentCategories, newCategories...)); err != nil {
-			log.Printf("Warning: Failed to add new categories to config: %v\n", err)
-		}
+			Source:   storage.ExpenseSourceImport,
+		}, label)
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
-		"status":          "success",
-		"total_processed": len(records) - 1,
-		"imported":        importedCount,
-		"skipped":         skippedCount,
-		"new_categories":  newCategories,
-	})
-	log.Printf("HTTP: Imported %d expenses from CSV file. Skipped %d records.", importedCount, skippedCount)
+	return rows
 }
 
 func parseDate(dateStr string) (time.Time, error) {
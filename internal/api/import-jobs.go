@@ -0,0 +1,286 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxImportFileSize  = 1 << 30 // 1GB, the file is spooled to disk rather than held in memory
+	maxImportFieldSize = 1 << 10
+	importChunkSize    = 1000           // expenses per transaction of a background import
+	importJobRetention = 24 * time.Hour // finished jobs can be polled this long
+)
+
+// States of a background import.
+const (
+	importJobRunning = "running"
+	importJobDone    = "done"
+	importJobFailed  = "failed"
+)
+
+var errMissingImportFile = errors.New("no file in upload")
+
+// importUpload is an import file streamed from a multipart request into a
+// temporary file, along with the form values and URL query of the request.
+// Reading it counts the bytes consumed, which is the progress of a job.
+type importUpload struct {
+	values   url.Values
+	file     *os.File
+	size     int64
+	read     atomic.Int64
+	detached bool // owned by a background job
+}
+
+func (u *importUpload) Read(p []byte) (int, error) {
+	n, err := u.file.Read(p)
+	u.read.Add(int64(n))
+	return n, err
+}
+
+// release removes the temporary file unless a job took it over.
+func (u *importUpload) release() {
+	if !u.detached {
+		u.remove()
+	}
+}
+
+func (u *importUpload) remove() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// receiveImportUpload reads the multipart body part by part, so an import
+// is limited by maxImportFileSize only and never parsed as a whole in memory.
+// Form values may come before or after the file.
+func receiveImportUpload(w http.ResponseWriter, r *http.Request) (upload *importUpload, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	received := &importUpload{values: r.URL.Query()}
+	defer func() {
+		// upload is nil on errors, so the cleanup holds on to its own pointer
+		if err != nil && received.file != nil {
+			received.remove()
+		}
+	}()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" && received.file == nil {
+			if received.file, err = os.CreateTemp("", "expenseowl-import-*"); err != nil {
+				return nil, fmt.Errorf("failed to create temporary file: %v", err)
+			}
+			if received.size, err = io.Copy(received.file, part); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxImportFieldSize))
+		if err != nil {
+			return nil, err
+		}
+		received.values.Add(part.FormName(), string(value))
+	}
+	if received.file == nil {
+		return nil, errMissingImportFile
+	}
+	if _, err := received.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind temporary file: %v", err)
+	}
+	return received, nil
+}
+
+// writeUploadError answers a request whose import file could not be received.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("File is larger than %d MB", maxImportFileSize>>20)})
+	case errors.Is(err, errMissingImportFile):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Error retrieving the file"})
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Could not parse multipart form"})
+		log.Printf("API ERROR: Failed to receive import file: %v\n", err)
+	}
+}
+
+// ImportJob reports the progress of an import running in the background.
+// Expenses are stored in chunks under the job's batch, so a failed job can
+// be undone like any other import.
+type ImportJob struct {
+	ID         string         `json:"id"`
+	Format     string         `json:"format"`
+	Status     string         `json:"status"`   // running, done or failed
+	Size       int64          `json:"size"`     // bytes of the file
+	Read       int64          `json:"read"`     // bytes parsed so far
+	Progress   int            `json:"progress"` // percent of the file parsed
+	Imported   int            `json:"imported"` // rows stored so far
+	Skipped    int            `json:"skipped"`
+	Batch      string         `json:"batch,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Result     map[string]any `json:"result,omitempty"` // response of the import once finished
+	Issues     []ImportRow    `json:"issues,omitempty"` // rows that were skipped or invalid
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+
+	userID string
+	upload *importUpload
+}
+
+// importJobs keeps the jobs of this server process. Jobs don't survive a
+// restart; the chunks they stored remain listed at /imports.
+type importJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*ImportJob
+}
+
+func newImportJobs() *importJobs {
+	return &importJobs{jobs: make(map[string]*ImportJob)}
+}
+
+// snapshot copies a job for a response. The caller holds the lock.
+func (j *importJobs) snapshot(job *ImportJob) ImportJob {
+	copied := *job
+	if job.Status == importJobRunning {
+		copied.Read = job.upload.read.Load()
+	}
+	if copied.Size > 0 {
+		copied.Progress = int(copied.Read * 100 / copied.Size)
+	}
+	return copied
+}
+
+func (j *importJobs) add(job *ImportJob) ImportJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, old := range j.jobs {
+		if old.FinishedAt != nil && time.Since(*old.FinishedAt) > importJobRetention {
+			delete(j.jobs, id)
+		}
+	}
+	j.jobs[job.ID] = job
+	return j.snapshot(job)
+}
+
+func (j *importJobs) get(userID, id string) (ImportJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok || job.userID != userID {
+		return ImportJob{}, false
+	}
+	return j.snapshot(job), true
+}
+
+func (j *importJobs) update(id string, change func(job *ImportJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if job, ok := j.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// startImportJob feeds the upload to the pipeline in the background, storing
+// the expenses in chunks of importChunkSize.
+func (h *Handler) startImportJob(userID, format string, upload *importUpload, pipeline *importPipeline, feed func(*importPipeline) int) ImportJob {
+	job := &ImportJob{
+		ID:        uuid.New().String(),
+		Format:    format,
+		Status:    importJobRunning,
+		Size:      upload.size,
+		Batch:     pipeline.batchID,
+		StartedAt: time.Now(),
+		userID:    userID,
+		upload:    upload,
+	}
+	upload.detached = true
+	pipeline.chunkSize = importChunkSize
+	pipeline.progress = func(imported, skipped int) {
+		h.imports.update(job.ID, func(job *ImportJob) {
+			job.Imported, job.Skipped = imported, skipped
+		})
+	}
+	started := h.imports.add(job)
+
+	go func() {
+		defer upload.remove()
+		total := feed(pipeline)
+		err := pipeline.err
+		if err == nil {
+			err = pipeline.store()
+		}
+		// categories, statements and checks cover what was stored even if a chunk failed
+		response := pipeline.finish()
+		response["total_processed"] = total
+		var issues []ImportRow
+		for _, row := range pipeline.rows {
+			if row.Status == importRowSkipped || row.Status == importRowInvalid {
+				issues = append(issues, row)
+			}
+		}
+		h.imports.update(job.ID, func(job *ImportJob) {
+			now := time.Now()
+			job.Status = importJobDone
+			if err != nil {
+				job.Status = importJobFailed
+				job.Error = "Failed to store imported expenses, the rows stored before can be undone with the batch"
+			}
+			job.Read = upload.read.Load()
+			job.Imported, job.Skipped = pipeline.imported, pipeline.skipped
+			if len(pipeline.importedIDs) == 0 {
+				job.Batch = "" // nothing to undo
+			}
+			job.Result = response
+			job.Issues = issues
+			job.FinishedAt = &now
+		})
+		if err != nil {
+			log.Printf("API ERROR: Import job %s failed: %v\n", job.ID, err)
+			return
+		}
+		log.Printf("HTTP: Import job %s imported %d expenses from %s file. Skipped %d records.", job.ID, pipeline.imported, format, pipeline.skipped)
+	}()
+	return started
+}
+
+// GetImportJob reports the progress of a background import (?id=).
+func (h *Handler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	job, ok := h.imports.get(userCtx.ID, id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("import job with ID %s not found", id)})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/tanq16/expenseowl/internal/importer"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+func (h *Handler) GetImportLocale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	locale, err := h.storage.GetImportLocale(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get import locale"})
+		log.Printf("API ERROR: Failed to get import locale: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, locale)
+}
+
+func (h *Handler) UpdateImportLocale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var locale storage.ImportLocale
+	if err := json.NewDecoder(r.Body).Decode(&locale); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := locale.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.UpdateImportLocale(userCtx.ID, locale); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update import locale"})
+		log.Printf("API ERROR: Failed to update import locale: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// importLocale resolves how an import file writes numbers and dates: the
+// user's setting, overridden by the locale, decimalSeparator and dateOrder
+// form values of the upload.
+func (h *Handler) importLocale(userID string, values url.Values) (importer.Locale, error) {
+	setting, err := h.storage.GetImportLocale(userID)
+	if err != nil {
+		log.Printf("Error: Could not retrieve import locale: %v\n", err)
+		return importer.Locale{}, fmt.Errorf("could not retrieve import locale")
+	}
+	override := storage.ImportLocale{
+		Locale:           values.Get("locale"),
+		DecimalSeparator: values.Get("decimalSeparator"),
+		DateOrder:        values.Get("dateOrder"),
+	}
+	if err := override.Validate(); err != nil {
+		return importer.Locale{}, err
+	}
+	return setting.Override(override).Resolve(), nil
+}
@@ -0,0 +1,502 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/importer"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// Categories given to bank rows that carry none, before payees and rules.
+const (
+	importIncomeCategory  = "Income"
+	importExpenseCategory = "Miscellaneous"
+)
+
+// Outcomes of an import row.
+const (
+	importRowReady    = "ready"    // passed every check, stored unless previewing
+	importRowImported = "imported" // stored
+	importRowSkipped  = "skipped"  // closed period, already imported or duplicate under the skip policy
+	importRowInvalid  = "invalid"  // could not be parsed or failed validation
+)
+
+// ImportRow reports what happened to one expense of an import file. Expense
+// holds the normalized values once the row could be parsed.
+type ImportRow struct {
+	Index     int              `json:"index"`
+	Label     string           `json:"label"` // e.g. "row 4"
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Warnings  []string         `json:"warnings,omitempty"`
+	Expense   *storage.Expense `json:"expense,omitempty"`
+	Statement *int             `json:"statement,omitempty"` // index into the statements of the import
+}
+
+// importPipeline takes parsed rows of any import format through the steps
+// all imports share: closed periods, already imported bank transactions,
+// payee mapping, categorization rules, validation, duplicate screening and
+// encryption. The expenses are stored together as one import batch by store,
+// or chunk by chunk under the same batch when chunkSize is set. A dry run
+// performs every check but stores nothing.
+type importPipeline struct {
+	h         *Handler
+	userID    string
+	manager   *encryption.Manager
+	currency  string
+	format    string
+	batchID   string
+	dryRun    bool
+	chunkSize int                         // expenses per transaction, 0 stores the whole file at once
+	progress  func(imported, skipped int) // called after each stored chunk
+	previewID string                      // preview being committed, deleted together with the stored expenses
+
+	closedPeriods   []storage.ClosedPeriod
+	payees          []storage.Payee
+	rules           []storage.CategorizationRule
+	duplicates      *storage.DuplicateIndex
+	duplicatePolicy storage.DuplicatePolicy
+	externalRefs    map[string]string
+
+	categories    []string
+	categorySet   map[string]bool
+	newCategories []string
+
+	rows       []ImportRow
+	statements []storage.Statement
+	statement  int // statement the current rows belong to, -1 for none
+
+	imported       int
+	skipped        int
+	duplicateCount int
+	duplicateCheck string // why duplicates weren't screened, empty when they were
+	importedIDs    []string
+	pending        []storage.Expense // encrypted expenses waiting for store
+	storedRows     int               // rows already marked by store
+	storedImported int               // imported rows already stored
+	err            error             // a chunk failed to store, the import is aborted
+}
+
+func (h *Handler) newImportPipeline(userID, format string, manager *encryption.Manager) (*importPipeline, error) {
+	p := &importPipeline{
+		h: h, userID: userID, manager: manager, format: format, batchID: uuid.New().String(),
+		categorySet: make(map[string]bool), statement: -1,
+	}
+	var err error
+	if p.categories, err = h.storage.GetCategories(userID); err != nil {
+		return nil, fmt.Errorf("could not retrieve current categories")
+	}
+	for _, cat := range p.categories {
+		p.categorySet[strings.ToLower(cat)] = true
+	}
+	// TODO: might be worth setting default currency when we have currency updation behavior
+	if p.currency, err = h.storage.GetCurrency(userID); err != nil {
+		log.Printf("Error: Could not retrieve currency, shutting down import: %v\n", err)
+		return nil, fmt.Errorf("could not retrieve currency")
+	}
+	if p.closedPeriods, err = h.storage.GetClosedPeriods(userID); err != nil {
+		return nil, fmt.Errorf("could not retrieve closed periods")
+	}
+	if p.rules, err = h.storage.GetCategorizationRules(userID); err != nil {
+		return nil, fmt.Errorf("could not retrieve categorization rules")
+	}
+	if p.payees, err = h.storage.GetPayees(userID); err != nil {
+		return nil, fmt.Errorf("could not retrieve payees")
+	}
+	if p.externalRefs, err = h.storage.GetExternalRefs(userID); err != nil {
+		return nil, fmt.Errorf("could not retrieve imported transactions")
+	}
+	if p.duplicates, p.duplicatePolicy, err = h.duplicateIndex(userID, manager); err != nil {
+		log.Printf("Warning: Importing without duplicate check: %v\n", err)
+		// bank rows are still matched by their reference in admit
+		p.duplicateCheck = "skipped, the ledger can't be read without the encryption key"
+	}
+	return p, nil
+}
+
+// add runs a single parsed row through the pipeline. label names the row in
+// log messages and the row report, e.g. "row 4".
+func (p *importPipeline) add(expense storage.Expense, label string) bool {
+	return p.addRows(expense, label, 1)
+}
+
+// addRows is add for an expense that stands for rows lines of the file, such
+// as a split expense grouped from its line items.
+func (p *importPipeline) addRows(expense storage.Expense, label string, rows int) bool {
+	if p.err != nil {
+		return false
+	}
+	if !p.admit(expense, label, rows) {
+		return false
+	}
+	if expense.Currency == "" {
+		expense.Currency = p.currency
+	}
+	if expense.Source == "" {
+		expense.Source = storage.ExpenseSourceImport
+	}
+	storage.AssignPayee(p.payees, &expense)
+	storage.ApplyRules(p.rules, &expense)
+	if err := expense.Validate(); err != nil {
+		p.fail(label, &expense, fmt.Errorf("validation error: %v", err), rows)
+		return false
+	}
+	var warnings []string
+	if p.duplicates != nil {
+		if match, found := p.duplicates.Find(expense); found {
+			p.duplicateCount++
+			if p.duplicatePolicy.Action == storage.DuplicateActionSkip {
+				p.skip(label, &expense, fmt.Sprintf("it looks like a duplicate of expense '%s'", match.ID), rows)
+				return false
+			}
+			expense.DuplicateOf = match.ID
+			warnings = append(warnings, fmt.Sprintf("possible duplicate of expense '%s'", match.ID))
+		}
+	}
+	return p.save(expense, label, rows, warnings...)
+}
+
+// admit checks what may change between a preview and its commit: closed
+// periods and bank transactions imported in the meantime.
+func (p *importPipeline) admit(expense storage.Expense, label string, rows int) bool {
+	if _, closed := storage.ClosedPeriodFor(p.closedPeriods, expense.Date); closed {
+		p.skip(label, &expense, "its date falls in a closed period", rows)
+		return false
+	}
+	if expense.ExternalRef != "" {
+		if id, ok := p.externalRefs[expense.ExternalRef]; ok {
+			p.skip(label, &expense, fmt.Sprintf("it was already imported as expense '%s'", id), rows)
+			return false
+		}
+	}
+	return true
+}
+
+// save encrypts an already validated expense that stands for rows lines of
+// the file and queues it for store.
+func (p *importPipeline) save(expense storage.Expense, label string, rows int, warnings ...string) bool {
+	if p.err != nil {
+		return false
+	}
+	if expense.ID == "" {
+		expense.ID = uuid.New().String()
+	}
+	if !p.dryRun {
+		expense.ImportBatchID = p.batchID
+		stored := expense
+		if err := ensureExpenseBlob(p.manager, &stored); err != nil {
+			p.fail(label, &expense, fmt.Errorf("encryption error: %v", err), rows)
+			return false
+		}
+		p.pending = append(p.pending, stored)
+	}
+	if expense.ExternalRef != "" {
+		p.externalRefs[expense.ExternalRef] = expense.ID
+	}
+	p.addCategory(expense.Category)
+	for _, split := range expense.Splits {
+		p.addCategory(split.Category)
+	}
+	p.imported += rows
+	p.record(label, &expense, importRowReady, "", warnings)
+	if p.chunkSize > 0 && len(p.pending) >= p.chunkSize {
+		p.err = p.store()
+	}
+	return true
+}
+
+// store writes the queued expenses in one transaction as an import batch
+// that can be undone. Nothing is stored when it fails.
+func (p *importPipeline) store() error {
+	if p.dryRun || len(p.pending) == 0 {
+		return nil
+	}
+	batch := storage.ImportBatch{ID: p.batchID, Format: p.format}
+	var err error
+	if p.previewID != "" {
+		_, err = p.h.storage.CommitImportPreview(p.userID, p.previewID, batch, p.pending)
+	} else {
+		_, err = p.h.storage.AddImportBatch(p.userID, batch, p.pending)
+	}
+	if err != nil {
+		for i := p.storedRows; i < len(p.rows); i++ {
+			if p.rows[i].Status == importRowReady {
+				p.rows[i].Status = importRowInvalid
+				p.rows[i].Error = "could not store expense"
+			}
+		}
+		p.storedRows = len(p.rows)
+		p.skipped += p.imported - p.storedImported
+		p.imported = p.storedImported
+		p.pending = nil
+		return err
+	}
+	for i := p.storedRows; i < len(p.rows); i++ {
+		if p.rows[i].Status == importRowReady {
+			p.rows[i].Status = importRowImported
+			if p.chunkSize > 0 {
+				p.rows[i].Expense = nil // long imports don't keep what is stored
+			}
+		}
+	}
+	p.storedRows = len(p.rows)
+	p.storedImported = p.imported
+	for _, expense := range p.pending {
+		p.importedIDs = append(p.importedIDs, expense.ID)
+	}
+	p.pending = nil
+	if p.progress != nil {
+		p.progress(p.imported, p.skipped)
+	}
+	return nil
+}
+
+// skip records a row that is left out on purpose.
+func (p *importPipeline) skip(label string, expense *storage.Expense, reason string, rows int) {
+	log.Printf("Info: Skipping %s because %s\n", label, reason)
+	p.record(label, expense, importRowSkipped, reason, nil)
+	p.skipped += rows
+}
+
+// fail records a row that can't be imported. expense is nil when the row
+// couldn't be parsed.
+func (p *importPipeline) fail(label string, expense *storage.Expense, err error, rows int) {
+	log.Printf("Warning: Skipping %s: %v\n", label, err)
+	p.record(label, expense, importRowInvalid, err.Error(), nil)
+	p.skipped += rows
+}
+
+func (p *importPipeline) record(label string, expense *storage.Expense, status, message string, warnings []string) {
+	row := ImportRow{Index: len(p.rows), Label: label, Status: status, Error: message, Warnings: warnings}
+	if expense != nil {
+		copied := *expense
+		copied.Blob = ""
+		row.Expense = &copied
+	}
+	if p.statement >= 0 {
+		statement := p.statement
+		row.Statement = &statement
+	}
+	p.rows = append(p.rows, row)
+}
+
+func (p *importPipeline) addCategory(category string) {
+	if _, ok := p.categorySet[strings.ToLower(category)]; !ok {
+		p.newCategories = append(p.newCategories, category)
+		p.categorySet[strings.ToLower(category)] = true // Add to set to handle duplicates in the same file
+	}
+}
+
+// beginStatement makes the following rows belong to a bank statement, which
+// is recorded once the import finishes.
+func (p *importPipeline) beginStatement(statement storage.Statement) {
+	p.statement = len(p.statements)
+	p.statements = append(p.statements, statement)
+}
+
+// finish stores new categories and the statements of the file, starts the
+// background checks on the new expenses and returns the response fields
+// common to all imports.
+func (p *importPipeline) finish() map[string]any {
+	if len(p.newCategories) > 0 {
+		if err := p.h.storage.UpdateCategories(p.userID, append(p.categories, p.newCategories...)); err != nil {
+			log.Printf("Warning: Failed to add new categories to config: %v\n", err)
+		}
+	}
+	recorded := []storage.Statement{}
+	for i, statement := range p.statements {
+		statement.Imported = 0
+		for _, row := range p.rows {
+			if row.Status == importRowImported && row.Statement != nil && *row.Statement == i {
+				statement.Imported++
+			}
+		}
+		saved, err := p.h.storage.AddStatement(p.userID, statement)
+		if err != nil {
+			log.Printf("Warning: Failed to record statement for account %s: %v\n", statement.Account, err)
+			continue
+		}
+		recorded = append(recorded, saved)
+	}
+	p.h.checkNewExpenses(p.userID, p.manager, p.importedIDs...)
+	suggestions, _, err := p.h.subscriptionSuggestions(p.userID, p.manager)
+	if err != nil {
+		log.Printf("Warning: Failed to detect subscriptions: %v\n", err)
+	}
+	response := map[string]any{
+		"status":                   "success",
+		"imported":                 p.imported,
+		"skipped":                  p.skipped,
+		"new_categories":           p.newCategories,
+		"duplicates":               p.duplicateCount,
+		"subscription_suggestions": suggestions,
+	}
+	if p.duplicateCheck != "" {
+		response["duplicate_check"] = p.duplicateCheck
+	}
+	if len(p.importedIDs) > 0 {
+		response["batch"] = p.batchID
+	}
+	if len(p.statements) > 0 {
+		response["statements"] = recorded
+	}
+	return response
+}
+
+// externalRef builds the idempotency key of a bank transaction. The bank's
+// transaction ID is only unique per account, so the key covers both; hashing
+// them keeps the key short whatever the bank's IDs look like.
+func externalRef(source, account, transactionID string) string {
+	sum := sha256.Sum256([]byte(account + "|" + transactionID))
+	return source + ":" + hex.EncodeToString(sum[:16])
+}
+
+// defaultImportCategory picks the category for a bank row without one.
+func defaultImportCategory(amount float64) string {
+	if amount > 0 {
+		return importIncomeCategory
+	}
+	return importExpenseCategory
+}
+
+// runImport handles the upload of an import file (multipart field "file") in
+// the given format, or in the format named by the form value when format is
+// empty. A dry run answers with a preview instead of storing the rows. With
+// async=true the file is imported in chunks by a background job whose
+// progress is polled at /import/job.
+func (h *Handler) runImport(w http.ResponseWriter, r *http.Request, format string, dryRun bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	upload, err := receiveImportUpload(w, r)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer upload.release()
+	if format == "" {
+		format = strings.ToLower(strings.TrimSpace(upload.values.Get("format")))
+	}
+	feed, err := h.importFeed(upload.values, userCtx.ID, format, upload)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	pipeline, err := h.newImportPipeline(userCtx.ID, format, manager)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !dryRun && upload.values.Get("async") == "true" {
+		job := h.startImportJob(userCtx.ID, format, upload, pipeline, feed)
+		writeJSON(w, http.StatusAccepted, job)
+		log.Printf("HTTP: Started %s import job %s for %d bytes.", format, job.ID, upload.size)
+		return
+	}
+	pipeline.dryRun = dryRun
+	total := feed(pipeline)
+	if dryRun {
+		h.storePreview(w, pipeline, format, total)
+		return
+	}
+	if err := pipeline.store(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to store imported expenses, nothing was imported"})
+		log.Printf("API ERROR: Failed to store %s import: %v\n", format, err)
+		return
+	}
+	response := pipeline.finish()
+	response["total_processed"] = total
+	writeJSON(w, http.StatusOK, response)
+	log.Printf("HTTP: Imported %d expenses from %s file. Skipped %d records.", pipeline.imported, format, pipeline.skipped)
+}
+
+// importFeed parses an import file and returns the function that feeds its
+// rows to a pipeline, along with the number of rows processed. CSV files are
+// only read up to their header here, their rows are read while feeding.
+func (h *Handler) importFeed(values url.Values, userID, format string, file io.Reader) (func(*importPipeline) int, error) {
+	locale, err := h.importLocale(userID, values)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "csv":
+		if profileID := values.Get("profile"); profileID != "" {
+			profile, err := h.storage.GetImportProfile(userID, profileID)
+			if err != nil {
+				return nil, err
+			}
+			currency, err := h.storage.GetCurrency(userID)
+			if err != nil {
+				return nil, fmt.Errorf("could not retrieve currency")
+			}
+			rows, err := readProfileCSV(profile, file, currency, locale)
+			if err != nil {
+				return nil, err
+			}
+			return func(p *importPipeline) int { return feedProfileCSV(p, rows) }, nil
+		}
+		reader, header, err := readExpenseCSV(file)
+		if err != nil {
+			return nil, err
+		}
+		return func(p *importPipeline) int { return h.feedCSV(p, reader, header, locale) }, nil
+	case "csvold":
+		reader, header, err := readExpenseCSV(file)
+		if err != nil {
+			return nil, err
+		}
+		return func(p *importPipeline) int { return feedOldCSV(p, reader, header, locale) }, nil
+	case "qif":
+		// The user's locale is not applied: QIF dates follow the program that
+		// wrote the file, and their order is detected unless given here.
+		dateOrder := strings.ToLower(strings.TrimSpace(values.Get("dateFormat")))
+		if dateOrder == "" {
+			dateOrder = strings.ToLower(strings.TrimSpace(values.Get("dateOrder")))
+		}
+		if dateOrder != "" && dateOrder != importer.QIFMonthFirst && dateOrder != importer.QIFDayFirst {
+			return nil, fmt.Errorf("dateFormat must be mdy or dmy")
+		}
+		accounts, err := importer.ParseQIF(file, dateOrder)
+		if err != nil {
+			return nil, err
+		}
+		return func(p *importPipeline) int { return feedQIF(p, accounts) }, nil
+	case "ofx", "camt053", "mt940":
+		parse := map[string]func(io.Reader) ([]importer.Statement, error){
+			"ofx":     importer.ParseOFX,
+			"camt053": importer.ParseCAMT053,
+			"mt940":   importer.ParseMT940,
+		}[format]
+		statements, err := parse(file)
+		if err != nil {
+			return nil, err
+		}
+		return func(p *importPipeline) int { return feedStatements(p, format, statements) }, nil
+	}
+	return nil, fmt.Errorf("unsupported import format %q, use csv, csvold, qif, ofx, camt053 or mt940", format)
+}
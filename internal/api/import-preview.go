@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// previewPayload is the content of a stored import preview.
+type previewPayload struct {
+	Rows       []ImportRow         `json:"rows"`
+	Statements []storage.Statement `json:"statements,omitempty"`
+}
+
+// PreviewImport checks an import file without storing anything. It takes the
+// same form values as the import endpoints plus "format" (csv, qif, ofx,
+// camt053 or mt940) and returns every row with its normalized values,
+// errors and duplicate warnings. The preview can be committed for a day.
+func (h *Handler) PreviewImport(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "", true)
+}
+
+// storePreview saves the checked rows of a dry run and answers with the
+// preview.
+func (h *Handler) storePreview(w http.ResponseWriter, pipeline *importPipeline, format string, total int) {
+	payload := previewPayload{Rows: pipeline.rows, Statements: pipeline.statements}
+	blob, err := encryptPreview(pipeline.manager, payload)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to store import preview"})
+		log.Printf("API ERROR: Failed to store import preview: %v\n", err)
+		return
+	}
+	preview, err := h.storage.AddImportPreview(pipeline.userID, storage.ImportPreview{Format: format, Blob: blob})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to store import preview"})
+		log.Printf("API ERROR: Failed to store import preview: %v\n", err)
+		return
+	}
+	rows := pipeline.rows
+	if rows == nil {
+		rows = []ImportRow{}
+	}
+	response := map[string]any{
+		"id":              preview.ID,
+		"format":          format,
+		"expiresAt":       preview.CreatedAt.Add(storage.ImportPreviewTTL),
+		"total_processed": total,
+		"ready":           pipeline.imported,
+		"skipped":         pipeline.skipped,
+		"duplicates":      pipeline.duplicateCount,
+		"new_categories":  pipeline.newCategories,
+		"statements":      pipeline.statements,
+		"rows":            rows,
+	}
+	if pipeline.duplicateCheck != "" {
+		response["duplicate_check"] = pipeline.duplicateCheck
+	}
+	writeJSON(w, http.StatusOK, response)
+	log.Printf("HTTP: Previewed %s import, %d of %d records ready.", format, pipeline.imported, total)
+}
+
+// commitRequest selects the previewed rows to import. Rows listed in
+// exclude (by index) are left out.
+type commitRequest struct {
+	ID      string `json:"id"`
+	Exclude []int  `json:"exclude"`
+}
+
+// CommitImport stores the ready rows of a preview exactly as they were
+// previewed. Closed periods and bank transactions imported since the preview
+// are checked again.
+func (h *Handler) CommitImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var req commitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	preview, err := h.storage.GetImportPreview(userCtx.ID, req.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve import preview"})
+		log.Printf("API ERROR: Failed to retrieve import preview: %v\n", err)
+		return
+	}
+	payload, err := decryptPreview(manager, preview.Blob)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	pipeline, err := h.newImportPipeline(userCtx.ID, preview.Format, manager)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// the preview is deleted in the transaction storing its expenses, so a
+	// second commit of the same preview can't import twice
+	pipeline.previewID = preview.ID
+	pipeline.statements = payload.Statements
+	for _, row := range payload.Rows {
+		if row.Status != importRowReady || row.Expense == nil {
+			continue
+		}
+		pipeline.statement = -1
+		if row.Statement != nil {
+			pipeline.statement = *row.Statement
+		}
+		if slices.Contains(req.Exclude, row.Index) {
+			pipeline.skip(row.Label, row.Expense, "it was excluded from the commit", 1)
+			continue
+		}
+		if !pipeline.admit(*row.Expense, row.Label, 1) {
+			continue
+		}
+		if row.Expense.DuplicateOf != "" {
+			pipeline.duplicateCount++
+		}
+		pipeline.save(*row.Expense, row.Label, 1, row.Warnings...)
+	}
+	if err := pipeline.store(); err != nil {
+		if strings.Contains(err.Error(), "import preview with ID") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to store imported expenses, nothing was imported"})
+		log.Printf("API ERROR: Failed to store %s import preview: %v\n", preview.Format, err)
+		return
+	}
+	// with nothing left to store the preview is removed on its own
+	if len(pipeline.importedIDs) == 0 {
+		if err := h.storage.RemoveImportPreview(userCtx.ID, preview.ID); err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to commit import preview"})
+			log.Printf("API ERROR: Failed to remove import preview: %v\n", err)
+			return
+		}
+	}
+	response := pipeline.finish()
+	response["total_processed"] = len(payload.Rows)
+	writeJSON(w, http.StatusOK, response)
+	log.Printf("HTTP: Committed %s import preview. Imported %d expenses, skipped %d records.", preview.Format, pipeline.imported, pipeline.skipped)
+}
+
+func encryptPreview(manager *encryption.Manager, payload previewPayload) (string, error) {
+	if manager != nil {
+		blob, err := manager.Encrypt(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt import preview: %w", err)
+		}
+		return blob, nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize import preview: %w", err)
+	}
+	return string(raw), nil
+}
+
+func decryptPreview(manager *encryption.Manager, blob string) (previewPayload, error) {
+	var payload previewPayload
+	if err := json.Unmarshal([]byte(blob), &payload); err == nil {
+		return payload, nil
+	}
+	if manager == nil {
+		return payload, fmt.Errorf("encrypted import preview provided without %s header", encryptionHeader)
+	}
+	if err := manager.Decrypt(blob, &payload); err != nil {
+		return payload, fmt.Errorf("failed to decrypt import preview: %w", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tanq16/expenseowl/internal/importer"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+func (h *Handler) GetImportProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	profiles, err := h.storage.GetImportProfiles(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve import profiles"})
+		log.Printf("API ERROR: Failed to retrieve import profiles: %v\n", err)
+		return
+	}
+	if profiles == nil {
+		profiles = []storage.ImportProfile{}
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+func (h *Handler) AddImportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var profile storage.ImportProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := profile.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	created, err := h.storage.AddImportProfile(userCtx.ID, profile)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to add import profile"})
+		log.Printf("API ERROR: Failed to add import profile: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) EditImportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	var profile storage.ImportProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := profile.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.UpdateImportProfile(userCtx.ID, id, profile); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update import profile"})
+		log.Printf("API ERROR: Failed to update import profile: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) DeleteImportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveImportProfile(userCtx.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete import profile"})
+		log.Printf("API ERROR: Failed to delete import profile: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// profileRow is a data row of a bank CSV read through an import profile.
+// err is set when the row can't become an expense.
+type profileRow struct {
+	line    int
+	expense storage.Expense
+	err     error
+}
+
+// profileCSV reads the rows of a bank export one by one and maps them onto
+// expenses using the layout of an import profile.
+type profileCSV struct {
+	profile  storage.ImportProfile
+	reader   *csv.Reader
+	index    map[string]int // expense field -> column of the file
+	currency string
+	locale   importer.Locale // for dates when the profile has no format
+	line     int
+}
+
+// readProfileCSV reads the header of a bank export. An error is returned
+// only when the file as a whole can't be read, e.g. when a mapped column is
+// missing from the header.
+func readProfileCSV(profile storage.ImportProfile, file io.Reader, currency string, locale importer.Locale) (*profileCSV, error) {
+	buffered := bufio.NewReader(file)
+	for i := 0; i < profile.SkipRows; i++ {
+		if _, err := buffered.ReadString('\n'); err != nil {
+			return nil, fmt.Errorf("file has fewer than %d lines to skip", profile.SkipRows)
+		}
+	}
+	reader := csv.NewReader(buffered)
+	reader.Comma, _ = utf8.DecodeRuneInString(profile.Delimiter)
+	reader.FieldsPerRecord = -1 // trailing summary lines often differ
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("CSV file must have a header and at least one data row")
+	}
+
+	colMap := make(map[string]int)
+	for i, col := range header {
+		col = strings.TrimPrefix(col, "\ufeff")
+		colMap[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	c := profile.Columns
+	index := make(map[string]int)
+	for field, name := range map[string]string{
+		"name": c.Name, "date": c.Date, "amount": c.Amount, "debit": c.Debit, "credit": c.Credit,
+		"category": c.Category, "tags": c.Tags, "note": c.Note, "currency": c.Currency, "account": c.Account,
+	} {
+		if name == "" {
+			continue
+		}
+		idx, ok := colMap[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("missing mapped column: %s", name)
+		}
+		index[field] = idx
+	}
+	return &profileCSV{profile: profile, reader: reader, index: index, currency: currency, locale: locale, line: profile.SkipRows + 1}, nil
+}
+
+// next reads the following data row. It returns io.EOF at the end of the
+// file and other errors when the file can't be read any further.
+func (c *profileCSV) next() (profileRow, error) {
+	record, err := c.reader.Read()
+	c.line++
+	row := profileRow{line: c.line}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			row.err = fmt.Errorf("malformed row: %v", parseErr.Err)
+			return row, nil
+		}
+		return row, err
+	}
+	cell := func(field string) string {
+		idx, ok := c.index[field]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+	profile := c.profile
+	amount, err := profile.SignedAmount(cell("amount"), cell("debit"), cell("credit"))
+	if err != nil {
+		row.err = err
+		return row, nil
+	}
+	date, ok, err := profile.ParseDate(cell("date"))
+	if !ok {
+		date, err = c.locale.ParseDate(cell("date"))
+	}
+	if err != nil {
+		row.err = err
+		return row, nil
+	}
+	expense := storage.Expense{
+		Name:     cell("name"),
+		Category: cell("category"),
+		Amount:   amount,
+		Currency: c.currency,
+		Date:     date,
+		Note:     cell("note"),
+		Account:  cell("account"),
+		Status:   storage.ExpenseStatusCleared, // bank rows are cleared
+	}
+	if expense.Category == "" {
+		expense.Category = profile.DefaultCategory
+	}
+	if expense.Category == "" {
+		expense.Category = defaultImportCategory(amount)
+	}
+	if value := strings.ToLower(cell("currency")); value != "" {
+		if !slices.Contains(storage.SupportedCurrencies, value) {
+			row.err = fmt.Errorf("invalid currency: %s", value)
+			return row, nil
+		}
+		expense.Currency = value
+	}
+	if tags := cell("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			expense.Tags = append(expense.Tags, strings.TrimSpace(tag))
+		}
+	}
+	row.expense = expense
+	return row, nil
+}
+
+// feedProfileCSV runs the rows read through an import profile through the
+// pipeline.
+func feedProfileCSV(pipeline *importPipeline, rows *profileCSV) int {
+	total := 0
+	for pipeline.err == nil {
+		row, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		label := fmt.Sprintf("row %d", row.line)
+		if err != nil {
+			pipeline.fail(label, nil, fmt.Errorf("failed to read CSV file: %v", err), 1)
+			break
+		}
+		total++
+		if row.err != nil {
+			pipeline.fail(label, nil, row.err, 1)
+			continue
+		}
+		pipeline.add(row.expense, label)
+	}
+	return total
+}
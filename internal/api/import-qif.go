@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/importer"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// Category of QIF transfers between accounts (L[Account]), which name no category.
+const qifTransferCategory = "Transfer"
+
+// ImportQIF imports the register transactions of a QIF file as written by
+// Quicken, GnuCash or Money Manager Ex. Split lines become expense splits and
+// "Parent:Child" categories are kept as they are. The optional dateFormat
+// form value (mdy or dmy) overrides the detected order of ambiguous dates.
+func (h *Handler) ImportQIF(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "qif", false)
+}
+
+func feedQIF(pipeline *importPipeline, accounts []importer.QIFAccount) int {
+	total := 0
+	for _, account := range accounts {
+		for i, txn := range account.Transactions {
+			total++
+			expense := qifExpense(txn)
+			expense.Account = account.Name
+			pipeline.add(expense, fmt.Sprintf("transaction %d of account '%s'", i+1, account.Name))
+		}
+	}
+	return total
+}
+
+// qifExpense maps a QIF transaction onto an expense. A single split line is
+// folded into the expense itself.
+func qifExpense(txn importer.QIFTransaction) storage.Expense {
+	name := txn.Payee
+	note := txn.Memo
+	if name == "" {
+		name, note = txn.Memo, ""
+	}
+	if name == "" {
+		name = txn.Transfer
+	}
+	expense := storage.Expense{
+		Name:     name,
+		Note:     note,
+		Category: qifCategory(txn.Category, txn.Transfer),
+		Amount:   txn.Amount,
+		Date:     txn.Date,
+		Tags:     txn.Tags,
+		Status:   qifStatus(txn.Status),
+	}
+	if len(txn.Splits) == 1 {
+		expense.Category = qifCategory(txn.Splits[0].Category, txn.Splits[0].Transfer)
+		expense.Tags = append(expense.Tags, txn.Splits[0].Tags...)
+	} else {
+		for _, split := range txn.Splits {
+			expense.Splits = append(expense.Splits, storage.ExpenseSplit{
+				Category: qifCategory(split.Category, split.Transfer),
+				Amount:   split.Amount,
+				Tags:     split.Tags,
+				Note:     split.Memo,
+			})
+		}
+	}
+	if expense.Category == "" && len(expense.Splits) == 0 {
+		expense.Category = defaultImportCategory(expense.Amount)
+	}
+	for i := range expense.Splits {
+		if expense.Splits[i].Category == "" {
+			expense.Splits[i].Category = defaultImportCategory(expense.Splits[i].Amount)
+		}
+	}
+	return expense
+}
+
+func qifCategory(category, transfer string) string {
+	if category == "" && transfer != "" {
+		return qifTransferCategory
+	}
+	return category
+}
+
+// qifStatus maps the QIF cleared flag onto an expense status.
+func qifStatus(flag string) string {
+	switch strings.ToUpper(flag) {
+	case "*", "C":
+		return storage.ExpenseStatusCleared
+	case "X", "R":
+		return storage.ExpenseStatusReconciled
+	}
+	return storage.ExpenseStatusUncleared
+}
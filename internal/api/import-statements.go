@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/importer"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// ImportOFX imports the transactions of an OFX or QFX bank statement.
+func (h *Handler) ImportOFX(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "ofx", false)
+}
+
+// ImportCAMT053 imports an ISO 20022 camt.053 statement as exported by
+// European banks.
+func (h *Handler) ImportCAMT053(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "camt053", false)
+}
+
+// ImportMT940 imports a SWIFT MT940 statement.
+func (h *Handler) ImportMT940(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "mt940", false)
+}
+
+// feedStatements runs the transactions of bank statements through the
+// pipeline. The bank's reference for each transaction makes re-importing the
+// same download a no-op, and each statement's account, currency and ledger
+// balance are recorded.
+func feedStatements(pipeline *importPipeline, source string, statements []importer.Statement) int {
+	total := 0
+	for _, statement := range statements {
+		currency := statement.Currency
+		if !slices.Contains(storage.SupportedCurrencies, currency) {
+			currency = pipeline.currency
+		}
+		pipeline.beginStatement(storage.Statement{
+			Source:        source,
+			Account:       statement.Account,
+			Currency:      currency,
+			LedgerBalance: statement.LedgerBalance,
+			BalanceDate:   statement.BalanceDate,
+			StartDate:     statement.Start,
+			EndDate:       statement.End,
+			Transactions:  len(statement.Transactions) + len(statement.Invalid),
+		})
+		for i, txn := range statement.Transactions {
+			total++
+			expense := statementExpense(txn, currency)
+			expense.Account = statement.Account
+			ref := txn.ID
+			if ref == "" {
+				ref = fallbackTransactionRef(txn, i)
+			}
+			expense.ExternalRef = externalRef(source, statement.BankID+"/"+statement.Account, ref)
+			pipeline.add(expense, fmt.Sprintf("transaction %d of account %s", i+1, statement.Account))
+		}
+		for _, invalid := range statement.Invalid {
+			total++
+			label := fmt.Sprintf("entry %d of account %s", invalid.Index, statement.Account)
+			if invalid.ID != "" {
+				label += " (" + invalid.ID + ")"
+			}
+			pipeline.fail(label, nil, errors.New(invalid.Error), 1)
+		}
+	}
+	return total
+}
+
+// fallbackTransactionRef stands in for the bank's reference of entries that
+// have none, such as camt.053 entries without AcctSvcrRef or MT940 NONREF
+// lines without a bank reference. It is only a fallback: it is stable across
+// downloads of the same statement, but changes when the bank corrects or
+// reorders the statement's entries.
+func fallbackTransactionRef(txn importer.Transaction, index int) string {
+	return fmt.Sprintf("fallback|%s|%.2f|%s|%s|%d", txn.Date.Format("2006-01-02"), txn.Amount, txn.Name, txn.Memo, index)
+}
+
+// statementExpense maps a bank transaction onto an expense: the counterparty
+// becomes the name and the remittance information the note.
+func statementExpense(txn importer.Transaction, currency string) storage.Expense {
+	if slices.Contains(storage.SupportedCurrencies, txn.Currency) {
+		currency = txn.Currency
+	}
+	expense := storage.Expense{
+		Name:     strings.TrimSpace(txn.Name),
+		Category: defaultImportCategory(txn.Amount),
+		Amount:   txn.Amount,
+		Currency: currency,
+		Date:     txn.Date,
+		Note:     strings.TrimSpace(txn.Memo),
+		Status:   storage.ExpenseStatusCleared, // bank rows are cleared
+	}
+	if expense.Name == "" {
+		expense.Name, expense.Note = expense.Note, ""
+	}
+	return expense
+}
+
+// GetStatements lists the bank statements recorded by imports.
+func (h *Handler) GetStatements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	statements, err := h.storage.GetStatements(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve statements"})
+		log.Printf("API ERROR: Failed to retrieve statements: %v\n", err)
+		return
+	}
+	if statements == nil {
+		statements = []storage.Statement{}
+	}
+	writeJSON(w, http.StatusOK, statements)
+}
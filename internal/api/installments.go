@@ -0,0 +1,47 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// GetInstallments lists installment plans with their payment progress.
+func (h *Handler) GetInstallments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	plans, err := h.storage.GetRecurringExpenses(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get recurring expenses"})
+		log.Printf("API ERROR: Failed to get recurring expenses: %v\n", err)
+		return
+	}
+	now := time.Now()
+	statuses := []storage.InstallmentStatus{}
+	for _, plan := range plans {
+		if !plan.IsInstallment() {
+			continue
+		}
+		if manager != nil {
+			if err := decryptRecurring(manager, &plan); err != nil {
+				log.Printf("API ERROR: Failed to decrypt recurring expense %s: %v\n", plan.ID, err)
+			}
+		}
+		statuses = append(statuses, storage.InstallmentStatusAt(plan, now))
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/reports"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+func (h *Handler) GetPayees(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	payees, err := h.storage.GetPayees(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve payees"})
+		log.Printf("API ERROR: Failed to retrieve payees: %v\n", err)
+		return
+	}
+	if payees == nil {
+		payees = []storage.Payee{}
+	}
+	writeJSON(w, http.StatusOK, payees)
+}
+
+func (h *Handler) AddPayee(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var payee storage.Payee
+	if err := json.NewDecoder(r.Body).Decode(&payee); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := payee.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	created, err := h.storage.AddPayee(userCtx.ID, payee)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to add payee"})
+		log.Printf("API ERROR: Failed to add payee: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// EditPayee updates a payee. When the name changes, the expenses mapped to
+// the payee are renamed too so history shows the new name. Reconciled
+// expenses and those in closed periods keep their name; they are reported as
+// skipped with a 207 status.
+func (h *Handler) EditPayee(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	var payee storage.Payee
+	if err := json.NewDecoder(r.Body).Decode(&payee); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := payee.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	existing, err := h.storage.GetPayee(userCtx.ID, id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// Decrypt before changing anything so a missing key can't leave the
+	// payee renamed but its history untouched.
+	var history []storage.Expense
+	if existing.Name != payee.Name {
+		expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		for _, expense := range expenses {
+			if expense.PayeeID == id && expense.Name != payee.Name {
+				history = append(history, expense)
+			}
+		}
+	}
+	renamed, skipped, err := h.renamableExpenses(userCtx.ID, history)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to check reconciliation locks and closed periods"})
+		log.Printf("API ERROR: Failed to check payee history: %v\n", err)
+		return
+	}
+	for i := range renamed {
+		renamed[i].Name = payee.Name
+		renamed[i].Blob = ""
+		if err := ensureExpenseBlob(manager, &renamed[i]); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	if err := h.storage.UpdatePayee(userCtx.ID, id, payee, renamed); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update payee"})
+		log.Printf("API ERROR: Failed to update payee: %v\n", err)
+		return
+	}
+	if len(skipped) > 0 {
+		writeJSON(w, http.StatusMultiStatus, map[string]any{
+			"status":  "partial",
+			"renamed": len(renamed),
+			"skipped": skipped,
+			"error":   "reconciled expenses and expenses in closed periods keep their name",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "success", "renamed": len(renamed)})
+}
+
+// renamableExpenses splits a payee's history into the expenses that may be
+// renamed and the IDs of those that are reconciled or in a closed period.
+func (h *Handler) renamableExpenses(userID string, history []storage.Expense) ([]storage.Expense, []string, error) {
+	if len(history) == 0 {
+		return nil, nil, nil
+	}
+	ids := make([]string, len(history))
+	for i, expense := range history {
+		ids[i] = expense.ID
+	}
+	locked, err := h.storage.GetLockedExpenseIDs(userID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	periods, err := h.storage.GetClosedPeriods(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var renamable []storage.Expense
+	skipped := []string{}
+	for _, expense := range history {
+		if _, closed := storage.ClosedPeriodFor(periods, expense.Date); closed || slices.Contains(locked, expense.ID) {
+			skipped = append(skipped, expense.ID)
+			continue
+		}
+		renamable = append(renamable, expense)
+	}
+	return renamable, skipped, nil
+}
+
+func (h *Handler) DeletePayee(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemovePayee(userCtx.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete payee"})
+		log.Printf("API ERROR: Failed to delete payee: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// PayeeReport returns totals per payee for an optional date range.
+func (h *Handler) PayeeReport(w http.ResponseWriter, r *http.Request) {
+	userID, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := dateRangeFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	payees, err := h.storage.GetPayees(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve payees"})
+		log.Printf("API ERROR: Failed to retrieve payees: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports.PayeeTotals(expenses, payees, from, to))
+}
+
+// assignPayee maps an incoming expense to one of the user's payees. Like the
+// categorization rules, a lookup failure only skips the mapping.
+func (h *Handler) assignPayee(userID string, expense *storage.Expense) {
+	payees, err := h.storage.GetPayees(userID)
+	if err != nil {
+		log.Printf("Warning: Skipping payee mapping: %v\n", err)
+		return
+	}
+	storage.AssignPayee(payees, expense)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+func (h *Handler) GetClosedPeriods(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	periods, err := h.storage.GetClosedPeriods(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get closed periods"})
+		log.Printf("API ERROR: Failed to get closed periods: %v\n", err)
+		return
+	}
+	if periods == nil {
+		periods = []storage.ClosedPeriod{}
+	}
+	writeJSON(w, http.StatusOK, periods)
+}
+
+// ClosePeriod closes the budgeting period containing the given date.
+func (h *Handler) ClosePeriod(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var payload struct {
+		Date string `json:"date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	date, err := parseDate(strings.TrimSpace(payload.Date))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	period, err := h.storage.ClosePeriod(userCtx.ID, date)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to close period: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, period)
+}
+
+func (h *Handler) ReopenPeriod(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.ReopenPeriod(userCtx.ID, id); err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// rejectClosedDates answers 409 when any of the dates lies in a closed
+// period. It reports whether the request was rejected.
+func (h *Handler) rejectClosedDates(w http.ResponseWriter, userID string, dates ...time.Time) bool {
+	periods, err := h.storage.GetClosedPeriods(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to check closed periods"})
+		log.Printf("API ERROR: Failed to check closed periods: %v\n", err)
+		return true
+	}
+	for _, date := range dates {
+		if period, closed := storage.ClosedPeriodFor(periods, date); closed {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: closedPeriodMessage(period)})
+			return true
+		}
+	}
+	return false
+}
+
+// rejectClosedExpenses is rejectClosedDates for stored expenses. Their dates
+// live in the blob only, so once a period is closed an encrypted ledger needs
+// the key to edit or delete any expense; without it the request is refused.
+func (h *Handler) rejectClosedExpenses(w http.ResponseWriter, userID string, manager *encryption.Manager, ids ...string) bool {
+	periods, err := h.storage.GetClosedPeriods(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to check closed periods"})
+		log.Printf("API ERROR: Failed to check closed periods: %v\n", err)
+		return true
+	}
+	if len(periods) == 0 {
+		return false
+	}
+	for _, id := range ids {
+		expense, err := h.loadExpense(userID, id, manager)
+		if err != nil {
+			if manager == nil && strings.Contains(err.Error(), encryptionHeader) {
+				err = fmt.Errorf("expense %s is encrypted; send the %s header to change expenses while periods are closed", id, encryptionHeader)
+			}
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return true
+		}
+		if period, closed := storage.ClosedPeriodFor(periods, expense.Date); closed {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: closedPeriodMessage(period)})
+			return true
+		}
+	}
+	return false
+}
+
+// rejectClosedRecurring guards rules whose (re)generation from the start date
+// would touch a closed period.
+func (h *Handler) rejectClosedRecurring(w http.ResponseWriter, userID string, rules ...storage.RecurringExpense) bool {
+	var dates []time.Time
+	for _, rule := range rules {
+		dates = append(dates, storage.RecurringOccurrenceDates(rule)...)
+	}
+	return h.rejectClosedDates(w, userID, dates...)
+}
+
+func closedPeriodMessage(period storage.ClosedPeriod) string {
+	return fmt.Sprintf("period %s to %s is closed; reopen it to make changes",
+		period.PeriodStart.Format("2006-01-02"), period.PeriodEnd.AddDate(0, 0, -1).Format("2006-01-02"))
+}
@@ -0,0 +1,372 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+func (h *Handler) GetReconciliations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	recs, err := h.storage.GetReconciliations(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get reconciliations"})
+		log.Printf("API ERROR: Failed to get reconciliations: %v\n", err)
+		return
+	}
+	if recs == nil {
+		recs = []storage.Reconciliation{}
+	}
+	writeJSON(w, http.StatusOK, recs)
+}
+
+// StartReconciliation opens a session for an account and statement. Without a
+// startingBalance, the ending balance of the last completed session is used.
+func (h *Handler) StartReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var payload struct {
+		Account         string    `json:"account"`
+		StatementDate   time.Time `json:"statementDate"`
+		EndingBalance   float64   `json:"endingBalance"`
+		StartingBalance *float64  `json:"startingBalance"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	rec := storage.Reconciliation{
+		Account:       payload.Account,
+		StatementDate: payload.StatementDate,
+		EndingBalance: payload.EndingBalance,
+	}
+	if err := rec.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if payload.StartingBalance != nil {
+		rec.StartingBalance = *payload.StartingBalance
+	} else {
+		rec.StartingBalance, err = h.storage.LastReconciledBalance(userCtx.ID, rec.Account)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get previous balance"})
+			log.Printf("API ERROR: Failed to get previous balance: %v\n", err)
+			return
+		}
+	}
+	created, err := h.storage.AddReconciliation(userCtx.ID, rec)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to start reconciliation: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// GetReconciliationSummary returns the session with its cleared balance and
+// the difference left to explain.
+func (h *Handler) GetReconciliationSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	rec, ticked, ok := h.loadReconciliation(w, r, userCtx.ID, manager)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.Summarize(ticked))
+}
+
+// GetReconciliationCandidates lists expenses that can still be ticked off: not
+// yet reconciled, dated on or before the statement date, and either on the
+// session's account or without an account.
+func (h *Handler) GetReconciliationCandidates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	rec, err := h.storage.GetReconciliation(userCtx.ID, r.URL.Query().Get("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to load expenses for reconciliation: %v\n", err)
+		return
+	}
+	inSession := make(map[string]bool, len(rec.ExpenseIDs))
+	for _, id := range rec.ExpenseIDs {
+		inSession[id] = true
+	}
+	cutoff := rec.StatementDate.AddDate(0, 0, 1)
+	candidates := []storage.Expense{}
+	for _, expense := range expenses {
+		if inSession[expense.ID] || expense.Status == storage.ExpenseStatusReconciled || !expense.Date.Before(cutoff) {
+			continue
+		}
+		if expense.Account != "" && !strings.EqualFold(expense.Account, rec.Account) {
+			continue
+		}
+		candidates = append(candidates, expense)
+	}
+	writeJSON(w, http.StatusOK, candidates)
+}
+
+// TickReconciliationExpense adds an expense to an open session and marks it cleared.
+func (h *Handler) TickReconciliationExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	rec, err := h.storage.GetReconciliation(userCtx.ID, r.URL.Query().Get("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if rec.Status != storage.ReconciliationOpen {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "reconciliation is already completed"})
+		return
+	}
+	expense, err := h.loadExpense(userCtx.ID, r.URL.Query().Get("expenseId"), manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if expense.Account != "" && !strings.EqualFold(expense.Account, rec.Account) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("expense belongs to account '%s'", expense.Account)})
+		return
+	}
+	if !expense.Date.Before(rec.StatementDate.AddDate(0, 0, 1)) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expense is dated after the statement date"})
+		return
+	}
+	if err := h.storage.AddReconciliationItem(userCtx.ID, rec.ID, expense.ID); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if expense.Status != storage.ExpenseStatusCleared || expense.Account == "" {
+		expense.Status = storage.ExpenseStatusCleared
+		expense.Account = rec.Account
+		if err := h.saveExpense(userCtx.ID, expense, manager); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to mark expense as cleared"})
+			log.Printf("API ERROR: Failed to mark expense %s as cleared: %v\n", expense.ID, err)
+			return
+		}
+	}
+	rec, ticked, ok := h.loadReconciliation(w, r, userCtx.ID, manager)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.Summarize(ticked))
+}
+
+func (h *Handler) UntickReconciliationExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.RemoveReconciliationItem(userCtx.ID, r.URL.Query().Get("id"), r.URL.Query().Get("expenseId")); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	rec, ticked, ok := h.loadReconciliation(w, r, userCtx.ID, manager)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, rec.Summarize(ticked))
+}
+
+// CompleteReconciliation locks the ticked expenses once the difference is zero.
+func (h *Handler) CompleteReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	rec, ticked, ok := h.loadReconciliation(w, r, userCtx.ID, manager)
+	if !ok {
+		return
+	}
+	if rec.Status != storage.ReconciliationOpen {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "reconciliation is already completed"})
+		return
+	}
+	summary := rec.Summarize(ticked)
+	if !summary.Balanced {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":   fmt.Sprintf("statement does not balance, difference is %.2f", summary.Difference),
+			"summary": summary,
+		})
+		return
+	}
+	reconciled := make([]storage.Expense, 0, len(ticked))
+	for _, expense := range ticked {
+		expense.Status = storage.ExpenseStatusReconciled
+		expense.Blob = ""
+		if err := ensureExpenseBlob(manager, &expense); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		reconciled = append(reconciled, expense)
+	}
+	if err := h.storage.CompleteReconciliation(userCtx.ID, rec.ID, reconciled); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to complete reconciliation"})
+		log.Printf("API ERROR: Failed to complete reconciliation: %v\n", err)
+		return
+	}
+	completed, err := h.storage.GetReconciliation(userCtx.ID, rec.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get reconciliation"})
+		log.Printf("API ERROR: Failed to get reconciliation: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, completed.Summarize(ticked))
+}
+
+// DeleteReconciliation discards an open session; its expenses stay cleared.
+func (h *Handler) DeleteReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveReconciliation(userCtx.ID, id); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// loadReconciliation fetches the session named by ?id= together with its
+// decrypted expenses, writing the error response itself on failure.
+func (h *Handler) loadReconciliation(w http.ResponseWriter, r *http.Request, userID string, manager *encryption.Manager) (storage.Reconciliation, []storage.Expense, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return storage.Reconciliation{}, nil, false
+	}
+	rec, err := h.storage.GetReconciliation(userID, id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return storage.Reconciliation{}, nil, false
+	}
+	ticked := make([]storage.Expense, 0, len(rec.ExpenseIDs))
+	for _, expenseID := range rec.ExpenseIDs {
+		expense, err := h.loadExpense(userID, expenseID, manager)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return storage.Reconciliation{}, nil, false
+		}
+		ticked = append(ticked, expense)
+	}
+	return rec, ticked, true
+}
+
+// rejectLockedRecurring is rejectLockedExpenses for the occurrences of a
+// recurring expense that updateAll and removeAll delete.
+func (h *Handler) rejectLockedRecurring(w http.ResponseWriter, userID, recurringID string) bool {
+	ids, err := h.storage.GetRecurringExpenseIDs(userID, recurringID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to check reconciliation locks"})
+		log.Printf("API ERROR: Failed to list recurring expense occurrences: %v\n", err)
+		return true
+	}
+	return h.rejectLockedExpenses(w, userID, ids...)
+}
+
+// rejectLockedExpenses answers 409 when any of the expenses belongs to a
+// completed reconciliation. It reports whether the request was rejected.
+func (h *Handler) rejectLockedExpenses(w http.ResponseWriter, userID string, ids ...string) bool {
+	locked, err := h.storage.GetLockedExpenseIDs(userID, ids)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to check reconciliation locks"})
+		log.Printf("API ERROR: Failed to check reconciliation locks: %v\n", err)
+		return true
+	}
+	if len(locked) > 0 {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("expense %s is reconciled and locked", strings.Join(locked, ", "))})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/tanq16/expenseowl/internal/reports"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+func (h *Handler) GetRefundLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	links, err := h.storage.GetRefundLinks(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get refund links"})
+		log.Printf("API ERROR: Failed to get refund links: %v\n", err)
+		return
+	}
+	expenseID := r.URL.Query().Get("expenseId")
+	filtered := []storage.RefundLink{}
+	for _, link := range links {
+		if expenseID == "" || link.ExpenseID == expenseID || link.RefundID == expenseID {
+			filtered = append(filtered, link)
+		}
+	}
+	writeJSON(w, http.StatusOK, filtered)
+}
+
+// AddRefundLink links a refunding transaction to the expense it pays back.
+func (h *Handler) AddRefundLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	var link storage.RefundLink
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := link.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	original, err := h.loadExpense(userCtx.ID, link.ExpenseID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	refund, err := h.loadExpense(userCtx.ID, link.RefundID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if original.Amount >= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "only spending (negative) expenses can be refunded"})
+		return
+	}
+	if refund.Amount <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "a refund must be a positive transaction"})
+		return
+	}
+
+	existing, err := h.storage.GetRefundLinks(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get refund links"})
+		log.Printf("API ERROR: Failed to get refund links: %v\n", err)
+		return
+	}
+	for _, other := range existing {
+		if other.ExpenseID == link.ExpenseID && other.RefundID == link.RefundID {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "these transactions are already linked"})
+			return
+		}
+	}
+	byExpense, byRefund := reports.LinkedAmounts(existing)
+	available := math.Min(refund.Amount-byRefund[refund.ID], -original.Amount-byExpense[original.ID])
+	available = math.Round(available*100) / 100
+	if available <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "nothing left to link: the refund is fully applied or the expense fully refunded"})
+		return
+	}
+	if link.Amount == 0 {
+		link.Amount = available
+	}
+	if link.Amount > available {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("amount exceeds the %.2f still available to link", available)})
+		return
+	}
+
+	created, err := h.storage.AddRefundLink(userCtx.ID, link)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to link refund"})
+		log.Printf("API ERROR: Failed to link refund: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) DeleteRefundLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveRefundLink(userCtx.ID, id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete refund link"})
+		log.Printf("API ERROR: Failed to delete refund link: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// GetOutstandingReimbursements lists reimbursable expenses still awaiting payback.
+func (h *Handler) GetOutstandingReimbursements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to load expenses for reimbursements: %v\n", err)
+		return
+	}
+	links, err := h.storage.GetRefundLinks(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get refund links"})
+		log.Printf("API ERROR: Failed to get refund links: %v\n", err)
+		return
+	}
+	items := reports.OutstandingReimbursements(expenses, links)
+	var total float64
+	for _, item := range items {
+		total += item.Outstanding
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total": math.Round(total*100) / 100,
+		"items": items,
+	})
+}
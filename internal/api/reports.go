@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/reports"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// CategoryReport returns spend, refunds and income per category for an optional date range.
+func (h *Handler) CategoryReport(w http.ResponseWriter, r *http.Request) {
+	userID, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := dateRangeFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	links, err := h.storage.GetRefundLinks(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get refund links"})
+		log.Printf("API ERROR: Failed to get refund links: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports.CategoryTotals(expenses, links, from, to))
+}
+
+// TagReport returns spend and income per tag for an optional date range.
+func (h *Handler) TagReport(w http.ResponseWriter, r *http.Request) {
+	_, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := dateRangeFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reports.TagTotals(expenses, from, to))
+}
+
+// MonthReport returns totals per calendar month for an optional date range.
+func (h *Handler) MonthReport(w http.ResponseWriter, r *http.Request) {
+	_, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := dateRangeFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reports.MonthTotals(expenses, from, to))
+}
+
+// PeriodReport returns totals for the last ?count= budgeting periods, which
+// start on the user's configured start day.
+func (h *Handler) PeriodReport(w http.ResponseWriter, r *http.Request) {
+	userID, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	count, err := intParam(r, "count", 12, 1, 120)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	startDay, err := h.storage.GetStartDate(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get start date"})
+		log.Printf("API ERROR: Failed to get start date: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports.PeriodTotals(expenses, startDay, time.Now(), count))
+}
+
+// YearOverYearReport compares each month of ?year= with the previous year.
+func (h *Handler) YearOverYearReport(w http.ResponseWriter, r *http.Request) {
+	_, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	year, err := intParam(r, "year", time.Now().Year(), 1900, 9999)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reports.YearOverYear(expenses, year))
+}
+
+// HeatmapReport returns daily totals, by default for the last year.
+func (h *Handler) HeatmapReport(w http.ResponseWriter, r *http.Request) {
+	_, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := dateRangeFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if from.IsZero() && to.IsZero() {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		from, to = today.AddDate(-1, 0, 1), today.AddDate(0, 0, 1)
+	}
+	writeJSON(w, http.StatusOK, reports.DailyTotals(expenses, from, to))
+}
+
+// SummaryReport returns income against expenses. Without from/to it covers
+// the current budgeting period ("this month's spend"); ?period=previous
+// selects the one before.
+func (h *Handler) SummaryReport(w http.ResponseWriter, r *http.Request) {
+	userID, expenses, ok := h.reportExpenses(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := dateRangeFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if from.IsZero() && to.IsZero() {
+		startDay, err := h.storage.GetStartDate(userID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get start date"})
+			log.Printf("API ERROR: Failed to get start date: %v\n", err)
+			return
+		}
+		from, to = storage.PeriodBounds(time.Now(), startDay)
+		switch r.URL.Query().Get("period") {
+		case "", "current":
+		case "previous":
+			to = from
+			from, _ = storage.PeriodBounds(from.AddDate(0, 0, -1), startDay)
+		default:
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "period must be 'current' or 'previous'"})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, reports.Summarize(expenses, from, to))
+}
+
+// reportExpenses runs the common preamble of report handlers: method check,
+// authentication and loading the expenses, decrypted in memory when the
+// request carries an encryption key.
+func (h *Handler) reportExpenses(w http.ResponseWriter, r *http.Request) (string, []storage.Expense, bool) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return "", nil, false
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return "", nil, false
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return "", nil, false
+	}
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to load expenses for report: %v\n", err)
+		return "", nil, false
+	}
+	return userCtx.ID, expenses, true
+}
+
+// dateRangeFromRequest reads the optional from/to query parameters. The range
+// is inclusive of both days; `to` is turned into an exclusive upper bound.
+func dateRangeFromRequest(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if raw := strings.TrimSpace(r.URL.Query().Get("from")); raw != "" {
+		d, err := parseDate(raw)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid 'from' date: %s", raw)
+		}
+		from = d
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("to")); raw != "" {
+		d, err := parseDate(raw)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid 'to' date: %s", raw)
+		}
+		to = d.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("'from' must not be after 'to'")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// RuleChange is one expense a dry run would modify.
+type RuleChange struct {
+	ExpenseID string          `json:"expenseId"`
+	Date      time.Time       `json:"date"`
+	Before    RuleChangeState `json:"before"`
+	After     RuleChangeState `json:"after"`
+	RuleIDs   []string        `json:"ruleIds"`
+}
+
+// RuleChangeState holds the fields rules can change.
+type RuleChangeState struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
+}
+
+func (h *Handler) GetCategorizationRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	rules, err := h.storage.GetCategorizationRules(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve rules"})
+		log.Printf("API ERROR: Failed to retrieve rules: %v\n", err)
+		return
+	}
+	if rules == nil {
+		rules = []storage.CategorizationRule{}
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// AddCategorizationRule appends a rule; it is enabled unless stated otherwise.
+func (h *Handler) AddCategorizationRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	rule := storage.CategorizationRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	created, err := h.storage.AddCategorizationRule(userCtx.ID, rule)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to add rule"})
+		log.Printf("API ERROR: Failed to add rule: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) EditCategorizationRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	rule := storage.CategorizationRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.UpdateCategorizationRule(userCtx.ID, id, rule); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to update rule"})
+		log.Printf("API ERROR: Failed to update rule: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) DeleteCategorizationRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "ID parameter is required"})
+		return
+	}
+	if err := h.storage.RemoveCategorizationRule(userCtx.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete rule"})
+		log.Printf("API ERROR: Failed to delete rule: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// ReorderCategorizationRules takes {"ids": [...]} listing every rule in the
+// order they should run.
+func (h *Handler) ReorderCategorizationRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if err := h.storage.ReorderCategorizationRules(userCtx.ID, req.IDs); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// TestCategorizationRules is a dry run against past expenses. It tests the
+// rule in the request body, the saved rule given by ?id=, or all saved rules
+// when neither is given, and lists the changes without storing them.
+func (h *Handler) TestCategorizationRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var rules []storage.CategorizationRule
+	if id := r.URL.Query().Get("id"); id != "" {
+		rule, err := h.storage.GetCategorizationRule(userCtx.ID, id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		rule.Enabled = true
+		rules = append(rules, rule)
+	} else if r.ContentLength != 0 {
+		rule := storage.CategorizationRule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+		if err := rule.Validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		rules = append(rules, rule)
+	} else {
+		rules, err = h.storage.GetCategorizationRules(userCtx.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to retrieve rules"})
+			log.Printf("API ERROR: Failed to retrieve rules: %v\n", err)
+			return
+		}
+	}
+
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	changes := []RuleChange{}
+	for _, expense := range expenses {
+		before := RuleChangeState{Name: expense.Name, Category: expense.Category, Tags: append([]string(nil), expense.Tags...)}
+		updated := expense
+		updated.Tags = append([]string(nil), expense.Tags...)
+		matched := storage.ApplyRules(rules, &updated)
+		if len(matched) == 0 {
+			continue
+		}
+		after := RuleChangeState{Name: updated.Name, Category: updated.Category, Tags: updated.Tags}
+		if after.Name == before.Name && after.Category == before.Category && len(after.Tags) == len(before.Tags) {
+			continue
+		}
+		changes = append(changes, RuleChange{ExpenseID: expense.ID, Date: expense.Date, Before: before, After: after, RuleIDs: matched})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total":   len(expenses),
+		"changed": len(changes),
+		"changes": changes,
+	})
+}
+
+// applyCategorizationRules runs the user's rules against a new expense. A
+// failure to load the rules must not block the expense, so it is only logged.
+func (h *Handler) applyCategorizationRules(userID string, expense *storage.Expense) {
+	rules, err := h.storage.GetCategorizationRules(userID)
+	if err != nil {
+		log.Printf("Warning: Skipping categorization rules: %v\n", err)
+		return
+	}
+	storage.ApplyRules(rules, expense)
+}
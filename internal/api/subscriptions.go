@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/encryption"
+	"github.com/tanq16/expenseowl/internal/reports"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// GetSubscriptionSuggestions lists recurring patterns found in expenses that
+// aren't linked to a recurring rule.
+func (h *Handler) GetSubscriptionSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	suggestions, _, err := h.subscriptionSuggestions(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+// AcceptSubscription turns a suggestion into a recurring rule. The matched
+// expenses are linked to the rule and only later occurrences are generated.
+func (h *Handler) AcceptSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	var req struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	suggestions, expenses, err := h.subscriptionSuggestions(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	var suggestion *reports.SubscriptionSuggestion
+	for i := range suggestions {
+		if suggestions[i].ID == req.ID {
+			suggestion = &suggestions[i]
+			break
+		}
+	}
+	if suggestion == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Subscription suggestion not found"})
+		return
+	}
+
+	rule := storage.RecurringExpense{
+		ID:        uuid.New().String(),
+		UserID:    userCtx.ID,
+		Name:      suggestion.Name,
+		Amount:    suggestion.Amount,
+		Currency:  suggestion.Currency,
+		Tags:      suggestion.Tags,
+		Category:  suggestion.Category,
+		StartDate: suggestion.StartDate,
+		Interval:  suggestion.Interval,
+	}
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.Category != "" {
+		rule.Category = req.Category
+	}
+	if err := rule.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// Linking only sets RecurringID and leaves amounts and dates alone, so
+	// closed or reconciled rows may be linked. New occurrences start at the
+	// next expected charge, which must not fall in a closed period.
+	if h.rejectClosedDates(w, userCtx.ID, suggestion.NextDate) {
+		return
+	}
+	byID := make(map[string]storage.Expense, len(expenses))
+	for _, expense := range expenses {
+		byID[expense.ID] = expense
+	}
+	linked := make([]storage.Expense, 0, len(suggestion.ExpenseIDs))
+	for _, id := range suggestion.ExpenseIDs {
+		expense := byID[id]
+		expense.RecurringID = rule.ID
+		expense.Blob = ""
+		if err := ensureExpenseBlob(manager, &expense); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		linked = append(linked, expense)
+	}
+	if err := ensureRecurringBlob(manager, &rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := h.storage.AdoptRecurringExpense(userCtx.ID, rule, linked, manager); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to accept subscription"})
+		log.Printf("API ERROR: Failed to accept subscription: %v\n", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// subscriptionSuggestions detects subscriptions among the user's expenses,
+// leaving out patterns an existing rule with the same name and interval
+// already covers. The decrypted expenses are returned for reuse.
+func (h *Handler) subscriptionSuggestions(userID string, manager *encryption.Manager) ([]reports.SubscriptionSuggestion, []storage.Expense, error) {
+	expenses, err := h.decryptedExpenses(userID, manager)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := h.storage.GetRecurringExpenses(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	covered := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := decryptRecurring(manager, &rule); err != nil {
+			continue
+		}
+		covered[storage.NormalizeName(rule.Name)+"|"+rule.Interval] = true
+	}
+	suggestions := []reports.SubscriptionSuggestion{}
+	for _, suggestion := range reports.DetectSubscriptions(expenses, time.Now()) {
+		if !covered[storage.NormalizeName(suggestion.Name)+"|"+suggestion.Interval] {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions, expenses, nil
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/reports"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// GetTaxClasses returns the suggested deduction classes.
+func (h *Handler) GetTaxClasses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, storage.TaxClasses)
+}
+
+// TaxReport returns the yearly tax report as JSON, CSV or a printable HTML page.
+func (h *Handler) TaxReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+	userCtx, err := h.userFromRequest(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	manager, err := h.encryptionManagerFromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	year := time.Now().Year()
+	if raw := strings.TrimSpace(r.URL.Query().Get("year")); raw != "" {
+		year, err = strconv.Atoi(raw)
+		if err != nil || year < 1900 || year > 9999 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid 'year' parameter"})
+			return
+		}
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "html" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "format must be one of 'json', 'csv' or 'html'"})
+		return
+	}
+
+	expenses, err := h.decryptedExpenses(userCtx.ID, manager)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		log.Printf("API ERROR: Failed to load expenses for tax report: %v\n", err)
+		return
+	}
+	links, err := h.storage.GetRefundLinks(userCtx.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to get refund links"})
+		log.Printf("API ERROR: Failed to get refund links: %v\n", err)
+		return
+	}
+	report := reports.BuildTaxReport(expenses, links, year, time.UTC)
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=tax-report-%d.csv", year))
+		if err := writeTaxReportCSV(w, report); err != nil {
+			log.Printf("API ERROR: Failed to write tax report CSV: %v\n", err)
+		}
+	case "html":
+		currency, err := h.storage.GetCurrency(userCtx.ID)
+		if err != nil {
+			log.Printf("API ERROR: Failed to get currency for tax report: %v\n", err)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := taxReportTemplate.Execute(w, taxReportPage{Report: report, Currency: strings.ToUpper(currency), Generated: time.Now()}); err != nil {
+			log.Printf("API ERROR: Failed to render tax report: %v\n", err)
+		}
+	default:
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// writeTaxReportCSV lists the supporting expenses per class followed by a
+// total row for each class and the grand total.
+func writeTaxReportCSV(out io.Writer, report reports.TaxReport) error {
+	writer := csv.NewWriter(out)
+	defer writer.Flush()
+
+	headers := []string{"Class", "ID", "Name", "Category", "Date", "Amount", "Refunded", "Deductible", "Tags"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("failed to write CSV header: %v", err)
+	}
+	for _, class := range report.Classes {
+		for _, item := range class.Items {
+			record := []string{
+				class.Class,
+				item.Expense.ID,
+				item.Expense.Name,
+				item.Expense.Category,
+				item.Expense.Date.Format(time.RFC3339),
+				strconv.FormatFloat(item.Expense.Amount, 'f', 2, 64),
+				strconv.FormatFloat(item.Refunded, 'f', 2, 64),
+				strconv.FormatFloat(item.Deductible, 'f', 2, 64),
+				strings.Join(item.Expense.Tags, ","),
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV record for expense ID %s: %v", item.Expense.ID, err)
+			}
+		}
+		if err := writer.Write([]string{class.Class, "", "Total", "", "", "", "", strconv.FormatFloat(class.Total, 'f', 2, 64), ""}); err != nil {
+			return fmt.Errorf("failed to write CSV total: %v", err)
+		}
+	}
+	if err := writer.Write([]string{"", "", "Grand total", "", "", "", "", strconv.FormatFloat(report.Total, 'f', 2, 64), ""}); err != nil {
+		return fmt.Errorf("failed to write CSV total: %v", err)
+	}
+	return nil
+}
+
+type taxReportPage struct {
+	Report    reports.TaxReport
+	Currency  string
+	Generated time.Time
+}
+
+var taxReportTemplate = template.Must(template.New("tax").Funcs(template.FuncMap{
+	"money": func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+	"label": func(class string) string { return strings.ReplaceAll(class, "_", " ") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Tax report {{.Report.Year}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; margin-top: 0.3em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ccc; padding: 0.3em 0.5em; text-align: left; }
+td.num, th.num { text-align: right; }
+tr.total td { font-weight: bold; border-top: 2px solid #222; }
+h2 { text-transform: capitalize; }
+@media print { body { margin: 0; } h2 { page-break-after: avoid; } }
+</style>
+</head>
+<body>
+<h1>Tax report {{.Report.Year}}</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02"}}{{if .Currency}} &middot; amounts in {{.Currency}}{{end}}</p>
+<table>
+<tr><th>Class</th><th class="num">Expenses</th><th class="num">Deductible</th></tr>
+{{range .Report.Classes}}<tr><td style="text-transform: capitalize">{{label .Class}}</td><td class="num">{{.Count}}</td><td class="num">{{money .Total}}</td></tr>
+{{end}}<tr class="total"><td>Total</td><td></td><td class="num">{{money .Report.Total}}</td></tr>
+</table>
+{{range .Report.Classes}}
+<h2>{{label .Class}}</h2>
+<table>
+<tr><th>Date</th><th>Name</th><th>Category</th><th class="num">Amount</th><th class="num">Refunded</th><th class="num">Deductible</th></tr>
+{{range .Items}}<tr><td>{{.Expense.Date.Format "2006-01-02"}}</td><td>{{.Expense.Name}}</td><td>{{.Expense.Category}}</td><td class="num">{{money .Expense.Amount}}</td><td class="num">{{money .Refunded}}</td><td class="num">{{money .Deductible}}</td></tr>
+{{end}}<tr class="total"><td colspan="5">Total</td><td class="num">{{money .Total}}</td></tr>
+</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,53 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the requested object does not exist.
+	ErrNotFound = errors.New("blob not found")
+)
+
+// Store persists opaque binary objects such as receipts and invoices.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv builds the configured Store. ATTACHMENT_STORAGE selects the
+// driver ("local" by default, or "s3"); the remaining variables configure it.
+func NewFromEnv() (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ATTACHMENT_STORAGE"))) {
+	case "", "local":
+		dir := os.Getenv("ATTACHMENT_DIR")
+		if dir == "" {
+			dir = "data/attachments"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
+		})
+	default:
+		return nil, fmt.Errorf("invalid attachment storage: %s", os.Getenv("ATTACHMENT_STORAGE"))
+	}
+}
+
+// validKey rejects keys that could escape the storage root.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid blob key: %q", key)
+	}
+	return nil
+}
@@ -78,3 +78,40 @@ func (m *Manager) Key() []byte {
 	copy(dup, m.key)
 	return dup
 }
+
+// EncryptBytes encrypts raw binary content (e.g. attachments) without the
+// JSON envelope used by Encrypt.
+func (m *Manager) EncryptBytes(data []byte) (string, error) {
+	if m == nil || len(m.key) == 0 {
+		return "", ErrMissingCipher
+	}
+	recipient := jose.Recipient{
+		Algorithm: jose.A256KW,
+		Key:       m.key,
+	}
+	enc, err := jose.NewEncrypter(jose.A128CBC_HS256, recipient, nil)
+	if err != nil {
+		return "", err
+	}
+	object, err := enc.Encrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return object.CompactSerialize()
+}
+
+// DecryptBytes reverses EncryptBytes.
+func (m *Manager) DecryptBytes(blob string) ([]byte, error) {
+	if m == nil || len(m.key) == 0 {
+		return nil, ErrMissingCipher
+	}
+	blob = strings.TrimSpace(blob)
+	if blob == "" {
+		return nil, errors.New("ciphertext payload is empty")
+	}
+	object, err := jose.ParseEncrypted(blob)
+	if err != nil {
+		return nil, err
+	}
+	return object.Decrypt(m.key)
+}
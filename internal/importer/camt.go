@@ -0,0 +1,319 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xmlNode is an element of an XML document keyed by its local name, so the
+// namespaces of the different camt versions don't matter.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	value    string
+	children []*xmlNode
+}
+
+func (n *xmlNode) child(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// path follows the first child with each name.
+func (n *xmlNode) path(names ...string) *xmlNode {
+	node := n
+	for _, name := range names {
+		if node = node.child(name); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func (n *xmlNode) text(names ...string) string {
+	if node := n.path(names...); node != nil {
+		return strings.TrimSpace(node.value)
+	}
+	return ""
+}
+
+func (n *xmlNode) all(name string) []*xmlNode {
+	if n == nil {
+		return nil
+	}
+	var found []*xmlNode
+	for _, c := range n.children {
+		if c.name == name {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+func parseXMLTree(r io.Reader) (*xmlNode, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	root := &xmlNode{name: "#root"}
+	stack := []*xmlNode{root}
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string)}
+			for _, a := range t.Attr {
+				node.attrs[a.Name.Local] = a.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			stack[len(stack)-1].value += string(t)
+		}
+	}
+	return root, nil
+}
+
+// ParseCAMT053 reads an ISO 20022 camt.053 bank-to-customer statement (any
+// version from 001.02 on). Only booked entries are returned; entries booked
+// as a batch with individually amounted details yield one transaction each.
+func ParseCAMT053(r io.Reader) ([]Statement, error) {
+	root, err := parseXMLTree(r)
+	if err != nil {
+		return nil, err
+	}
+	report := root.path("Document", "BkToCstmrStmt")
+	if report == nil {
+		return nil, fmt.Errorf("not a camt.053 file: missing BkToCstmrStmt element")
+	}
+	var statements []Statement
+	for _, stmt := range report.all("Stmt") {
+		statement, err := parseCAMTStatement(stmt)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("camt.053 file contains no statement")
+	}
+	return statements, nil
+}
+
+func parseCAMTStatement(stmt *xmlNode) (Statement, error) {
+	account := stmt.child("Acct")
+	statement := Statement{
+		Account:  account.text("Id", "IBAN"),
+		BankID:   account.text("Svcr", "FinInstnId", "BIC"),
+		Currency: strings.ToLower(account.text("Ccy")),
+	}
+	if statement.Account == "" {
+		statement.Account = account.text("Id", "Othr", "Id")
+	}
+	if statement.BankID == "" {
+		statement.BankID = account.text("Svcr", "FinInstnId", "BICFI")
+	}
+	if period := stmt.child("FrToDt"); period != nil {
+		if date, err := parseCAMTDate(period.text("FrDtTm")); err == nil {
+			statement.Start = &date
+		}
+		if date, err := parseCAMTDate(period.text("ToDtTm")); err == nil {
+			statement.End = &date
+		}
+	}
+	for _, balance := range stmt.all("Bal") {
+		code := balance.text("Tp", "CdOrPrtry", "Cd")
+		if code != "CLBD" && code != "CLAV" {
+			continue
+		}
+		if statement.LedgerBalance != nil && code == "CLAV" {
+			continue // the closing booked balance wins over the available one
+		}
+		amount, currency, err := parseCAMTAmount(balance)
+		if err != nil {
+			continue
+		}
+		statement.LedgerBalance = &amount
+		if statement.Currency == "" {
+			statement.Currency = currency
+		}
+		if date, err := parseCAMTDate(camtDate(balance.child("Dt"))); err == nil {
+			statement.BalanceDate = &date
+		}
+	}
+
+	for i, entry := range stmt.all("Ntry") {
+		status := entry.text("Sts")
+		if status == "" {
+			status = entry.text("Sts", "Cd")
+		}
+		if status != "" && status != "BOOK" {
+			continue // pending and information-only entries change later
+		}
+		txns, err := parseCAMTEntry(entry)
+		if err != nil {
+			return statement, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		statement.Transactions = append(statement.Transactions, txns...)
+	}
+	return statement, nil
+}
+
+func parseCAMTEntry(entry *xmlNode) ([]Transaction, error) {
+	amount, currency, err := parseCAMTAmount(entry)
+	if err != nil {
+		return nil, err
+	}
+	date, err := parseCAMTDate(camtDate(entry.child("BookgDt")))
+	if err != nil {
+		if date, err = parseCAMTDate(camtDate(entry.child("ValDt"))); err != nil {
+			return nil, fmt.Errorf("missing booking date")
+		}
+	}
+	// CdtDbtInd of a reversal already states the direction of the booking,
+	// so RvslInd is only passed on
+	base := Transaction{
+		ID:       entry.text("AcctSvcrRef"),
+		Type:     entry.text("BkTxCd", "Domn", "Fmly", "SubFmlyCd"),
+		Date:     date,
+		Amount:   amount,
+		Currency: currency,
+		Memo:     entry.text("AddtlNtryInf"),
+		Reversal: entry.text("RvslInd") == "true",
+	}
+
+	var details []*xmlNode
+	for _, batch := range entry.all("NtryDtls") {
+		details = append(details, batch.all("TxDtls")...)
+	}
+	if len(details) <= 1 {
+		txn := base
+		if len(details) == 1 {
+			applyCAMTDetails(&txn, details[0], amount < 0)
+		}
+		return []Transaction{txn}, nil
+	}
+	// batch booking: one transaction per detail when every detail has an amount
+	var txns []Transaction
+	for i, detail := range details {
+		amounts := detail.path("AmtDtls", "TxAmt")
+		if amounts == nil {
+			amounts = detail
+		}
+		detailAmount, detailCurrency, err := parseCAMTAmount(amounts)
+		if err != nil {
+			txn := base
+			applyCAMTDetails(&txn, details[0], amount < 0)
+			return []Transaction{txn}, nil
+		}
+		indicator := detail.text("CdtDbtInd")
+		if indicator == "" {
+			indicator = entry.text("CdtDbtInd") // inherited from the entry
+		}
+		detailAmount = math.Abs(detailAmount)
+		if indicator == "DBIT" {
+			detailAmount = -detailAmount
+		}
+		txn := base
+		txn.Amount = detailAmount
+		txn.Currency = detailCurrency
+		applyCAMTDetails(&txn, detail, detailAmount < 0)
+		if txn.ID == base.ID && base.ID != "" {
+			txn.ID = base.ID + "/" + strconv.Itoa(i+1)
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
+
+// applyCAMTDetails takes the counterparty, references and remittance
+// information from a TxDtls element. The counterparty of a debit is the
+// creditor and vice versa.
+func applyCAMTDetails(txn *Transaction, detail *xmlNode, debit bool) {
+	if ref := detail.text("Refs", "AcctSvcrRef"); ref != "" && txn.ID == "" {
+		txn.ID = ref
+	}
+	parties := detail.child("RltdPties")
+	party := "Dbtr"
+	if debit {
+		party = "Cdtr"
+	}
+	name := parties.text(party, "Nm")
+	if name == "" {
+		name = parties.text(party, "Pty", "Nm") // camt.053.001.08 and later
+	}
+	if name != "" {
+		txn.Name = name
+	}
+	var remittance []string
+	for _, line := range detail.path("RmtInf").all("Ustrd") {
+		if text := strings.TrimSpace(line.value); text != "" {
+			remittance = append(remittance, text)
+		}
+	}
+	for _, structured := range detail.path("RmtInf").all("Strd") {
+		if ref := structured.text("CdtrRefInf", "Ref"); ref != "" {
+			remittance = append(remittance, ref)
+		}
+	}
+	if len(remittance) > 0 {
+		txn.Memo = strings.Join(remittance, " ")
+	} else if info := detail.text("AddtlTxInf"); info != "" {
+		txn.Memo = info
+	}
+}
+
+// parseCAMTAmount reads Amt with its Ccy attribute and applies CdtDbtInd.
+func parseCAMTAmount(node *xmlNode) (float64, string, error) {
+	amt := node.child("Amt")
+	if amt == nil {
+		return 0, "", fmt.Errorf("missing amount")
+	}
+	amount, err := strconv.ParseFloat(strings.TrimSpace(amt.value), 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid amount: %q", amt.value)
+	}
+	if node.text("CdtDbtInd") == "DBIT" {
+		amount = -amount
+	}
+	return amount, strings.ToLower(amt.attrs["Ccy"]), nil
+}
+
+// camtDate returns the Dt or DtTm value of a date choice element.
+func camtDate(node *xmlNode) string {
+	if date := node.text("Dt"); date != "" {
+		return date
+	}
+	return node.text("DtTm")
+}
+
+func parseCAMTDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05", "2006-01-02Z07:00"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %q", value)
+}
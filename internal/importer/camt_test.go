@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const camtStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+<BkToCstmrStmt>
+<Stmt>
+  <Acct>
+    <Id><IBAN>DE89370400440532013000</IBAN></Id>
+    <Ccy>EUR</Ccy>
+    <Svcr><FinInstnId><BICFI>COBADEFFXXX</BICFI></FinInstnId></Svcr>
+  </Acct>
+  <FrToDt><FrDtTm>2024-03-01T00:00:00+01:00</FrDtTm><ToDtTm>2024-03-31T23:59:59+01:00</ToDtTm></FrToDt>
+  <Bal>
+    <Tp><CdOrPrtry><Cd>CLAV</Cd></CdOrPrtry></Tp>
+    <Amt Ccy="EUR">900.00</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <Dt><Dt>2024-03-31</Dt></Dt>
+  </Bal>
+  <Bal>
+    <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+    <Amt Ccy="EUR">1000.50</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <Dt><Dt>2024-03-31</Dt></Dt>
+  </Bal>
+  <Ntry>
+    <Amt Ccy="EUR">100.00</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+    <Sts><Cd>BOOK</Cd></Sts>
+    <BookgDt><Dt>2024-03-05</Dt></BookgDt>
+    <AcctSvcrRef>E1</AcctSvcrRef>
+    <NtryDtls><TxDtls>
+      <RltdPties>
+        <Dbtr><Pty><Nm>Me</Nm></Pty></Dbtr>
+        <Cdtr><Pty><Nm>Shop</Nm></Pty></Cdtr>
+      </RltdPties>
+      <RmtInf><Ustrd>Invoice</Ustrd><Ustrd>42</Ustrd></RmtInf>
+    </TxDtls></NtryDtls>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">25.00</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <RvslInd>true</RvslInd>
+    <Sts><Cd>BOOK</Cd></Sts>
+    <BookgDt><DtTm>2024-03-06T10:00:00Z</DtTm></BookgDt>
+    <AcctSvcrRef>E2</AcctSvcrRef>
+    <NtryDtls><TxDtls>
+      <RltdPties><Dbtr><Nm>Shop</Nm></Dbtr></RltdPties>
+      <AddtlTxInf>Returned debit</AddtlTxInf>
+    </TxDtls></NtryDtls>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">5.00</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+    <Sts><Cd>PDNG</Cd></Sts>
+    <BookgDt><Dt>2024-03-07</Dt></BookgDt>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">30.00</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+    <Sts>BOOK</Sts>
+    <BookgDt><Dt>2024-03-08</Dt></BookgDt>
+    <AcctSvcrRef>E4</AcctSvcrRef>
+    <NtryDtls>
+      <TxDtls>
+        <AmtDtls><TxAmt><Amt Ccy="EUR">10.00</Amt></TxAmt></AmtDtls>
+        <RltdPties><Cdtr><Nm>Gas</Nm></Cdtr></RltdPties>
+      </TxDtls>
+      <TxDtls>
+        <Amt Ccy="USD">20.00</Amt>
+        <RltdPties><Cdtr><Nm>Power</Nm></Cdtr></RltdPties>
+      </TxDtls>
+    </NtryDtls>
+  </Ntry>
+  <Ntry>
+    <Amt Ccy="EUR">12.00</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+    <ValDt><Dt>2024-03-09</Dt></ValDt>
+    <NtryDtls>
+      <TxDtls>
+        <Refs><AcctSvcrRef>D1</AcctSvcrRef></Refs>
+        <RltdPties><Cdtr><Nm>Batch payee</Nm></Cdtr></RltdPties>
+      </TxDtls>
+      <TxDtls>
+        <Refs><AcctSvcrRef>D2</AcctSvcrRef></Refs>
+      </TxDtls>
+    </NtryDtls>
+  </Ntry>
+</Stmt>
+</BkToCstmrStmt>
+</Document>`
+
+func TestParseCAMT053(t *testing.T) {
+	statements, err := ParseCAMT053(strings.NewReader(camtStatement))
+	if err != nil {
+		t.Fatalf("ParseCAMT053: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.Account != "DE89370400440532013000" || s.BankID != "COBADEFFXXX" || s.Currency != "eur" {
+		t.Errorf("statement header = %q/%q/%q", s.Account, s.BankID, s.Currency)
+	}
+	if s.LedgerBalance == nil || *s.LedgerBalance != 1000.5 {
+		t.Errorf("ledger balance = %v, want the CLBD balance 1000.5", s.LedgerBalance)
+	}
+	if s.Start == nil || s.End == nil {
+		t.Errorf("statement period = %v - %v", s.Start, s.End)
+	}
+
+	want := []Transaction{
+		{ID: "E1", Amount: -100, Currency: "eur", Name: "Shop", Memo: "Invoice 42", Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{ID: "E2", Amount: 25, Currency: "eur", Name: "Shop", Memo: "Returned debit", Reversal: true, Date: time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)},
+		{ID: "E4/1", Amount: -10, Currency: "eur", Name: "Gas", Date: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{ID: "E4/2", Amount: -20, Currency: "usd", Name: "Power", Date: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{ID: "D1", Amount: -12, Currency: "eur", Name: "Batch payee", Date: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(s.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d: %+v", len(s.Transactions), len(want), s.Transactions)
+	}
+	for i, w := range want {
+		got := s.Transactions[i]
+		if got.ID != w.ID || got.Amount != w.Amount || got.Currency != w.Currency || got.Name != w.Name ||
+			got.Memo != w.Memo || got.Reversal != w.Reversal || !got.Date.Equal(w.Date) {
+			t.Errorf("transaction %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestParseCAMTAmountSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		indicator string
+		reversal  string
+		want      float64
+	}{
+		{"debit", "DBIT", "", -10},
+		{"credit", "CRDT", "", 10},
+		// the indicator of a reversal already states the booking's direction
+		{"reversed debit", "CRDT", "<RvslInd>true</RvslInd>", 10},
+		{"reversed credit", "DBIT", "<RvslInd>true</RvslInd>", -10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `<Document><BkToCstmrStmt><Stmt><Ntry><Amt Ccy="EUR">10.00</Amt><CdtDbtInd>` + tt.indicator +
+				`</CdtDbtInd>` + tt.reversal + `<BookgDt><Dt>2024-01-02</Dt></BookgDt></Ntry></Stmt></BkToCstmrStmt></Document>`
+			statements, err := ParseCAMT053(strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("ParseCAMT053: %v", err)
+			}
+			txn := statements[0].Transactions[0]
+			if txn.Amount != tt.want || txn.Reversal != (tt.reversal != "") {
+				t.Errorf("amount = %v, reversal = %v; want %v", txn.Amount, txn.Reversal, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCAMT053Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"not XML", "no xml here <"},
+		{"other document", `<Document><BkToCstmrAcctRpt></BkToCstmrAcctRpt></Document>`},
+		{"no statement", `<Document><BkToCstmrStmt></BkToCstmrStmt></Document>`},
+		{"bad amount", `<Document><BkToCstmrStmt><Stmt><Ntry><Amt>ten</Amt><BookgDt><Dt>2024-01-02</Dt></BookgDt></Ntry></Stmt></BkToCstmrStmt></Document>`},
+		{"no date", `<Document><BkToCstmrStmt><Stmt><Ntry><Amt>1.00</Amt></Ntry></Stmt></BkToCstmrStmt></Document>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCAMT053(strings.NewReader(tt.content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
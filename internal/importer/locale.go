@@ -0,0 +1,225 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Orders of the day and month in dates that don't start with the year.
+const (
+	DateOrderDMY = "dmy"
+	DateOrderMDY = "mdy"
+)
+
+// Locale describes how a file writes numbers and dates. An empty field is
+// detected from each value where that can be done without guessing.
+type Locale struct {
+	DecimalSeparator string // "." or ","
+	DateOrder        string // dmy or mdy
+}
+
+// Conventions of the locales bank exports commonly come in, keyed by
+// lowercase language tag.
+var locales = map[string]Locale{
+	"en-us": {".", DateOrderMDY},
+	"en-ca": {".", DateOrderMDY},
+	"en-gb": {".", DateOrderDMY},
+	"en-ie": {".", DateOrderDMY},
+	"en-au": {".", DateOrderDMY},
+	"en-nz": {".", DateOrderDMY},
+	"en-in": {".", DateOrderDMY},
+	"de-de": {",", DateOrderDMY},
+	"de-at": {",", DateOrderDMY},
+	"de-ch": {".", DateOrderDMY},
+	"fr-fr": {",", DateOrderDMY},
+	"fr-be": {",", DateOrderDMY},
+	"fr-ch": {".", DateOrderDMY},
+	"fr-ca": {",", DateOrderDMY},
+	"nl-nl": {",", DateOrderDMY},
+	"nl-be": {",", DateOrderDMY},
+	"es-es": {",", DateOrderDMY},
+	"es-mx": {".", DateOrderDMY},
+	"it-it": {",", DateOrderDMY},
+	"pt-pt": {",", DateOrderDMY},
+	"pt-br": {",", DateOrderDMY},
+	"pl-pl": {",", DateOrderDMY},
+	"sv-se": {",", DateOrderDMY},
+	"da-dk": {",", DateOrderDMY},
+	"nb-no": {",", DateOrderDMY},
+	"fi-fi": {",", DateOrderDMY},
+}
+
+// LookupLocale returns the conventions of a language tag such as "de-DE"
+// or "pt_BR".
+func LookupLocale(tag string) (Locale, bool) {
+	locale, ok := locales[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))]
+	return locale, ok
+}
+
+// ParseAmount reads an amount such as "1.234,56 €", "-$1,234.56", "(12.50)",
+// "CHF 1'234.50" or "12,50-". Currency symbols and codes around the number
+// are ignored, parentheses and a trailing minus mean a negative amount.
+// Without a decimal separator the last of "." and "," is taken as decimal,
+// except for a lone one followed by exactly three digits, which groups
+// thousands ("1.234" and "1,234" are both 1234). With one, a single other
+// separator that can't group thousands ("12.5") is read as decimal.
+func (l Locale) ParseAmount(value string) (float64, error) {
+	s := strings.TrimFunc(value, isAmountDecoration)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimFunc(s[1:len(s)-1], isAmountDecoration)
+	}
+	switch {
+	case strings.HasPrefix(s, "-"), strings.HasPrefix(s, "−"):
+		negative = !negative
+		s = strings.TrimFunc(strings.TrimLeft(s, "-−"), isAmountDecoration)
+	case strings.HasSuffix(s, "-"):
+		negative = !negative
+		s = strings.TrimFunc(strings.TrimSuffix(s, "-"), isAmountDecoration)
+	case strings.HasPrefix(s, "+"):
+		s = strings.TrimFunc(s[1:], isAmountDecoration)
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Zs, r) || r == '\'' || r == '’' {
+			return -1 // digit grouping
+		}
+		return r
+	}, s)
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return !(r >= '0' && r <= '9' || r == '.' || r == ',') }) >= 0 {
+		return 0, fmt.Errorf("invalid amount: %q", value)
+	}
+
+	decimal := l.DecimalSeparator
+	if decimal == "" {
+		decimal = detectDecimalSeparator(s)
+	}
+	grouping := ","
+	if decimal == "," {
+		grouping = "."
+	}
+	if !strings.Contains(s, decimal) && strings.Count(s, grouping) == 1 && !validGrouping(s, grouping) {
+		decimal, grouping = grouping, decimal // "12.5" in a decimal comma locale, e.g. ExpenseOwl's own export
+	}
+	s = strings.ReplaceAll(s, grouping, "")
+	if strings.Count(s, decimal) > 1 {
+		return 0, fmt.Errorf("invalid amount: %q", value)
+	}
+	amount, err := strconv.ParseFloat(strings.Replace(s, decimal, ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// isAmountDecoration matches what may surround a number: spaces, currency
+// symbols and currency codes or prefixes such as "EUR" or "R$".
+func isAmountDecoration(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.Zs, r) || unicode.Is(unicode.Sc, r) || unicode.IsLetter(r)
+}
+
+// validGrouping reports whether every grouping separator is followed by
+// exactly three digits.
+func validGrouping(s, grouping string) bool {
+	for _, group := range strings.Split(s, grouping)[1:] {
+		digits := strings.IndexFunc(group, func(r rune) bool { return r < '0' || r > '9' })
+		if digits == -1 {
+			digits = len(group)
+		}
+		if digits != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func detectDecimalSeparator(s string) string {
+	comma, dot := strings.LastIndex(s, ","), strings.LastIndex(s, ".")
+	switch {
+	case comma >= 0 && dot >= 0:
+		if comma > dot {
+			return ","
+		}
+		return "."
+	case comma >= 0:
+		if strings.Count(s, ",") == 1 && len(s)-comma-1 != 3 {
+			return ","
+		}
+		return "."
+	case dot >= 0:
+		if strings.Count(s, ".") > 1 || len(s)-dot-1 == 3 {
+			return "," // 1.234.567 and 1.234
+		}
+	}
+	return "."
+}
+
+var localeDatePattern = regexp.MustCompile(`^(\d{1,4})([./-])(\d{1,2})[./-](\d{1,4})(?:[ T](\d{1,2}):(\d{2})(?::(\d{2}))?)?$`)
+
+// ParseDate reads dates such as 2024-01-15, 15.01.2024, 15/01/24 or
+// 01/15/2024, optionally followed by a time. Dates that start with the year
+// are read as year-month-day. Otherwise the date order decides; without one,
+// dotted dates are day-first and others must be unambiguous, as 13/01/2024 is.
+func (l Locale) ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date.UTC(), nil
+	}
+	m := localeDatePattern.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("unable to parse date: %s", value)
+	}
+	first, _ := strconv.Atoi(m[1])
+	second, _ := strconv.Atoi(m[3])
+	third, _ := strconv.Atoi(m[4])
+	var year, month, day int
+	switch {
+	case len(m[1]) == 4 && len(m[4]) <= 2:
+		year, month, day = first, second, third
+	case len(m[1]) > 2 || len(m[4]) != 2 && len(m[4]) != 4:
+		return time.Time{}, fmt.Errorf("unable to parse date: %s", value)
+	default:
+		order := l.DateOrder
+		if order == "" {
+			switch {
+			case m[2] == ".", first > 12:
+				order = DateOrderDMY
+			case second > 12, first == second:
+				order = DateOrderMDY
+			default:
+				return time.Time{}, fmt.Errorf("ambiguous date %q, set the date order to %s or %s", value, DateOrderDMY, DateOrderMDY)
+			}
+		}
+		if order == DateOrderDMY {
+			day, month = first, second
+		} else {
+			month, day = first, second
+		}
+		year = third
+		if len(m[4]) == 2 {
+			if year < 70 {
+				year += 2000
+			} else {
+				year += 1900
+			}
+		}
+	}
+	var hour, minute, sec int
+	if m[5] != "" {
+		hour, _ = strconv.Atoi(m[5])
+		minute, _ = strconv.Atoi(m[6])
+		sec, _ = strconv.Atoi(m[7])
+	}
+	date := time.Date(year, time.Month(month), day, hour, minute, sec, 0, time.UTC)
+	if date.Day() != day || int(date.Month()) != month || date.Hour() != hour || date.Minute() != minute || date.Second() != sec {
+		return time.Time{}, fmt.Errorf("invalid date: %s", value)
+	}
+	return date, nil
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupLocale(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Locale
+		ok   bool
+	}{
+		{"de-DE", Locale{",", DateOrderDMY}, true},
+		{" pt_BR ", Locale{",", DateOrderDMY}, true},
+		{"en-us", Locale{".", DateOrderMDY}, true},
+		{"xx-XX", Locale{}, false},
+		{"", Locale{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := LookupLocale(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("LookupLocale(%q) = %+v, %v; want %+v, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	german, _ := LookupLocale("de-DE")
+	american, _ := LookupLocale("en-US")
+	tests := []struct {
+		name   string
+		locale Locale
+		value  string
+		want   float64
+	}{
+		{"plain", Locale{}, "12.34", 12.34},
+		{"decimal comma", Locale{}, "12,34", 12.34},
+		{"grouped comma decimal", Locale{}, "1.234,56 €", 1234.56},
+		{"grouped dot decimal", Locale{}, "-$1,234.56", -1234.56},
+		{"parentheses", Locale{}, "(12.50)", -12.5},
+		{"trailing minus", Locale{}, "12,50-", -12.5},
+		{"unicode minus", Locale{}, "−3.00", -3},
+		{"explicit plus", Locale{}, "+ 7", 7},
+		{"apostrophe grouping", Locale{}, "CHF 1'234.50", 1234.5},
+		{"space grouping", Locale{}, "1 234,56", 1234.56},
+		{"currency prefix", Locale{}, "R$ 10,00", 10},
+		{"several dot groups", Locale{}, "1.234.567", 1234567},
+		{"several comma groups", Locale{}, "1,234,567.8", 1234567.8},
+		{"lone comma with three digits", Locale{}, "1,234", 1234},
+		{"lone dot with three digits", Locale{}, "1.234", 1234},
+		{"lone dot with three digits, negative", Locale{}, "-1.234", -1234},
+		{"lone dot with two digits", Locale{}, "1.23", 1.23},
+		{"german grouping", german, "1.234", 1234},
+		{"german decimal", german, "1.234,5", 1234.5},
+		{"german reading a decimal point", german, "12.5", 12.5},
+		{"american decimal", american, "1.234", 1.234},
+		{"american grouping", american, "1,234", 1234},
+		{"american reading a decimal comma", american, "12,5", 12.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.locale.ParseAmount(tt.value)
+			if err != nil || got != tt.want {
+				t.Errorf("ParseAmount(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAmountErrors(t *testing.T) {
+	german, _ := LookupLocale("de-DE")
+	tests := []struct {
+		locale Locale
+		value  string
+	}{
+		{Locale{}, ""},
+		{Locale{}, "EUR"},
+		{Locale{}, "12a34"},
+		{Locale{}, "1/2"},
+		{german, "1,2,3"},
+	}
+	for _, tt := range tests {
+		if got, err := tt.locale.ParseAmount(tt.value); err == nil {
+			t.Errorf("ParseAmount(%q) = %v, want an error", tt.value, got)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	british, _ := LookupLocale("en-GB")
+	american, _ := LookupLocale("en-US")
+	tests := []struct {
+		name   string
+		locale Locale
+		value  string
+		want   time.Time
+	}{
+		{"ISO", Locale{}, "2024-01-15", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"ISO slashes", american, "2024/01/15", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"RFC 3339", Locale{}, "2024-01-15T10:30:00+02:00", time.Date(2024, 1, 15, 8, 30, 0, 0, time.UTC)},
+		{"with time", Locale{}, "2024-01-15 10:30", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{"with seconds", Locale{}, "15.01.2024 07:05:09", time.Date(2024, 1, 15, 7, 5, 9, 0, time.UTC)},
+		{"dotted is day-first", Locale{}, "03.04.2024", time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)},
+		{"day above 12", Locale{}, "13/01/2024", time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"month-first day above 12", Locale{}, "01/13/2024", time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"same day and month", Locale{}, "05/05/2024", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"british order", british, "03/04/2024", time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)},
+		{"american order", american, "03/04/2024", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"two-digit year", british, "15/01/24", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"two-digit year before 2000", british, "15-01-99", time.Date(1999, 1, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.locale.ParseDate(tt.value)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string // part of the error message
+	}{
+		{"ambiguous", "03/04/2024", "ambiguous date"},
+		{"ambiguous two-digit year", "1/2/24", "ambiguous date"},
+		{"not a date", "yesterday", "unable to parse"},
+		{"three-digit year", "15/01/224", "unable to parse"},
+		{"no such day", "31/02/2024", "invalid date"},
+		{"no such month", "2024-13-01", "invalid date"},
+		{"no such hour", "2024-01-15 25:00", "invalid date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Locale{}.ParseDate(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseDate(%q) error = %v, want %q", tt.value, err, tt.want)
+			}
+		})
+	}
+}
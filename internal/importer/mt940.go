@@ -0,0 +1,271 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mt940Field is a ":tag:" field with its continuation lines.
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+var (
+	mt940TagPattern     = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+	mt940LinePattern    = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+,\d*)([NFS][A-Z0-9]{3})([^/]*)(?://(.*))?$`)
+	mt940BalancePattern = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+	mt940CodedPattern   = regexp.MustCompile(`/([A-Z]{3,4})/`)
+	mt940PurposePattern = regexp.MustCompile(`SVWZ\+(.*?)(?:(?:EREF|KREF|MREF|CRED|DEBT|ABWA|ABWE|IBAN|BIC)\+|$)`)
+)
+
+// ParseMT940 reads a SWIFT MT940 customer statement, with or without the
+// {1:}{2:}{4: block envelope. Counterparty and remittance information come
+// from the :86: field in either the German "?20" subfield layout or the
+// "/NAME/.../REMI/..." layout; other banks' free text becomes the memo.
+func ParseMT940(r io.Reader) ([]Statement, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MT940 file: %w", err)
+	}
+	var statements []Statement
+	var fields []mt940Field
+	for _, line := range strings.Split(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if i := strings.Index(line, "{4:"); i >= 0 {
+			line = line[i+3:] // header blocks precede the text block
+		}
+		if line == "-" || line == "-}" || strings.HasPrefix(line, "-}") {
+			continue // end of a message, the next :20: starts a new statement
+		}
+		if m := mt940TagPattern.FindStringSubmatch(line); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: m[2]})
+			continue
+		}
+		if len(fields) > 0 && line != "" && !strings.HasPrefix(line, "{") {
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+
+	var statement *Statement
+	var last *Transaction
+	for _, field := range fields {
+		switch field.tag {
+		case "20":
+			if statement != nil {
+				statements = append(statements, *statement)
+			}
+			statement = &Statement{}
+			last = nil
+		case "25":
+			if statement != nil {
+				statement.BankID, statement.Account = splitMT940Account(field.value)
+			}
+		case "60F", "60M":
+			if statement == nil {
+				continue
+			}
+			if _, currency, _, err := parseMT940Balance(field.value); err == nil {
+				statement.Currency = currency
+			}
+		case "62F", "62M":
+			if statement == nil {
+				continue
+			}
+			amount, currency, date, err := parseMT940Balance(field.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid closing balance %q: %w", field.value, err)
+			}
+			statement.LedgerBalance, statement.BalanceDate = &amount, &date
+			if statement.Currency == "" {
+				statement.Currency = currency
+			}
+		case "61":
+			if statement == nil {
+				return nil, fmt.Errorf("statement line before the :20: field")
+			}
+			txn, err := parseMT940Line(field.value)
+			if err != nil {
+				return nil, err
+			}
+			txn.Currency = statement.Currency
+			statement.Transactions = append(statement.Transactions, txn)
+			last = &statement.Transactions[len(statement.Transactions)-1]
+			if statement.Start == nil || txn.Date.Before(*statement.Start) {
+				statement.Start = &txn.Date
+			}
+			if statement.End == nil || txn.Date.After(*statement.End) {
+				statement.End = &txn.Date
+			}
+		case "86":
+			if last != nil {
+				name, memo := parseMT940Information(field.value)
+				if name != "" {
+					last.Name = name
+				}
+				if memo != "" {
+					last.Memo = memo
+				}
+				last = nil
+			}
+		}
+	}
+	if statement != nil {
+		statements = append(statements, *statement)
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("not an MT940 file: no :20: statement found")
+	}
+	return statements, nil
+}
+
+// splitMT940Account separates "BLZ/account" from the :25: field; IBANs
+// are returned whole.
+func splitMT940Account(value string) (string, string) {
+	value = strings.TrimSpace(value)
+	if bank, account, ok := strings.Cut(value, "/"); ok {
+		return strings.TrimSpace(bank), strings.TrimSpace(account)
+	}
+	return "", value
+}
+
+func parseMT940Balance(value string) (float64, string, time.Time, error) {
+	m := mt940BalancePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, "", time.Time{}, fmt.Errorf("unrecognised balance")
+	}
+	date, err := time.Parse("060102", m[2])
+	if err != nil {
+		return 0, "", time.Time{}, err
+	}
+	amount, err := parseMT940Amount(m[4])
+	if err != nil {
+		return 0, "", time.Time{}, err
+	}
+	if m[1] == "D" {
+		amount = -amount
+	}
+	return amount, strings.ToLower(m[3]), date, nil
+}
+
+// parseMT940Line reads a :61: statement line: value date, optional booking
+// date (MMDD), debit/credit mark, amount, transaction type, the customer's
+// reference and, after "//", the bank's reference. A supplementary line may
+// follow and serves as the name when no :86: field is present.
+func parseMT940Line(value string) (Transaction, error) {
+	first, supplementary, _ := strings.Cut(value, "\n")
+	m := mt940LinePattern.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return Transaction{}, fmt.Errorf("invalid :61: statement line %q", first)
+	}
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("invalid :61: value date %q", m[1])
+	}
+	date := valueDate
+	if m[2] != "" {
+		// the booking date has no year: take the one closest to the value date
+		month, _ := strconv.Atoi(m[2][:2])
+		day, _ := strconv.Atoi(m[2][2:])
+		date = time.Date(valueDate.Year(), time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if date.Sub(valueDate) > 180*24*time.Hour {
+			date = date.AddDate(-1, 0, 0)
+		} else if valueDate.Sub(date) > 180*24*time.Hour {
+			date = date.AddDate(1, 0, 0)
+		}
+	}
+	amount, err := parseMT940Amount(m[5])
+	if err != nil {
+		return Transaction{}, err
+	}
+	// debits and reversed credits take money out of the account
+	if m[3] == "D" || m[3] == "RC" {
+		amount = -amount
+	}
+	txn := Transaction{Date: date, Amount: amount, Type: m[6], Name: strings.TrimSpace(supplementary)}
+	customerRef := strings.TrimSpace(m[7])
+	switch bankRef := strings.TrimSpace(m[8]); {
+	case bankRef != "":
+		txn.ID = bankRef
+	case customerRef != "" && customerRef != "NONREF":
+		txn.ID = customerRef
+	}
+	return txn, nil
+}
+
+func parseMT940Amount(value string) (float64, error) {
+	amount, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid MT940 amount: %q", value)
+	}
+	return amount, nil
+}
+
+// parseMT940Information returns the counterparty name and the remittance
+// information of an :86: field.
+func parseMT940Information(value string) (string, string) {
+	text := strings.ReplaceAll(value, "\n", "")
+	// German banks: "166?00GUTSCHRIFT?20remittance?21...?32name?33name"
+	if len(text) > 4 && isDigits(text[:3]) && !isAlphanumeric(text[3]) {
+		separator := string(text[3])
+		var name, memo, booking string
+		for _, sub := range strings.Split(text[4:], separator) {
+			if len(sub) < 2 || !isDigits(sub[:2]) {
+				continue
+			}
+			code, content := sub[:2], strings.TrimSpace(sub[2:])
+			switch {
+			case code == "00":
+				booking = content
+			case code >= "20" && code <= "29", code >= "60" && code <= "63":
+				memo += content
+			case code == "32" || code == "33":
+				name += content
+			}
+		}
+		if name == "" {
+			name = booking
+		}
+		if m := mt940PurposePattern.FindStringSubmatch(memo); m != nil {
+			memo = m[1] // SEPA purpose without the EREF+/MREF+ references
+		}
+		return strings.TrimSpace(name), strings.TrimSpace(memo)
+	}
+	// "/CODE/value" layout, used by Dutch and other banks
+	if matches := mt940CodedPattern.FindAllStringSubmatchIndex(text, -1); len(matches) > 0 && matches[0][0] == 0 {
+		values := make(map[string]string)
+		for i, m := range matches {
+			end := len(text)
+			if i+1 < len(matches) {
+				end = matches[i+1][0]
+			}
+			code := text[m[2]:m[3]]
+			if _, seen := values[code]; !seen {
+				values[code] = strings.Trim(text[m[1]:end], "/ ")
+			}
+		}
+		name := values["NAME"]
+		if parts := strings.Split(values["CNTP"], "/"); name == "" && len(parts) >= 3 {
+			name = strings.TrimSpace(parts[2]) // account/BIC/name/city
+		}
+		return name, values["REMI"]
+	}
+	return "", strings.TrimSpace(text)
+}
+
+func isDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const mt940Statement = "{1:F01COBADEFFAXXX0000000000}{2:O9400000000000COBADEFFXXXX0000000000000000000000N}{4:\r\n" +
+	":20:STARTUMS\r\n" +
+	":25:37040044/0532013000\r\n" +
+	":28C:1/1\r\n" +
+	":60F:C240301EUR1000,00\r\n" +
+	":61:2403050305DR100,00NTRFNONREF//B1\r\n" +
+	":86:177?00SEPA-UEBERWEISUNG?20EREF+E2E-1 SVWZ+Rent Ma?21rch?32Landlord G?33mbH\r\n" +
+	":61:2403060306C25,50NTRFCUST-1\r\n" +
+	":86:/NAME/Employer/REMI/Salary bonus/EREF/X1\r\n" +
+	":61:240307RC5,00NMSCNONREF\r\n" +
+	"Chargeback fee\r\n" +
+	":61:2312310102D7,00NMSCNONREF\r\n" +
+	":86:Card payment at ki\r\n" +
+	"osk\r\n" +
+	":62F:C240331EUR918,50\r\n" +
+	"-}\r\n"
+
+func TestParseMT940(t *testing.T) {
+	statements, err := ParseMT940(strings.NewReader(mt940Statement))
+	if err != nil {
+		t.Fatalf("ParseMT940: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.BankID != "37040044" || s.Account != "0532013000" || s.Currency != "eur" {
+		t.Errorf("statement header = %q/%q/%q", s.BankID, s.Account, s.Currency)
+	}
+	if s.LedgerBalance == nil || *s.LedgerBalance != 918.5 {
+		t.Errorf("ledger balance = %v, want 918.5", s.LedgerBalance)
+	}
+
+	want := []Transaction{
+		{ID: "B1", Type: "NTRF", Amount: -100, Name: "Landlord GmbH", Memo: "Rent March", Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{ID: "CUST-1", Type: "NTRF", Amount: 25.5, Name: "Employer", Memo: "Salary bonus", Date: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{Type: "NMSC", Amount: -5, Name: "Chargeback fee", Date: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+		// booked in the new year, valued in the old one
+		{Type: "NMSC", Amount: -7, Memo: "Card payment at kiosk", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(s.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(s.Transactions), len(want))
+	}
+	for i, w := range want {
+		got := s.Transactions[i]
+		if got.ID != w.ID || got.Type != w.Type || got.Amount != w.Amount || got.Name != w.Name ||
+			got.Memo != w.Memo || !got.Date.Equal(w.Date) || got.Currency != "eur" {
+			t.Errorf("transaction %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if s.Start == nil || !s.Start.Equal(want[3].Date) || s.End == nil || !s.End.Equal(want[2].Date) {
+		t.Errorf("statement period = %v - %v", s.Start, s.End)
+	}
+}
+
+func TestParseMT940LineSign(t *testing.T) {
+	tests := []struct {
+		mark string
+		want float64
+	}{
+		{"D", -12.3},
+		{"C", 12.3},
+		{"RD", 12.3}, // reversed debit returns money
+		{"RC", -12.3},
+	}
+	for _, tt := range tests {
+		txn, err := parseMT940Line("240102" + tt.mark + "12,30NTRFNONREF")
+		if err != nil || txn.Amount != tt.want {
+			t.Errorf("mark %s: amount = %v, %v; want %v", tt.mark, txn.Amount, err, tt.want)
+		}
+	}
+}
+
+func TestParseMT940Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"line before statement", ":61:240102D1,00NTRFNONREF\n"},
+		{"bad line", ":20:X\n:61:garbage\n"},
+		{"bad closing balance", ":20:X\n:62F:X240331EUR1,00\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMT940(strings.NewReader(tt.content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
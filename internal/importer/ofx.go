@@ -0,0 +1,262 @@
+// Package importer parses bank statement files into transactions that the
+// API turns into expenses.
+package importer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statement is one account statement from a bank file.
+type Statement struct {
+	Account       string        `json:"account"`
+	BankID        string        `json:"bankId,omitempty"`
+	Currency      string        `json:"currency"`
+	LedgerBalance *float64      `json:"ledgerBalance,omitempty"`
+	BalanceDate   *time.Time    `json:"balanceDate,omitempty"`
+	Start         *time.Time    `json:"start,omitempty"`
+	End           *time.Time    `json:"end,omitempty"`
+	Transactions  []Transaction `json:"transactions"`
+	Invalid       []EntryError  `json:"invalid,omitempty"` // entries left out because they couldn't be read
+}
+
+// EntryError is a statement entry that couldn't be read. The rest of the
+// statement is still returned.
+type EntryError struct {
+	Index int    `json:"index"`        // position among the statement's entries, from 1
+	ID    string `json:"id,omitempty"` // bank reference, when it could be read
+	Error string `json:"error"`
+}
+
+// Transaction is a single statement line. Amount is negative for money
+// leaving the account, matching the sign of expenses.
+type Transaction struct {
+	ID       string    `json:"id"` // bank-assigned reference (FITID, AcctSvcrRef), stable across downloads
+	Type     string    `json:"type,omitempty"`
+	Date     time.Time `json:"date"`
+	Amount   float64   `json:"amount"`
+	Currency string    `json:"currency,omitempty"` // set when the entry states its own currency
+	Name     string    `json:"name"`               // counterparty
+	Memo     string    `json:"memo,omitempty"`     // remittance information
+	Reversal bool      `json:"reversal,omitempty"` // the bank marks the booking as reversing an earlier one
+}
+
+// ofxNode is an element of the OFX document. Leaves carry a value, aggregates
+// carry children.
+type ofxNode struct {
+	name     string
+	value    string
+	children []*ofxNode
+}
+
+func (n *ofxNode) child(name string) *ofxNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// text returns the value of the leaf at the given path below n.
+func (n *ofxNode) text(path ...string) string {
+	node := n
+	for _, name := range path {
+		if node = node.child(name); node == nil {
+			return ""
+		}
+	}
+	return node.value
+}
+
+// findAll collects every node with the name below n, depth first.
+func (n *ofxNode) findAll(name string) []*ofxNode {
+	var found []*ofxNode
+	for _, c := range n.children {
+		if c.name == name {
+			found = append(found, c)
+		}
+		found = append(found, c.findAll(name)...)
+	}
+	return found
+}
+
+// ParseOFX reads an OFX or QFX file, either OFX 1.x (SGML, where leaf
+// elements are usually not closed) or OFX 2.x (XML). Bank and credit card
+// statements are both returned.
+func ParseOFX(r io.Reader) ([]Statement, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OFX file: %w", err)
+	}
+	content := string(raw)
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("not an OFX file: missing <OFX> element")
+	}
+	root, err := parseOFXTree(content[start:])
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []Statement
+	for _, name := range []string{"STMTRS", "CCSTMTRS"} {
+		for _, node := range root.findAll(name) {
+			statement, err := parseOFXStatement(node)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement)
+		}
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("OFX file contains no bank or credit card statement")
+	}
+	return statements, nil
+}
+
+var ofxTagPattern = regexp.MustCompile(`<(/?)([A-Za-z0-9._]+)[^>]*>`)
+
+// parseOFXTree builds the element tree. An opening tag followed by text is a
+// leaf whether or not it is closed, and a self-closing tag is an empty leaf;
+// anything else opens an aggregate. Closing tags pop back to the matching
+// aggregate, which tolerates the unclosed leaves of SGML files.
+func parseOFXTree(content string) (*ofxNode, error) {
+	root := &ofxNode{name: "#root"}
+	stack := []*ofxNode{root}
+	matches := ofxTagPattern.FindAllStringSubmatchIndex(content, -1)
+	for i, m := range matches {
+		closing := content[m[2]:m[3]] == "/"
+		name := strings.ToUpper(content[m[4]:m[5]])
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		if closing {
+			for j := len(stack) - 1; j > 0; j-- {
+				if stack[j].name == name {
+					stack = stack[:j]
+					break
+				}
+			}
+			continue
+		}
+		parent := stack[len(stack)-1]
+		node := &ofxNode{name: name}
+		parent.children = append(parent.children, node)
+		if strings.HasSuffix(content[m[0]:m[1]], "/>") {
+			continue
+		}
+		if value := strings.TrimSpace(content[m[1]:end]); value != "" {
+			node.value = html.UnescapeString(value)
+			continue
+		}
+		stack = append(stack, node)
+	}
+	ofx := root.child("OFX")
+	if ofx == nil {
+		return nil, fmt.Errorf("invalid OFX file: no <OFX> element")
+	}
+	return ofx, nil
+}
+
+func parseOFXStatement(node *ofxNode) (Statement, error) {
+	statement := Statement{Currency: strings.ToLower(node.text("CURDEF"))}
+	if account := node.child("BANKACCTFROM"); account != nil {
+		statement.Account = account.text("ACCTID")
+		statement.BankID = account.text("BANKID")
+	} else if account := node.child("CCACCTFROM"); account != nil {
+		statement.Account = account.text("ACCTID")
+	}
+	if balance := node.child("LEDGERBAL"); balance != nil {
+		if amount, err := parseOFXAmount(balance.text("BALAMT")); err == nil {
+			statement.LedgerBalance = &amount
+		}
+		if date, err := ParseOFXDate(balance.text("DTASOF")); err == nil {
+			statement.BalanceDate = &date
+		}
+	}
+	list := node.child("BANKTRANLIST")
+	if list == nil {
+		return statement, nil
+	}
+	if date, err := ParseOFXDate(list.text("DTSTART")); err == nil {
+		statement.Start = &date
+	}
+	if date, err := ParseOFXDate(list.text("DTEND")); err == nil {
+		statement.End = &date
+	}
+	index := 0
+	for _, entry := range list.children {
+		if entry.name != "STMTTRN" {
+			continue
+		}
+		index++
+		date, err := ParseOFXDate(entry.text("DTPOSTED"))
+		if err != nil {
+			statement.Invalid = append(statement.Invalid, EntryError{Index: index, ID: entry.text("FITID"), Error: "invalid DTPOSTED: " + err.Error()})
+			continue
+		}
+		amount, err := parseOFXAmount(entry.text("TRNAMT"))
+		if err != nil {
+			statement.Invalid = append(statement.Invalid, EntryError{Index: index, ID: entry.text("FITID"), Error: fmt.Sprintf("invalid TRNAMT: %q", entry.text("TRNAMT"))})
+			continue
+		}
+		name := entry.text("NAME")
+		if name == "" {
+			name = entry.text("PAYEE", "NAME")
+		}
+		statement.Transactions = append(statement.Transactions, Transaction{
+			ID:     entry.text("FITID"),
+			Type:   entry.text("TRNTYPE"),
+			Date:   date,
+			Amount: amount,
+			Name:   name,
+			Memo:   entry.text("MEMO"),
+		})
+	}
+	return statement, nil
+}
+
+// parseOFXAmount accepts a decimal comma, which some banks emit, and
+// thousands separators: of "," and "." the last one is the decimal point.
+func parseOFXAmount(value string) (float64, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "+")
+	comma, dot := strings.LastIndex(value, ","), strings.LastIndex(value, ".")
+	switch {
+	case comma > dot:
+		value = strings.ReplaceAll(value[:comma], ".", "") + "." + value[comma+1:]
+	case comma >= 0:
+		value = strings.ReplaceAll(value, ",", "")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+var ofxDatePattern = regexp.MustCompile(`^(\d{8})(\d{6})?(?:\.\d+)?(?:\[([+-]?\d+(?:\.\d+)?)(?::[^\]]*)?\])?`)
+
+// ParseOFXDate reads the OFX datetime format YYYYMMDD[HHMMSS[.XXX]][offset:TZ],
+// e.g. "20240115120000.000[-5:EST]". Without an offset the time is UTC.
+func ParseOFXDate(value string) (time.Time, error) {
+	m := ofxDatePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid OFX date: %q", value)
+	}
+	clock := m[2]
+	if clock == "" {
+		clock = "000000"
+	}
+	loc := time.UTC
+	if m[3] != "" {
+		hours, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid OFX timezone: %q", value)
+		}
+		loc = time.FixedZone("", int(hours*3600))
+	}
+	return time.ParseInLocation("20060102150405", m[1]+clock, loc)
+}
@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const ofxSGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<CURDEF>EUR
+<BANKACCTFROM>
+<BANKID>12345678
+<ACCTID>DE001
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240101
+<DTEND>20240131
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240115120000.000[-5:EST]
+<TRNAMT>-1,234.56
+<FITID>A1
+<NAME>Rent &amp; Co
+<MEMO>January
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240120
+<TRNAMT>+12,50
+<FITID>A2
+<PAYEE><NAME>Refund shop</PAYEE>
+<MEMO/>
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>yesterday
+<TRNAMT>-3.00
+<FITID>A3
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240125
+<TRNAMT>ten
+<FITID>A4
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL>
+<BALAMT>1.000,00
+<DTASOF>20240131
+</LEDGERBAL>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>`
+
+const ofxXML = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>USD</CURDEF>
+        <CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20240301</DTPOSTED>
+            <TRNAMT>-42.00</TRNAMT>
+            <FITID>C1</FITID>
+            <NAME>Grocer</NAME>
+            <MEMO/>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>`
+
+func TestParseOFX(t *testing.T) {
+	statements, err := ParseOFX(strings.NewReader(ofxSGML))
+	if err != nil {
+		t.Fatalf("ParseOFX: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.Account != "DE001" || s.BankID != "12345678" || s.Currency != "eur" {
+		t.Errorf("statement header = %q/%q/%q", s.Account, s.BankID, s.Currency)
+	}
+	if s.LedgerBalance == nil || *s.LedgerBalance != 1000 {
+		t.Errorf("ledger balance = %v, want 1000", s.LedgerBalance)
+	}
+
+	want := []Transaction{
+		{ID: "A1", Type: "DEBIT", Amount: -1234.56, Name: "Rent & Co", Memo: "January"},
+		{ID: "A2", Type: "CREDIT", Amount: 12.5, Name: "Refund shop"},
+	}
+	if len(s.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(s.Transactions), len(want))
+	}
+	for i, w := range want {
+		got := s.Transactions[i]
+		if got.ID != w.ID || got.Type != w.Type || got.Amount != w.Amount || got.Name != w.Name || got.Memo != w.Memo {
+			t.Errorf("transaction %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if want := time.Date(2024, 1, 15, 17, 0, 0, 0, time.UTC); !s.Transactions[0].Date.Equal(want) {
+		t.Errorf("date = %v, want %v", s.Transactions[0].Date, want)
+	}
+
+	if len(s.Invalid) != 2 {
+		t.Fatalf("got %d invalid entries, want 2", len(s.Invalid))
+	}
+	for i, w := range []EntryError{{Index: 3, ID: "A3"}, {Index: 4, ID: "A4"}} {
+		if s.Invalid[i].Index != w.Index || s.Invalid[i].ID != w.ID || s.Invalid[i].Error == "" {
+			t.Errorf("invalid entry %d = %+v, want index %d id %s", i, s.Invalid[i], w.Index, w.ID)
+		}
+	}
+}
+
+func TestParseOFXCreditCardXML(t *testing.T) {
+	statements, err := ParseOFX(strings.NewReader(ofxXML))
+	if err != nil {
+		t.Fatalf("ParseOFX: %v", err)
+	}
+	if len(statements) != 1 || len(statements[0].Transactions) != 1 {
+		t.Fatalf("got %+v, want one statement with one transaction", statements)
+	}
+	s := statements[0]
+	if s.Account != "4111" || s.Currency != "usd" {
+		t.Errorf("statement header = %q/%q", s.Account, s.Currency)
+	}
+	if txn := s.Transactions[0]; txn.Amount != -42 || txn.Name != "Grocer" || txn.Memo != "" {
+		t.Errorf("transaction = %+v", txn)
+	}
+}
+
+func TestParseOFXRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no OFX element", "Date,Amount\n2024-01-01,1.00\n"},
+		{"no statement", "<OFX><SIGNONMSGSRSV1></SIGNONMSGSRSV1></OFX>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseOFX(strings.NewReader(tt.content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseOFXAmount(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"-12.34", -12.34},
+		{"+12.34", 12.34},
+		{"-12,34", -12.34},
+		{"1,234.56", 1234.56},
+		{"-1.234,56", -1234.56},
+		{"1,234,567.89", 1234567.89},
+		{" 100 ", 100},
+	}
+	for _, tt := range tests {
+		got, err := parseOFXAmount(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("parseOFXAmount(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := parseOFXAmount("ten"); err == nil {
+		t.Error(`parseOFXAmount("ten") should fail`)
+	}
+}
+
+func TestParseOFXDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"20240115", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"20240115093000", time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)},
+		{"20240115093000.123", time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)},
+		{"20240115120000[-5:EST]", time.Date(2024, 1, 15, 17, 0, 0, 0, time.UTC)},
+		{"20240115120000[+5.5:IST]", time.Date(2024, 1, 15, 6, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseOFXDate(tt.value)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseOFXDate(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"", "2024-01-15", "20241315"} {
+		if _, err := ParseOFXDate(value); err == nil {
+			t.Errorf("ParseOFXDate(%q) should fail", value)
+		}
+	}
+}
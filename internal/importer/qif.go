@@ -0,0 +1,285 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field orders of QIF dates, which carry no marker of their own. Year-first
+// dates (2024-01-15) are always recognised.
+const (
+	QIFMonthFirst = DateOrderMDY
+	QIFDayFirst   = DateOrderDMY
+)
+
+// QIFAccount holds the transactions of one account section of a QIF file.
+// Files without !Account headers yield a single account without a name.
+type QIFAccount struct {
+	Name         string           `json:"name"`
+	Type         string           `json:"type"`
+	Transactions []QIFTransaction `json:"transactions"`
+}
+
+// QIFTransaction is a register entry. Category keeps Quicken's
+// "Parent:Child" subcategory path, and the class after "/" becomes tags.
+type QIFTransaction struct {
+	Date     time.Time  `json:"date"`
+	Amount   float64    `json:"amount"`
+	Payee    string     `json:"payee"`
+	Memo     string     `json:"memo,omitempty"`
+	Number   string     `json:"number,omitempty"`
+	Category string     `json:"category,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Transfer string     `json:"transfer,omitempty"` // account named by an L[Account] transfer
+	Status   string     `json:"status,omitempty"`   // cleared flag: "", "*"/"c" or "X"/"R"
+	Splits   []QIFSplit `json:"splits,omitempty"`
+}
+
+// QIFSplit is an S/E/$ split line of a transaction.
+type QIFSplit struct {
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Transfer string   `json:"transfer,omitempty"`
+	Memo     string   `json:"memo,omitempty"`
+	Amount   float64  `json:"amount"`
+}
+
+// qifEntry is a transaction before its date is resolved, since the field
+// order is only known once every date of the file has been seen.
+type qifEntry struct {
+	account int
+	date    string
+	txn     QIFTransaction
+}
+
+// register types holding cash transactions; investment and list sections
+// (!Type:Invst, !Type:Cat, !Type:Memorized, ...) are skipped
+var qifRegisterTypes = map[string]bool{"bank": true, "cash": true, "ccard": true, "oth a": true, "oth l": true}
+
+// ParseQIF reads the register sections of a QIF file. dateOrder is
+// QIFMonthFirst, QIFDayFirst or empty to detect it from the file, falling
+// back to Quicken's month-first order when every date is ambiguous.
+func ParseQIF(r io.Reader, dateOrder string) ([]QIFAccount, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var accounts []QIFAccount
+	var entries []qifEntry
+	current := -1           // index of the account receiving transactions
+	section := ""           // lowercase header of the current section
+	var account *QIFAccount // account described by an !Account block
+	var entry *qifEntry
+	var split *QIFSplit
+	lineNo := 0
+
+	accountIndex := func(name, kind string) int {
+		for i := range accounts {
+			if accounts[i].Name == name {
+				return i
+			}
+		}
+		accounts = append(accounts, QIFAccount{Name: name, Type: kind})
+		return len(accounts) - 1
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] == '!' {
+			header := strings.ToLower(strings.TrimSpace(line[1:]))
+			switch {
+			case header == "account":
+				section = header
+				account = &QIFAccount{}
+			case strings.HasPrefix(header, "type:"):
+				section = header
+				kind := strings.TrimSpace(strings.TrimPrefix(header, "type:"))
+				if qifRegisterTypes[kind] && current < 0 {
+					current = accountIndex("", strings.TrimSpace(line[len("!Type:"):]))
+				}
+			default: // !Option:AutoSwitch, !Clear:AutoSwitch and the like
+			}
+			entry, split = nil, nil
+			continue
+		}
+		code, value := line[0], strings.TrimSpace(line[1:])
+
+		if section == "account" {
+			switch code {
+			case 'N':
+				account.Name = value
+			case 'T':
+				account.Type = value
+			case '^':
+				current = accountIndex(account.Name, account.Type)
+			}
+			continue
+		}
+		if !qifRegisterTypes[strings.TrimSpace(strings.TrimPrefix(section, "type:"))] {
+			continue
+		}
+		if code == '^' {
+			if entry != nil {
+				if entry.date == "" {
+					return nil, fmt.Errorf("line %d: transaction without a date", lineNo)
+				}
+				entries = append(entries, *entry)
+			}
+			entry, split = nil, nil
+			continue
+		}
+		if entry == nil {
+			entry = &qifEntry{account: current}
+		}
+		txn := &entry.txn
+		var err error
+		switch code {
+		case 'D':
+			entry.date = value
+		case 'T', 'U':
+			if txn.Amount, err = ParseQIFAmount(value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		case 'P':
+			txn.Payee = value
+		case 'M':
+			txn.Memo = value
+		case 'N':
+			txn.Number = value
+		case 'C':
+			txn.Status = value
+		case 'L':
+			txn.Category, txn.Tags, txn.Transfer = parseQIFCategory(value)
+		case 'S':
+			txn.Splits = append(txn.Splits, QIFSplit{})
+			split = &txn.Splits[len(txn.Splits)-1]
+			split.Category, split.Tags, split.Transfer = parseQIFCategory(value)
+		case 'E':
+			if split != nil {
+				split.Memo = value
+			}
+		case '$':
+			if split == nil {
+				return nil, fmt.Errorf("line %d: split amount without a split category", lineNo)
+			}
+			if split.Amount, err = ParseQIFAmount(value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		default: // addresses (A), memorized amortisation fields and the like
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF file: %v", err)
+	}
+	if entry != nil && entry.date != "" { // last record without a closing ^
+		entries = append(entries, *entry)
+	}
+
+	if dateOrder == "" {
+		dateOrder = detectQIFDateOrder(entries)
+	}
+	for _, e := range entries {
+		date, err := ParseQIFDate(e.date, dateOrder)
+		if err != nil {
+			return nil, err
+		}
+		e.txn.Date = date
+		if e.account < 0 {
+			e.account = accountIndex("", "")
+		}
+		accounts[e.account].Transactions = append(accounts[e.account].Transactions, e.txn)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no transactions found in QIF file")
+	}
+	return accounts, nil
+}
+
+// parseQIFCategory splits an L or S field such as "Food:Groceries/Work" into
+// the category path and class tags. "[Savings]" names a transfer account.
+func parseQIFCategory(value string) (string, []string, string) {
+	category, class, _ := strings.Cut(value, "/")
+	var tags []string
+	for _, tag := range strings.Split(class, ":") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	category = strings.TrimSpace(category)
+	if strings.HasPrefix(category, "[") && strings.HasSuffix(category, "]") {
+		return "", tags, strings.TrimSpace(category[1 : len(category)-1])
+	}
+	return category, tags, ""
+}
+
+var qifDatePattern = regexp.MustCompile(`^(\d{1,4})[/.\-](\d{1,2})([/.\-'])(\d{1,4})$`)
+
+// ParseQIFDate reads the date styles written by QIF exporters: 01/15/2024,
+// 1/15'24 (Quicken's marker for years after 1999), 15.01.2024, 15-01-24 and
+// 2024-01-15. Two-digit years after "/" below 70 are in the 2000s.
+func ParseQIFDate(value, dateOrder string) (time.Time, error) {
+	m := qifDatePattern.FindStringSubmatch(strings.ReplaceAll(value, " ", ""))
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid QIF date: %q", value)
+	}
+	first, _ := strconv.Atoi(m[1])
+	second, _ := strconv.Atoi(m[2])
+	third, _ := strconv.Atoi(m[4])
+	var year, month, day int
+	switch {
+	case len(m[1]) == 4:
+		year, month, day = first, second, third
+	case dateOrder == QIFDayFirst:
+		day, month, year = first, second, third
+	default:
+		month, day, year = first, second, third
+	}
+	if len(m[1]) != 4 && len(m[4]) <= 2 {
+		if m[3] == "'" || year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day || int(date.Month()) != month {
+		return time.Time{}, fmt.Errorf("invalid QIF date: %q", value)
+	}
+	return date, nil
+}
+
+// detectQIFDateOrder looks for a date whose first field can only be a day.
+func detectQIFDateOrder(entries []qifEntry) string {
+	for _, e := range entries {
+		m := qifDatePattern.FindStringSubmatch(strings.ReplaceAll(e.date, " ", ""))
+		if m == nil || len(m[1]) == 4 {
+			continue
+		}
+		if first, _ := strconv.Atoi(m[1]); first > 12 {
+			return QIFDayFirst
+		}
+	}
+	return QIFMonthFirst
+}
+
+// ParseQIFAmount reads amounts with thousands separators in either style
+// ("1,234.56" or "1.234,56"). A lone comma followed by exactly three digits
+// is taken as a thousands separator.
+func ParseQIFAmount(value string) (float64, error) {
+	amount, err := Locale{}.ParseAmount(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid QIF amount: %q", value)
+	}
+	return amount, nil
+}
@@ -0,0 +1,220 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const qifAccounts = `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Account
+NVisa
+TCCard
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D01/15'24
+T-1,234.56
+PLandlord
+MJanuary rent
+N101
+C*
+LHousing:Rent/Home:Fixed
+^
+D1/20'24
+T-50.00
+PTransfer
+L[Savings]
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D02/03/2024
+T-100.00
+PSupermarket
+SFood:Groceries
+EWeekly shop
+$-80.00
+SHousehold/Home
+$-20.00
+^
+!Type:Cat
+NFood
+D
+E
+^
+`
+
+func TestParseQIF(t *testing.T) {
+	accounts, err := ParseQIF(strings.NewReader(qifAccounts), "")
+	if err != nil {
+		t.Fatalf("ParseQIF: %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("got %d accounts, want 2", len(accounts))
+	}
+	if accounts[0].Name != "Checking" || accounts[1].Name != "Visa" {
+		t.Fatalf("accounts = %q, %q", accounts[0].Name, accounts[1].Name)
+	}
+
+	checking := accounts[0].Transactions
+	if len(checking) != 2 {
+		t.Fatalf("got %d checking transactions, want 2", len(checking))
+	}
+	want := QIFTransaction{
+		Date:     time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Amount:   -1234.56,
+		Payee:    "Landlord",
+		Memo:     "January rent",
+		Number:   "101",
+		Category: "Housing:Rent",
+		Tags:     []string{"Home", "Fixed"},
+		Status:   "*",
+	}
+	if !reflect.DeepEqual(checking[0], want) {
+		t.Errorf("transaction = %+v, want %+v", checking[0], want)
+	}
+	if checking[1].Transfer != "Savings" || checking[1].Category != "" {
+		t.Errorf("transfer = %q, category = %q", checking[1].Transfer, checking[1].Category)
+	}
+
+	visa := accounts[1].Transactions
+	if len(visa) != 1 {
+		t.Fatalf("got %d card transactions, want 1", len(visa))
+	}
+	wantSplits := []QIFSplit{
+		{Category: "Food:Groceries", Memo: "Weekly shop", Amount: -80},
+		{Category: "Household", Tags: []string{"Home"}, Amount: -20},
+	}
+	if !reflect.DeepEqual(visa[0].Splits, wantSplits) {
+		t.Errorf("splits = %+v, want %+v", visa[0].Splits, wantSplits)
+	}
+}
+
+func TestParseQIFWithoutAccounts(t *testing.T) {
+	content := "!Type:Bank\r\nD15/01/2024\r\nT-9.99\r\nPCafe\r\n^\r\nD02/01/2024\r\nT-1.00\r\n"
+	accounts, err := ParseQIF(strings.NewReader(content), "")
+	if err != nil {
+		t.Fatalf("ParseQIF: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].Name != "" || accounts[0].Type != "Bank" {
+		t.Fatalf("accounts = %+v, want one unnamed Bank account", accounts)
+	}
+	txns := accounts[0].Transactions
+	if len(txns) != 2 {
+		t.Fatalf("got %d transactions, want 2 (the last one without ^)", len(txns))
+	}
+	// 15/01 can only be day-first, so 02/01 is the 2nd of January
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !txns[1].Date.Equal(want) {
+		t.Errorf("date = %v, want %v", txns[1].Date, want)
+	}
+}
+
+func TestParseQIFErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"only categories", "!Type:Cat\nNFood\n^\n"},
+		{"missing date", "!Type:Bank\nT-1.00\n^\n"},
+		{"bad amount", "!Type:Bank\nD01/02/2024\nTabc\n^\n"},
+		{"split amount without split", "!Type:Bank\nD01/02/2024\n$-1.00\n^\n"},
+		{"bad date", "!Type:Bank\nD31/31/2024\nT-1.00\n^\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseQIF(strings.NewReader(tt.content), ""); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseQIFDate(t *testing.T) {
+	tests := []struct {
+		value string
+		order string
+		want  time.Time
+	}{
+		{"01/15/2024", QIFMonthFirst, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"1/15'24", QIFMonthFirst, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{" 1/ 5'04", QIFMonthFirst, time.Date(2004, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"1/15/99", QIFMonthFirst, time.Date(1999, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"15.01.2024", QIFDayFirst, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"15-01-24", QIFDayFirst, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-15", QIFDayFirst, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-15", QIFMonthFirst, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		// ambiguous dates follow the requested order
+		{"03/04/2024", QIFMonthFirst, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"03/04/2024", QIFDayFirst, time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseQIFDate(tt.value, tt.order)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseQIFDate(%q, %q) = %v, %v; want %v", tt.value, tt.order, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"", "Jan 15 2024", "02/30/2024", "13/13/2024"} {
+		if _, err := ParseQIFDate(value, QIFMonthFirst); err == nil {
+			t.Errorf("ParseQIFDate(%q) should fail", value)
+		}
+	}
+}
+
+func TestDetectQIFDateOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		dates []string
+		want  string
+	}{
+		{"all ambiguous", []string{"01/02/2024", "03/04/2024"}, QIFMonthFirst},
+		{"day above 12", []string{"01/02/2024", "25/12/2024"}, QIFDayFirst},
+		{"month-first day above 12", []string{"12/25/2024"}, QIFMonthFirst},
+		{"year first is skipped", []string{"2024-12-25", "01/02/2024"}, QIFMonthFirst},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entries []qifEntry
+			for _, date := range tt.dates {
+				entries = append(entries, qifEntry{date: date})
+			}
+			if got := detectQIFDateOrder(entries); got != tt.want {
+				t.Errorf("detectQIFDateOrder = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQIFAmount(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"-1,234.56", -1234.56},
+		{"1.234,56", 1234.56},
+		{"1,234", 1234},
+		{"12,34", 12.34},
+		{"-0.5", -0.5},
+	}
+	for _, tt := range tests {
+		got, err := ParseQIFAmount(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseQIFAmount(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := ParseQIFAmount("abc"); err == nil {
+		t.Error(`ParseQIFAmount("abc") should fail`)
+	}
+}
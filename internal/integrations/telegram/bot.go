@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Bot sends messages through the Telegram Bot API. It is only used for
+// outgoing notifications; incoming messages are handled by the n8n workflow.
+type Bot struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewBotFromEnv returns a bot for TELEGRAM_BOT_TOKEN, or nil when unset.
+func NewBotFromEnv() *Bot {
+	token := strings.TrimSpace(os.Getenv("TELEGRAM_BOT_TOKEN"))
+	if token == "" {
+		return nil
+	}
+	return &Bot{
+		token:   token,
+		baseURL: "https://api.telegram.org",
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// SendMessage posts a plain text message to a chat.
+func (b *Bot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	payload, err := json.Marshal(map[string]any{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", b.baseURL, b.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("telegram sendMessage failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -285,6 +285,28 @@ func (s *Service) Revoke(ctx context.Context, userID uuid.UUID, linkID uuid.UUID
 	return nil
 }
 
+// ActiveChatIDs returns the chats of the user's linked, non-revoked links.
+func (s *Service) ActiveChatIDs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT DISTINCT chat_id FROM telegram_links
+        WHERE user_id = $1 AND chat_id IS NOT NULL AND revoked_at IS NULL
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list telegram chats: %w", err)
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("failed to scan telegram chat: %w", err)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, rows.Err()
+}
+
 func randomCode(length int) (string, error) {
 	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
 	bytes := make([]byte, length)
@@ -0,0 +1,225 @@
+package reports
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// Totals holds income and spend over some bucket of expenses.
+type Totals struct {
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"` // reported as a positive number
+	Net      float64 `json:"net"`
+	Count    int     `json:"count"`
+}
+
+func (t *Totals) add(amount float64) {
+	if amount < 0 {
+		t.Expenses -= amount
+	} else {
+		t.Income += amount
+	}
+	t.Count++
+}
+
+func (t *Totals) round() {
+	t.Income = round2(t.Income)
+	t.Expenses = round2(t.Expenses)
+	t.Net = round2(t.Income - t.Expenses)
+}
+
+// TagTotal is the spend and income carrying one tag.
+type TagTotal struct {
+	Tag string `json:"tag"`
+	Totals
+}
+
+// PeriodTotal covers a calendar month or a budgeting period [Start, End).
+type PeriodTotal struct {
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Totals
+}
+
+// DayTotal is one cell of the daily heatmap.
+type DayTotal struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Totals
+}
+
+// YearOverYearMonth compares one month of a year with the year before.
+type YearOverYearMonth struct {
+	Month         int      `json:"month"`
+	Current       Totals   `json:"current"`
+	Previous      Totals   `json:"previous"`
+	ExpenseChange float64  `json:"expenseChange"`
+	ChangePercent *float64 `json:"changePercent,omitempty"` // nil when the previous year had no spend
+}
+
+// Summary is income against expenses over a date range.
+type Summary struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Totals
+	SavingsRate *float64 `json:"savingsRate,omitempty"` // net as a share of income
+}
+
+// TagTotals sums expenses per tag; an expense with several tags counts
+// towards each of them. Split line items use their own tags.
+func TagTotals(expenses []storage.Expense, from, to time.Time) []TagTotal {
+	byTag := make(map[string]*TagTotal)
+	for _, expense := range expenses {
+		if !InRange(expense.Date, from, to) {
+			continue
+		}
+		for _, portion := range expense.Allocations() {
+			for _, tag := range portion.Tags {
+				key := strings.ToLower(tag)
+				total, ok := byTag[key]
+				if !ok {
+					total = &TagTotal{Tag: tag}
+					byTag[key] = total
+				}
+				total.add(portion.Amount)
+			}
+		}
+	}
+	totals := make([]TagTotal, 0, len(byTag))
+	for _, total := range byTag {
+		total.round()
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Expenses != totals[j].Expenses {
+			return totals[i].Expenses > totals[j].Expenses
+		}
+		return totals[i].Tag < totals[j].Tag
+	})
+	return totals
+}
+
+// MonthTotals sums expenses per calendar month, oldest first.
+func MonthTotals(expenses []storage.Expense, from, to time.Time) []PeriodTotal {
+	byMonth := make(map[string]*PeriodTotal)
+	for _, expense := range expenses {
+		if !InRange(expense.Date, from, to) {
+			continue
+		}
+		d := expense.Date.UTC()
+		label := d.Format("2006-01")
+		total, ok := byMonth[label]
+		if !ok {
+			start := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+			total = &PeriodTotal{Label: label, Start: start, End: start.AddDate(0, 1, 0)}
+			byMonth[label] = total
+		}
+		total.add(expense.Amount)
+	}
+	return sortedPeriods(byMonth)
+}
+
+// PeriodTotals sums the last count budgeting periods up to and including the
+// one containing now. Periods start on the user's start day.
+func PeriodTotals(expenses []storage.Expense, startDay int, now time.Time, count int) []PeriodTotal {
+	periods := make([]PeriodTotal, count)
+	start, end := storage.PeriodBounds(now, startDay)
+	for i := count - 1; i >= 0; i-- {
+		periods[i] = PeriodTotal{Label: start.Format("2006-01-02"), Start: start, End: end}
+		end = start
+		start, _ = storage.PeriodBounds(start.AddDate(0, 0, -1), startDay)
+	}
+	for _, expense := range expenses {
+		for i := range periods {
+			if InRange(expense.Date, periods[i].Start, periods[i].End) {
+				periods[i].add(expense.Amount)
+				break
+			}
+		}
+	}
+	for i := range periods {
+		periods[i].round()
+	}
+	return periods
+}
+
+// YearOverYear compares each month of year with the same month a year earlier.
+func YearOverYear(expenses []storage.Expense, year int) []YearOverYearMonth {
+	months := make([]YearOverYearMonth, 12)
+	for i := range months {
+		months[i].Month = i + 1
+	}
+	for _, expense := range expenses {
+		d := expense.Date.UTC()
+		switch d.Year() {
+		case year:
+			months[d.Month()-1].Current.add(expense.Amount)
+		case year - 1:
+			months[d.Month()-1].Previous.add(expense.Amount)
+		}
+	}
+	for i := range months {
+		m := &months[i]
+		m.Current.round()
+		m.Previous.round()
+		m.ExpenseChange = round2(m.Current.Expenses - m.Previous.Expenses)
+		if m.Previous.Expenses != 0 {
+			pct := round2(m.ExpenseChange / m.Previous.Expenses * 100)
+			m.ChangePercent = &pct
+		}
+	}
+	return months
+}
+
+// DailyTotals returns one entry per day that has expenses, for heatmaps.
+func DailyTotals(expenses []storage.Expense, from, to time.Time) []DayTotal {
+	byDay := make(map[string]*DayTotal)
+	for _, expense := range expenses {
+		if !InRange(expense.Date, from, to) {
+			continue
+		}
+		key := expense.Date.UTC().Format("2006-01-02")
+		total, ok := byDay[key]
+		if !ok {
+			total = &DayTotal{Date: key}
+			byDay[key] = total
+		}
+		total.add(expense.Amount)
+	}
+	days := make([]DayTotal, 0, len(byDay))
+	for _, total := range byDay {
+		total.round()
+		days = append(days, *total)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// Summarize nets income against expenses within [from, to).
+func Summarize(expenses []storage.Expense, from, to time.Time) Summary {
+	summary := Summary{From: from, To: to}
+	for _, expense := range expenses {
+		if InRange(expense.Date, from, to) {
+			summary.add(expense.Amount)
+		}
+	}
+	summary.round()
+	if summary.Income > 0 {
+		rate := round2(summary.Net / summary.Income * 100)
+		summary.SavingsRate = &rate
+	}
+	return summary
+}
+
+func sortedPeriods(byLabel map[string]*PeriodTotal) []PeriodTotal {
+	periods := make([]PeriodTotal, 0, len(byLabel))
+	for _, total := range byLabel {
+		total.round()
+		periods = append(periods, *total)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods
+}
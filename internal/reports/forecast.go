@@ -0,0 +1,158 @@
+package reports
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// ForecastOptions controls a cash-flow projection. Cancel holds recurring
+// rule IDs and SkipCategories lowercased categories left out of the what-if
+// scenario.
+type ForecastOptions struct {
+	Now            time.Time
+	StartDay       int
+	Periods        int
+	Window         int // trailing full periods used to average non-recurring spend
+	Cancel         map[string]bool
+	SkipCategories map[string]bool
+}
+
+// ForecastPeriod is the projection for one budgeting period.
+type ForecastPeriod struct {
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	RecurringIncome   float64   `json:"recurringIncome"`
+	RecurringExpenses float64   `json:"recurringExpenses"`
+	AverageIncome     float64   `json:"averageIncome"`
+	AverageExpenses   float64   `json:"averageExpenses"`
+	Net               float64   `json:"net"`
+	Balance           float64   `json:"balance"`
+	BaselineBalance   float64   `json:"baselineBalance"` // running balance without what-if toggles
+}
+
+// CategoryAverage is the average non-recurring amount per period of a category.
+type CategoryAverage struct {
+	Category string  `json:"category"`
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"`
+}
+
+// Forecast is a projected running balance over the coming periods.
+type Forecast struct {
+	StartingBalance  float64           `json:"startingBalance"`
+	Periods          []ForecastPeriod  `json:"periods"`
+	CategoryAverages []CategoryAverage `json:"categoryAverages"`
+	BaselineEnding   float64           `json:"baselineEnding"`
+	ScenarioEnding   float64           `json:"scenarioEnding"`
+	Difference       float64           `json:"difference"` // scenario minus baseline
+	Cancelled        []string          `json:"cancelled"`  // names of cancelled recurring rules
+}
+
+// BuildForecast projects the next periods from recurring rules plus the
+// trailing average of non-recurring spend. The current period is included
+// and only its remainder is projected.
+func BuildForecast(expenses []storage.Expense, rules []storage.RecurringExpense, opts ForecastOptions) Forecast {
+	forecast := Forecast{Periods: []ForecastPeriod{}, CategoryAverages: []CategoryAverage{}, Cancelled: []string{}}
+	for _, expense := range expenses {
+		if !expense.Date.After(opts.Now) {
+			forecast.StartingBalance += expense.Amount
+		}
+	}
+	forecast.StartingBalance = round2(forecast.StartingBalance)
+
+	currentStart, currentEnd := storage.PeriodBounds(opts.Now, opts.StartDay)
+	windowStart := currentStart
+	for i := 0; i < opts.Window; i++ {
+		windowStart, _ = storage.PeriodBounds(windowStart.AddDate(0, 0, -1), opts.StartDay)
+	}
+	averages := make(map[string]*CategoryAverage)
+	for _, expense := range expenses {
+		if expense.RecurringID != "" || !InRange(expense.Date, windowStart, currentStart) {
+			continue
+		}
+		for _, portion := range expense.Allocations() {
+			key := strings.ToLower(portion.Category)
+			avg, ok := averages[key]
+			if !ok {
+				avg = &CategoryAverage{Category: portion.Category}
+				averages[key] = avg
+			}
+			if portion.Amount < 0 {
+				avg.Expenses -= portion.Amount
+			} else {
+				avg.Income += portion.Amount
+			}
+		}
+	}
+	var avgIncome, avgExpenses, scenarioIncome, scenarioExpenses float64
+	for key, avg := range averages {
+		if opts.Window > 0 {
+			avg.Income /= float64(opts.Window)
+			avg.Expenses /= float64(opts.Window)
+		}
+		avgIncome += avg.Income
+		avgExpenses += avg.Expenses
+		if !opts.SkipCategories[key] {
+			scenarioIncome += avg.Income
+			scenarioExpenses += avg.Expenses
+		}
+		forecast.CategoryAverages = append(forecast.CategoryAverages, CategoryAverage{
+			Category: avg.Category,
+			Income:   round2(avg.Income),
+			Expenses: round2(avg.Expenses),
+		})
+	}
+	sort.Slice(forecast.CategoryAverages, func(i, j int) bool {
+		return forecast.CategoryAverages[i].Expenses > forecast.CategoryAverages[j].Expenses
+	})
+	for _, rule := range rules {
+		if opts.Cancel[rule.ID] {
+			forecast.Cancelled = append(forecast.Cancelled, rule.Name)
+		}
+	}
+
+	balance, baseline := forecast.StartingBalance, forecast.StartingBalance
+	start, end := currentStart, currentEnd
+	for i := 0; i < opts.Periods; i++ {
+		period := ForecastPeriod{Start: start, End: end}
+		from := start
+		share := 1.0
+		if i == 0 {
+			from = opts.Now
+			share = float64(end.Sub(opts.Now)) / float64(end.Sub(start))
+		}
+		var baselineNet float64
+		for _, rule := range rules {
+			for _, occurrence := range storage.ProjectRecurring(rule, from, end) {
+				baselineNet += occurrence.Amount
+				if opts.Cancel[rule.ID] {
+					continue
+				}
+				if occurrence.Amount < 0 {
+					period.RecurringExpenses -= occurrence.Amount
+				} else {
+					period.RecurringIncome += occurrence.Amount
+				}
+			}
+		}
+		baselineNet += (avgIncome - avgExpenses) * share
+		period.AverageIncome = round2(scenarioIncome * share)
+		period.AverageExpenses = round2(scenarioExpenses * share)
+		period.RecurringIncome = round2(period.RecurringIncome)
+		period.RecurringExpenses = round2(period.RecurringExpenses)
+		period.Net = round2(period.RecurringIncome + period.AverageIncome - period.RecurringExpenses - period.AverageExpenses)
+		balance += period.Net
+		baseline += baselineNet
+		period.Balance = round2(balance)
+		period.BaselineBalance = round2(baseline)
+		forecast.Periods = append(forecast.Periods, period)
+		start, end = storage.PeriodBounds(end, opts.StartDay)
+	}
+	forecast.ScenarioEnding = round2(balance)
+	forecast.BaselineEnding = round2(baseline)
+	forecast.Difference = round2(balance - baseline)
+	return forecast
+}
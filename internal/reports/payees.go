@@ -0,0 +1,56 @@
+package reports
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// PayeeTotal is the spend and income with one payee. Expenses without a
+// known payee are grouped by their normalized name and have no PayeeID.
+type PayeeTotal struct {
+	PayeeID string `json:"payeeId,omitempty"`
+	Name    string `json:"name"`
+	Totals
+}
+
+// PayeeTotals sums expenses per payee within [from, to).
+func PayeeTotals(expenses []storage.Expense, payees []storage.Payee, from, to time.Time) []PayeeTotal {
+	names := make(map[string]string, len(payees))
+	for _, payee := range payees {
+		names[payee.ID] = payee.Name
+	}
+	byKey := make(map[string]*PayeeTotal)
+	for _, expense := range expenses {
+		if !InRange(expense.Date, from, to) {
+			continue
+		}
+		var key string
+		total := PayeeTotal{Name: expense.Name}
+		if name, ok := names[expense.PayeeID]; ok {
+			key = "id:" + expense.PayeeID
+			total.PayeeID, total.Name = expense.PayeeID, name
+		} else {
+			key = "name:" + storage.NormalizeName(expense.Name)
+		}
+		existing, ok := byKey[key]
+		if !ok {
+			existing = &total
+			byKey[key] = existing
+		}
+		existing.add(expense.Amount)
+	}
+	totals := make([]PayeeTotal, 0, len(byKey))
+	for _, total := range byKey {
+		total.round()
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Expenses != totals[j].Expenses {
+			return totals[i].Expenses > totals[j].Expenses
+		}
+		return totals[i].Name < totals[j].Name
+	})
+	return totals
+}
@@ -0,0 +1,71 @@
+package reports
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// Reimbursement tracks how much of a reimbursable expense has been paid back.
+type Reimbursement struct {
+	ExpenseID   string    `json:"expenseId"`
+	Name        string    `json:"name"`
+	Category    string    `json:"category"`
+	Currency    string    `json:"currency"`
+	Date        time.Time `json:"date"`
+	Amount      float64   `json:"amount"` // spent, as a positive number
+	Reimbursed  float64   `json:"reimbursed"`
+	Outstanding float64   `json:"outstanding"`
+	RefundIDs   []string  `json:"refundIds"`
+}
+
+// LinkedAmounts sums link amounts per original expense and per refund.
+func LinkedAmounts(links []storage.RefundLink) (byExpense, byRefund map[string]float64) {
+	byExpense = make(map[string]float64)
+	byRefund = make(map[string]float64)
+	for _, link := range links {
+		byExpense[link.ExpenseID] += link.Amount
+		byRefund[link.RefundID] += link.Amount
+	}
+	return byExpense, byRefund
+}
+
+// OutstandingReimbursements lists reimbursable expenses that have not been
+// fully paid back yet, oldest first.
+func OutstandingReimbursements(expenses []storage.Expense, links []storage.RefundLink) []Reimbursement {
+	refundIDs := make(map[string][]string)
+	for _, link := range links {
+		refundIDs[link.ExpenseID] = append(refundIDs[link.ExpenseID], link.RefundID)
+	}
+	reimbursed, _ := LinkedAmounts(links)
+
+	outstanding := []Reimbursement{}
+	for _, expense := range expenses {
+		if !expense.Reimbursable || expense.Amount >= 0 {
+			continue
+		}
+		item := Reimbursement{
+			ExpenseID:  expense.ID,
+			Name:       expense.Name,
+			Category:   expense.Category,
+			Currency:   expense.Currency,
+			Date:       expense.Date,
+			Amount:     round2(-expense.Amount),
+			Reimbursed: round2(reimbursed[expense.ID]),
+			RefundIDs:  refundIDs[expense.ID],
+		}
+		item.Outstanding = round2(item.Amount - item.Reimbursed)
+		if item.Outstanding <= 0 {
+			continue
+		}
+		if item.RefundIDs == nil {
+			item.RefundIDs = []string{}
+		}
+		outstanding = append(outstanding, item)
+	}
+	sort.Slice(outstanding, func(i, j int) bool {
+		return outstanding[i].Date.Before(outstanding[j].Date)
+	})
+	return outstanding
+}
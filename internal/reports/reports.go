@@ -0,0 +1,143 @@
+// Package reports aggregates decrypted expenses into server-side summaries.
+package reports
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// CategoryTotal is the spend and income booked against one category.
+type CategoryTotal struct {
+	Category string  `json:"category"`
+	Expenses float64 `json:"expenses"` // sum of negative portions, reported as a positive number
+	Refunds  float64 `json:"refunds"`  // linked refunds credited back to this category
+	NetSpend float64 `json:"netSpend"` // expenses minus refunds
+	Income   float64 `json:"income"`
+	Net      float64 `json:"net"`
+	Count    int     `json:"count"`
+}
+
+// InRange reports whether t lies within [from, to); zero bounds are open.
+func InRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+// CategoryTotals sums expenses per category. Split expenses contribute each
+// line item to its own category. Refunds linked to an expense are credited to
+// that expense's categories instead of being counted as income; any unlinked
+// remainder of a refund stays income in its own category.
+func CategoryTotals(expenses []storage.Expense, links []storage.RefundLink, from, to time.Time) []CategoryTotal {
+	byID := make(map[string]storage.Expense, len(expenses))
+	for _, expense := range expenses {
+		byID[expense.ID] = expense
+	}
+	linksByRefund := make(map[string][]storage.RefundLink)
+	for _, link := range links {
+		linksByRefund[link.RefundID] = append(linksByRefund[link.RefundID], link)
+	}
+
+	byCategory := make(map[string]*CategoryTotal)
+	totalFor := func(category string) *CategoryTotal {
+		key := strings.ToLower(category)
+		total, ok := byCategory[key]
+		if !ok {
+			total = &CategoryTotal{Category: category}
+			byCategory[key] = total
+		}
+		return total
+	}
+	for _, expense := range expenses {
+		if !InRange(expense.Date, from, to) {
+			continue
+		}
+		allocations := expense.Allocations()
+		if refundLinks := linksByRefund[expense.ID]; len(refundLinks) > 0 && expense.Amount > 0 {
+			var credited float64
+			for _, link := range refundLinks {
+				original, ok := byID[link.ExpenseID]
+				if !ok {
+					continue
+				}
+				for _, portion := range refundAllocations(original, link.Amount) {
+					total := totalFor(portion.Category)
+					total.Refunds += portion.Amount
+					total.Count++
+				}
+				credited += link.Amount
+			}
+			if credited >= expense.Amount {
+				continue
+			}
+			allocations = scaleAllocations(allocations, (expense.Amount-credited)/expense.Amount)
+		}
+		for _, portion := range allocations {
+			total := totalFor(portion.Category)
+			if portion.Amount < 0 {
+				total.Expenses -= portion.Amount
+			} else {
+				total.Income += portion.Amount
+			}
+			total.Count++
+		}
+	}
+	totals := make([]CategoryTotal, 0, len(byCategory))
+	for _, total := range byCategory {
+		total.Expenses = round2(total.Expenses)
+		total.Refunds = round2(total.Refunds)
+		total.Income = round2(total.Income)
+		total.NetSpend = round2(total.Expenses - total.Refunds)
+		total.Net = round2(total.Income + total.Refunds - total.Expenses)
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].NetSpend != totals[j].NetSpend {
+			return totals[i].NetSpend > totals[j].NetSpend
+		}
+		return totals[i].Category < totals[j].Category
+	})
+	return totals
+}
+
+// refundAllocations spreads a refunded amount over the spending line items of
+// the original expense, proportionally to their size.
+func refundAllocations(original storage.Expense, amount float64) []storage.ExpenseSplit {
+	var spent float64
+	for _, portion := range original.Allocations() {
+		if portion.Amount < 0 {
+			spent -= portion.Amount
+		}
+	}
+	if spent == 0 {
+		return []storage.ExpenseSplit{{Category: original.Category, Amount: amount}}
+	}
+	var credits []storage.ExpenseSplit
+	for _, portion := range original.Allocations() {
+		if portion.Amount < 0 {
+			credits = append(credits, storage.ExpenseSplit{Category: portion.Category, Amount: -portion.Amount / spent * amount})
+		}
+	}
+	return credits
+}
+
+func scaleAllocations(allocations []storage.ExpenseSplit, factor float64) []storage.ExpenseSplit {
+	scaled := make([]storage.ExpenseSplit, len(allocations))
+	for i, portion := range allocations {
+		portion.Amount *= factor
+		scaled[i] = portion
+	}
+	return scaled
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
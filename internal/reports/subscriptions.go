@@ -0,0 +1,163 @@
+package reports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/anomaly"
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// SubscriptionSuggestion is a recurring pattern found among expenses that
+// aren't linked to a rule yet. Amount is the median charge.
+type SubscriptionSuggestion struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Category    string    `json:"category"`
+	Currency    string    `json:"currency"`
+	Tags        []string  `json:"tags"`
+	Amount      float64   `json:"amount"`
+	Interval    string    `json:"interval"`
+	StartDate   time.Time `json:"startDate"`
+	LastDate    time.Time `json:"lastDate"`
+	NextDate    time.Time `json:"nextDate"`
+	Occurrences int       `json:"occurrences"`
+	ExpenseIDs  []string  `json:"expenseIds"`
+}
+
+// subscriptionInterval describes how a detected gap maps to a rule interval.
+type subscriptionInterval struct {
+	name      string
+	days      float64
+	tolerance float64
+	minCount  int
+}
+
+var subscriptionIntervals = []subscriptionInterval{
+	{name: "weekly", days: 7, tolerance: 1.5, minCount: 4},
+	{name: "monthly", days: 30.44, tolerance: 4, minCount: 3},
+	{name: "yearly", days: 365.25, tolerance: 10, minCount: 2},
+}
+
+// subscriptionAmountTolerance is how far a charge may be from the median
+// charge and still count as the same subscription (price changes, fx).
+const subscriptionAmountTolerance = 0.2
+
+// DetectSubscriptions groups unlinked expenses by normalized name and looks
+// for charges of a similar amount at a regular weekly, monthly or yearly
+// interval. Patterns whose next charge is overdue by more than one interval
+// are treated as cancelled and left out.
+func DetectSubscriptions(expenses []storage.Expense, now time.Time) []SubscriptionSuggestion {
+	groups := make(map[string][]storage.Expense)
+	for _, expense := range expenses {
+		if expense.RecurringID != "" || expense.Amount == 0 {
+			continue
+		}
+		key := storage.NormalizeName(expense.Name)
+		if key == "" {
+			continue
+		}
+		if expense.Amount > 0 {
+			key += "|income"
+		}
+		groups[key] = append(groups[key], expense)
+	}
+
+	var suggestions []SubscriptionSuggestion
+	for key, group := range groups {
+		if suggestion, ok := detectSubscription(key, group, now); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Name != suggestions[j].Name {
+			return suggestions[i].Name < suggestions[j].Name
+		}
+		return suggestions[i].ID < suggestions[j].ID
+	})
+	return suggestions
+}
+
+func detectSubscription(key string, group []storage.Expense, now time.Time) (SubscriptionSuggestion, bool) {
+	amounts := make([]float64, len(group))
+	for i, expense := range group {
+		amounts[i] = math.Abs(expense.Amount)
+	}
+	typical := anomaly.Median(amounts)
+	var charges []storage.Expense
+	for _, expense := range group {
+		if math.Abs(math.Abs(expense.Amount)-typical) <= typical*subscriptionAmountTolerance {
+			charges = append(charges, expense)
+		}
+	}
+	sort.Slice(charges, func(i, j int) bool { return charges[i].Date.Before(charges[j].Date) })
+
+	for _, interval := range subscriptionIntervals {
+		if len(charges) < interval.minCount {
+			continue
+		}
+		regular := true
+		for i := 1; i < len(charges); i++ {
+			gap := charges[i].Date.Sub(charges[i-1].Date).Hours() / 24
+			if math.Abs(gap-interval.days) > interval.tolerance {
+				regular = false
+				break
+			}
+		}
+		if !regular {
+			continue
+		}
+		first, last := charges[0], charges[len(charges)-1]
+		next := nextCharge(last.Date, interval.name)
+		if now.After(nextCharge(next, interval.name)) {
+			return SubscriptionSuggestion{}, false
+		}
+		ids := make([]string, len(charges))
+		chargeAmounts := make([]float64, len(charges))
+		for i, charge := range charges {
+			ids[i] = charge.ID
+			chargeAmounts[i] = charge.Amount
+		}
+		sum := sha256.Sum256([]byte(key + "|" + interval.name))
+		return SubscriptionSuggestion{
+			ID:          hex.EncodeToString(sum[:8]),
+			Name:        last.Name,
+			Category:    mostCommonCategory(charges),
+			Currency:    last.Currency,
+			Tags:        last.Tags,
+			Amount:      round2(anomaly.Median(chargeAmounts)),
+			Interval:    interval.name,
+			StartDate:   first.Date,
+			LastDate:    last.Date,
+			NextDate:    next,
+			Occurrences: len(charges),
+			ExpenseIDs:  ids,
+		}, true
+	}
+	return SubscriptionSuggestion{}, false
+}
+
+func nextCharge(date time.Time, interval string) time.Time {
+	switch interval {
+	case "weekly":
+		return date.AddDate(0, 0, 7)
+	case "yearly":
+		return date.AddDate(1, 0, 0)
+	}
+	return date.AddDate(0, 1, 0)
+}
+
+func mostCommonCategory(expenses []storage.Expense) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, expense := range expenses {
+		counts[expense.Category]++
+		if counts[expense.Category] > counts[best] || (counts[expense.Category] == counts[best] && expense.Category < best) {
+			best = expense.Category
+		}
+	}
+	return best
+}
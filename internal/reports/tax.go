@@ -0,0 +1,77 @@
+package reports
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tanq16/expenseowl/internal/storage"
+)
+
+// TaxItem is one tax-relevant expense with its deductible amount, which is
+// what was spent less any linked refunds.
+type TaxItem struct {
+	Expense    storage.Expense `json:"expense"`
+	Refunded   float64         `json:"refunded"`
+	Deductible float64         `json:"deductible"`
+}
+
+// TaxClassTotal groups the tax-relevant expenses of one deduction class.
+type TaxClassTotal struct {
+	Class string    `json:"class"`
+	Total float64   `json:"total"`
+	Count int       `json:"count"`
+	Items []TaxItem `json:"items"`
+}
+
+// TaxReport is the yearly summary of tax-relevant spending.
+type TaxReport struct {
+	Year    int             `json:"year"`
+	Total   float64         `json:"total"`
+	Classes []TaxClassTotal `json:"classes"`
+}
+
+// BuildTaxReport collects expenses with a tax class booked in the given
+// calendar year, grouped by class. Income rows are ignored.
+func BuildTaxReport(expenses []storage.Expense, links []storage.RefundLink, year int, loc *time.Location) TaxReport {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(1, 0, 0)
+	refunded, _ := LinkedAmounts(links)
+
+	byClass := make(map[string]*TaxClassTotal)
+	for _, expense := range expenses {
+		if expense.TaxClass == "" || expense.Amount >= 0 || !InRange(expense.Date, from, to) {
+			continue
+		}
+		item := TaxItem{
+			Expense:  expense,
+			Refunded: round2(refunded[expense.ID]),
+		}
+		item.Deductible = round2(-expense.Amount - item.Refunded)
+		if item.Deductible <= 0 {
+			continue
+		}
+		class, ok := byClass[expense.TaxClass]
+		if !ok {
+			class = &TaxClassTotal{Class: expense.TaxClass}
+			byClass[expense.TaxClass] = class
+		}
+		class.Items = append(class.Items, item)
+		class.Total += item.Deductible
+		class.Count++
+	}
+
+	report := TaxReport{Year: year, Classes: []TaxClassTotal{}}
+	for _, class := range byClass {
+		sort.Slice(class.Items, func(i, j int) bool {
+			return class.Items[i].Expense.Date.Before(class.Items[j].Expense.Date)
+		})
+		class.Total = round2(class.Total)
+		report.Total += class.Total
+		report.Classes = append(report.Classes, *class)
+	}
+	report.Total = round2(report.Total)
+	sort.Slice(report.Classes, func(i, j int) bool {
+		return report.Classes[i].Class < report.Classes[j].Class
+	})
+	return report
+}
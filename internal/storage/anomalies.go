@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnomalyFlag is a stored anomaly finding. Its details (names, amounts,
+// message) live in Blob, encrypted like expenses when the user has a key.
+type AnomalyFlag struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"userId"`
+	Kind        string     `json:"kind"`
+	ExpenseID   string     `json:"expenseId,omitempty"`
+	Fingerprint string     `json:"-"`
+	Blob        string     `json:"-"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DismissedAt *time.Time `json:"dismissedAt,omitempty"`
+}
+
+func (s *databaseStore) GetAnomalyFlags(userID string, includeDismissed bool) ([]AnomalyFlag, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, kind, expense_id, fingerprint, blob, created_at, dismissed_at
+        FROM anomaly_flags
+        WHERE user_id = $1 AND ($2 OR dismissed_at IS NULL)
+        ORDER BY created_at DESC
+    `, userID, includeDismissed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomaly flags: %v", err)
+	}
+	defer rows.Close()
+
+	var flags []AnomalyFlag
+	for rows.Next() {
+		var f AnomalyFlag
+		var expenseID sql.NullString
+		var dismissedAt sql.NullTime
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Kind, &expenseID, &f.Fingerprint, &f.Blob, &f.CreatedAt, &dismissedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan anomaly flag: %v", err)
+		}
+		f.ExpenseID = expenseID.String
+		if dismissedAt.Valid {
+			f.DismissedAt = &dismissedAt.Time
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// AddAnomalyFlag stores a flag unless one with the same fingerprint exists.
+// It reports whether the flag is new.
+func (s *databaseStore) AddAnomalyFlag(userID string, flag AnomalyFlag) (bool, error) {
+	if userID == "" {
+		return false, errors.New("userID is required")
+	}
+	if flag.ID == "" {
+		flag.ID = uuid.New().String()
+	}
+	if flag.CreatedAt.IsZero() {
+		flag.CreatedAt = time.Now()
+	}
+	res, err := s.db.Exec(`
+        INSERT INTO anomaly_flags (id, user_id, kind, expense_id, fingerprint, blob, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (user_id, fingerprint) DO NOTHING
+    `, flag.ID, userID, flag.Kind, nullString(flag.ExpenseID), flag.Fingerprint, flag.Blob, flag.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert anomaly flag: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read insert result: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (s *databaseStore) DismissAnomalyFlag(userID, id string) error {
+	res, err := s.db.Exec(`
+        UPDATE anomaly_flags SET dismissed_at = $1
+        WHERE user_id = $2 AND id = $3 AND dismissed_at IS NULL
+    `, time.Now(), userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to dismiss anomaly flag: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read update result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("open anomaly flag with ID %s not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment describes a receipt or document stored in the blob store. Only
+// metadata lives in PostgreSQL; the content is addressed by StorageKey.
+type Attachment struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	ExpenseID   string    `json:"expenseId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	Encrypted   bool      `json:"encrypted"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+var REUnsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N}._\- ()]`)
+
+// SanitizeFileName strips directories and unsafe characters from an uploaded file name.
+func SanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = REUnsafeFileChars.ReplaceAllString(name, "_")
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	if len(name) > 200 {
+		ext := filepath.Ext(name)
+		name = name[:200-len(ext)] + ext
+	}
+	return name
+}
+
+// AttachmentKey builds the blob key for an attachment.
+func AttachmentKey(userID, attachmentID string) string {
+	return userID + "/" + attachmentID
+}
+
+func scanAttachment(scanner interface{ Scan(...any) error }) (Attachment, error) {
+	var a Attachment
+	err := scanner.Scan(&a.ID, &a.UserID, &a.ExpenseID, &a.FileName, &a.ContentType, &a.Size, &a.StorageKey, &a.Encrypted, &a.CreatedAt)
+	return a, err
+}
+
+// GetAttachments lists attachment metadata for one expense, or for all of the
+// user's expenses when expenseID is empty.
+func (s *databaseStore) GetAttachments(userID, expenseID string) ([]Attachment, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, expense_id, file_name, content_type, size, storage_key, encrypted, created_at
+        FROM attachments
+        WHERE user_id = $1 AND ($2 = '' OR expense_id::text = $2)
+        ORDER BY created_at
+    `, userID, expenseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %v", err)
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %v", err)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *databaseStore) GetAttachment(userID, id string) (Attachment, error) {
+	a, err := scanAttachment(s.db.QueryRow(`
+        SELECT id, user_id, expense_id, file_name, content_type, size, storage_key, encrypted, created_at
+        FROM attachments
+        WHERE user_id = $1 AND id = $2
+    `, userID, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Attachment{}, fmt.Errorf("attachment with ID %s not found", id)
+		}
+		return Attachment{}, fmt.Errorf("failed to get attachment: %v", err)
+	}
+	return a, nil
+}
+
+func (s *databaseStore) AddAttachment(userID string, attachment Attachment) error {
+	if userID == "" {
+		return errors.New("userID is required")
+	}
+	if attachment.ID == "" {
+		attachment.ID = uuid.New().String()
+	}
+	if attachment.CreatedAt.IsZero() {
+		attachment.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(`
+        INSERT INTO attachments (id, user_id, expense_id, file_name, content_type, size, storage_key, encrypted, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `, attachment.ID, userID, attachment.ExpenseID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey, attachment.Encrypted, attachment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert attachment: %v", err)
+	}
+	return nil
+}
+
+func (s *databaseStore) RemoveAttachment(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM attachments WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("attachment with ID %s not found", id)
+	}
+	return nil
+}
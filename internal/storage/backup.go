@@ -0,0 +1,890 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/tanq16/expenseowl/internal/encryption"
+)
+
+// BackupFormat and BackupVersion identify the layout of a JSON backup.
+// Restores accept every version up to BackupVersion.
+const (
+	BackupFormat  = "expenseowl-backup"
+	BackupVersion = 1
+)
+
+// Backup is everything a user owns, as exported by /export/json. Expenses
+// and recurring expenses that can't be decrypted keep their blob and are
+// restored as-is; the others carry readable fields and no blob. Attachments
+// are included as metadata with their blob store keys; their content is not,
+// the archive export carries it.
+type Backup struct {
+	Format              string               `json:"format"`
+	Version             int                  `json:"version"`
+	ExportedAt          time.Time            `json:"exportedAt"`
+	Encrypted           bool                 `json:"encrypted"` // some blobs are kept encrypted, restoring them needs the same key
+	Settings            BackupSettings       `json:"settings"`
+	Expenses            []Expense            `json:"expenses"`
+	Attachments         []BackupAttachment   `json:"attachments"`
+	RecurringExpenses   []RecurringExpense   `json:"recurringExpenses"`
+	Payees              []Payee              `json:"payees"`
+	CategorizationRules []CategorizationRule `json:"categorizationRules"`
+	Contacts            []Contact            `json:"contacts"`
+	Debts               []Debt               `json:"debts"` // with their repayments
+	RefundLinks         []RefundLink         `json:"refundLinks"`
+	Reconciliations     []Reconciliation     `json:"reconciliations"`
+	ClosedPeriods       []ClosedPeriod       `json:"closedPeriods"`
+	Statements          []Statement          `json:"statements"`
+	ImportProfiles      []ImportProfile      `json:"importProfiles"`
+	ImportBatches       []ImportBatch        `json:"importBatches"`
+	TelegramLinks       []TelegramLink       `json:"telegramLinks"`
+}
+
+// BackupSettings are the per-user settings of a backup.
+type BackupSettings struct {
+	Categories      []string        `json:"categories"`
+	Currency        string          `json:"currency"`
+	StartDate       int             `json:"startDate"`
+	DuplicatePolicy DuplicatePolicy `json:"duplicatePolicy"`
+	ImportLocale    ImportLocale    `json:"importLocale"`
+}
+
+// BackupAttachment is attachment metadata as stored in a backup, including
+// the key of the content in the blob store.
+type BackupAttachment struct {
+	Attachment
+	StorageKey string `json:"storageKey"`
+}
+
+// TelegramLink is a Telegram chat connection as stored in a backup. The
+// ingest token is included so a restored link keeps working.
+type TelegramLink struct {
+	ID          string     `json:"id"`
+	Label       string     `json:"label"`
+	ChatID      *int64     `json:"chatId,omitempty"`
+	Username    string     `json:"username,omitempty"`
+	LinkCode    string     `json:"linkCode,omitempty"`
+	IngestToken string     `json:"ingestToken"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LinkedAt    *time.Time `json:"linkedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+}
+
+// BackupRestore counts, per section of the backup, the rows a restore added
+// and the rows it skipped because the account already had them.
+type BackupRestore struct {
+	Added   map[string]int `json:"added"`
+	Skipped map[string]int `json:"skipped"`
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+// GetBackup collects everything the user owns. Expense and recurring
+// expense blobs are returned as stored.
+func (s *databaseStore) GetBackup(userID string) (Backup, error) {
+	backup := Backup{Format: BackupFormat, Version: BackupVersion, ExportedAt: time.Now().UTC()}
+	cfg, err := s.GetConfig(userID)
+	if err != nil {
+		return Backup{}, err
+	}
+	backup.Settings = BackupSettings{Categories: cfg.Categories, Currency: cfg.Currency, StartDate: cfg.StartDate}
+	if backup.Settings.DuplicatePolicy, err = s.GetDuplicatePolicy(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Settings.ImportLocale, err = s.GetImportLocale(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Expenses, err = s.backupExpenses(userID); err != nil {
+		return Backup{}, err
+	}
+	attachments, err := s.GetAttachments(userID, "")
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, attachment := range attachments {
+		backup.Attachments = append(backup.Attachments, BackupAttachment{Attachment: attachment, StorageKey: attachment.StorageKey})
+	}
+	if backup.RecurringExpenses, err = s.GetRecurringExpenses(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Payees, err = s.GetPayees(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.CategorizationRules, err = s.GetCategorizationRules(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Contacts, err = s.GetContacts(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Debts, err = s.GetDebts(userID, ""); err != nil {
+		return Backup{}, err
+	}
+	if backup.RefundLinks, err = s.GetRefundLinks(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Reconciliations, err = s.GetReconciliations(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.ClosedPeriods, err = s.GetClosedPeriods(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.Statements, err = s.GetStatements(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.ImportProfiles, err = s.GetImportProfiles(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.ImportBatches, err = s.GetImportBatches(userID); err != nil {
+		return Backup{}, err
+	}
+	if backup.TelegramLinks, err = s.backupTelegramLinks(userID); err != nil {
+		return Backup{}, err
+	}
+	return backup, nil
+}
+
+// backupExpenses reads the expense rows including the columns kept outside
+// the blob.
+func (s *databaseStore) backupExpenses(userID string) ([]Expense, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, recurring_id, blob, external_ref, import_batch_id
+        FROM expenses
+        WHERE user_id = $1
+        ORDER BY id
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %v", err)
+	}
+	defer rows.Close()
+
+	var expenses []Expense
+	for rows.Next() {
+		var e Expense
+		var recurringID, externalRef, batchID sql.NullString
+		if err := rows.Scan(&e.ID, &e.UserID, &recurringID, &e.Blob, &externalRef, &batchID); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %v", err)
+		}
+		e.RecurringID, e.ExternalRef, e.ImportBatchID = recurringID.String, externalRef.String, batchID.String
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+func (s *databaseStore) backupTelegramLinks(userID string) ([]TelegramLink, error) {
+	rows, err := s.db.Query(`
+        SELECT id, label, chat_id, telegram_username, link_code, ingest_token, created_at, linked_at, revoked_at
+        FROM telegram_links
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query telegram links: %v", err)
+	}
+	defer rows.Close()
+
+	var links []TelegramLink
+	for rows.Next() {
+		var link TelegramLink
+		var chatID sql.NullInt64
+		var username, code sql.NullString
+		var linkedAt, revokedAt sql.NullTime
+		if err := rows.Scan(&link.ID, &link.Label, &chatID, &username, &code, &link.IngestToken, &link.CreatedAt, &linkedAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan telegram link: %v", err)
+		}
+		if chatID.Valid {
+			link.ChatID = &chatID.Int64
+		}
+		if linkedAt.Valid {
+			link.LinkedAt = &linkedAt.Time
+		}
+		if revokedAt.Valid {
+			link.RevokedAt = &revokedAt.Time
+		}
+		link.Username, link.LinkCode = username.String, code.String
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// RestoreBackup writes a backup into the user's account in one transaction.
+// Without merge the account must not have expenses or recurring expenses yet
+// and takes over the settings of the backup; with merge only missing
+// categories are added. Rows the user already has are skipped, so merging
+// the same backup twice adds nothing. Rows whose ID belongs to another
+// account, e.g. the one the backup was taken from, get new IDs and every
+// reference to them is updated. Readable expenses and recurring expenses
+// are encrypted with enc when it is set.
+func (s *databaseStore) RestoreBackup(userID string, backup Backup, merge bool, enc *encryption.Manager) (BackupRestore, error) {
+	if userID == "" {
+		return BackupRestore{}, errors.New("userID is required")
+	}
+	if err := s.EnsureUserDefaults(userID); err != nil {
+		return BackupRestore{}, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return BackupRestore{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if !merge {
+		var used bool
+		err := tx.QueryRow(`
+            SELECT EXISTS (SELECT 1 FROM expenses WHERE user_id = $1)
+                OR EXISTS (SELECT 1 FROM recurring_expenses WHERE user_id = $1)
+        `, userID).Scan(&used)
+		if err != nil {
+			return BackupRestore{}, fmt.Errorf("failed to check account: %v", err)
+		}
+		if used {
+			return BackupRestore{}, errors.New("account already has expenses, merge the backup instead")
+		}
+	}
+
+	r := &backupRestorer{
+		store:  s,
+		tx:     tx,
+		userID: userID,
+		merge:  merge,
+		enc:    enc,
+		remap:  make(map[string]string),
+		owned:  make(map[string]bool),
+		result: BackupRestore{Added: make(map[string]int), Skipped: make(map[string]int)},
+	}
+	steps := []func(Backup) error{
+		r.settings,
+		r.importBatches,
+		r.recurringExpenses,
+		r.payees,
+		r.contacts,
+		r.expenses,
+		r.attachments,
+		r.debts,
+		r.refundLinks,
+		r.reconciliations,
+		r.closedPeriods,
+		r.statements,
+		r.categorizationRules,
+		r.importProfiles,
+		r.telegramLinks,
+	}
+	for _, step := range steps {
+		if err := step(backup); err != nil {
+			return BackupRestore{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return BackupRestore{}, fmt.Errorf("failed to commit restore: %v", err)
+	}
+	return r.result, nil
+}
+
+// backupRestorer carries the state of one restore through its steps.
+type backupRestorer struct {
+	store  *databaseStore
+	tx     *sql.Tx
+	userID string
+	merge  bool
+	enc    *encryption.Manager
+	remap  map[string]string // backup ID to the new ID of rows whose ID was taken
+	owned  map[string]bool   // IDs the user has after the steps so far
+	result BackupRestore
+}
+
+// id returns the ID a row of the backup is stored under.
+func (r *backupRestorer) id(id string) string {
+	if moved, ok := r.remap[id]; ok {
+		return moved
+	}
+	return id
+}
+
+// claim sorts the IDs of a section: it returns those the user already has
+// and remaps those that are invalid or belong to another user. owners
+// selects the id and owning user_id of the rows among $1.
+func (r *backupRestorer) claim(owners string, ids []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	var valid []string
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			r.remap[id] = uuid.New().String()
+			continue
+		}
+		valid = append(valid, id)
+	}
+	if len(valid) == 0 {
+		return existing, nil
+	}
+	rows, err := r.tx.Query(owners, pq.Array(valid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing rows: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, owner string
+		if err := rows.Scan(&id, &owner); err != nil {
+			return nil, fmt.Errorf("failed to scan existing row: %v", err)
+		}
+		if owner == r.userID {
+			existing[id] = true
+			r.owned[id] = true
+		} else {
+			r.remap[id] = uuid.New().String()
+		}
+	}
+	return existing, rows.Err()
+}
+
+// ownersOf is the claim query of a table with id and user_id columns.
+func ownersOf(table string) string {
+	return fmt.Sprintf(`SELECT id::text, user_id::text FROM %s WHERE id = ANY($1::uuid[])`, table)
+}
+
+// fillIDs gives rows without an ID a new one and returns the IDs.
+func fillIDs(n int, id func(i int) *string) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		if *id(i) == "" {
+			*id(i) = uuid.New().String()
+		}
+		ids[i] = *id(i)
+	}
+	return ids
+}
+
+// added records the outcome of an insert that may have been skipped by an
+// ON CONFLICT clause.
+func (r *backupRestorer) added(section string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read insert result: %v", err)
+	}
+	if n == 0 {
+		r.result.Skipped[section]++
+		return nil
+	}
+	r.result.Added[section]++
+	return nil
+}
+
+// sealBlob serializes a restored row for its blob column, encrypted when a
+// key is supplied.
+func sealBlob(enc *encryption.Manager, payload any) (string, error) {
+	if enc != nil {
+		blob, err := enc.Encrypt(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt restored row: %v", err)
+		}
+		return blob, nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize restored row: %v", err)
+	}
+	return string(raw), nil
+}
+
+func (r *backupRestorer) settings(backup Backup) error {
+	settings := backup.Settings
+	var categoriesStr string
+	if err := r.tx.QueryRow(`SELECT categories FROM user_settings WHERE user_id = $1`, r.userID).Scan(&categoriesStr); err != nil {
+		return fmt.Errorf("failed to load settings: %v", err)
+	}
+	var categories []string
+	if err := json.Unmarshal([]byte(categoriesStr), &categories); err != nil {
+		return fmt.Errorf("failed to parse categories: %v", err)
+	}
+	if !r.merge && len(settings.Categories) > 0 {
+		categories = nil
+	}
+	for _, category := range settings.Categories {
+		if category, err := ValidateCategory(category); err == nil && !slices.Contains(categories, category) {
+			categories = append(categories, category)
+		}
+	}
+	categoriesJSON, err := json.Marshal(categories)
+	if err != nil {
+		return fmt.Errorf("failed to marshal categories: %v", err)
+	}
+	if _, err := r.tx.Exec(`UPDATE user_settings SET categories = $1 WHERE user_id = $2`, string(categoriesJSON), r.userID); err != nil {
+		return fmt.Errorf("failed to restore categories: %v", err)
+	}
+	if r.merge {
+		return nil
+	}
+
+	if settings.Currency != "" {
+		if _, err := r.tx.Exec(`UPDATE user_settings SET currency = $1 WHERE user_id = $2`, settings.Currency, r.userID); err != nil {
+			return fmt.Errorf("failed to restore currency: %v", err)
+		}
+	}
+	if settings.StartDate >= 1 && settings.StartDate <= 31 {
+		if _, err := r.tx.Exec(`UPDATE user_settings SET start_date = $1 WHERE user_id = $2`, settings.StartDate, r.userID); err != nil {
+			return fmt.Errorf("failed to restore start date: %v", err)
+		}
+	}
+	if policy := settings.DuplicatePolicy; policy.Action != "" {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid duplicate policy in backup: %v", err)
+		}
+		_, err := r.tx.Exec(`
+            UPDATE user_settings SET duplicate_action = $1, duplicate_window_days = $2 WHERE user_id = $3
+        `, policy.Action, policy.WindowDays, r.userID)
+		if err != nil {
+			return fmt.Errorf("failed to restore duplicate policy: %v", err)
+		}
+	}
+	locale := settings.ImportLocale
+	if err := locale.Validate(); err != nil {
+		return fmt.Errorf("invalid import locale in backup: %v", err)
+	}
+	_, err = r.tx.Exec(`
+        UPDATE user_settings SET import_locale = $1, import_decimal_separator = $2, import_date_order = $3 WHERE user_id = $4
+    `, locale.Locale, locale.DecimalSeparator, locale.DateOrder, r.userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore import locale: %v", err)
+	}
+	return nil
+}
+
+func (r *backupRestorer) importBatches(backup Backup) error {
+	batches := backup.ImportBatches
+	existing, err := r.claim(ownersOf("import_batches"), fillIDs(len(batches), func(i int) *string { return &batches[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, batch := range batches {
+		if existing[batch.ID] {
+			r.result.Skipped["importBatches"]++
+			continue
+		}
+		id := r.id(batch.ID)
+		_, err := r.tx.Exec(`
+            INSERT INTO import_batches (id, user_id, format, imported, created_at)
+            VALUES ($1, $2, $3, $4, $5)
+        `, id, r.userID, batch.Format, batch.Imported, batch.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore import batch: %v", err)
+		}
+		r.owned[id] = true
+		r.result.Added["importBatches"]++
+	}
+	return nil
+}
+
+func (r *backupRestorer) recurringExpenses(backup Backup) error {
+	recurring := backup.RecurringExpenses
+	existing, err := r.claim(ownersOf("recurring_expenses"), fillIDs(len(recurring), func(i int) *string { return &recurring[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, rec := range recurring {
+		if existing[rec.ID] {
+			r.result.Skipped["recurringExpenses"]++
+			continue
+		}
+		if _, moved := r.remap[rec.ID]; moved && rec.Blob != "" {
+			return fmt.Errorf("encrypted recurring expense %s exists in another account, so it needs a new ID and that needs the encryption key; restore with the key or into the account the backup was taken from", rec.ID)
+		}
+		rec.ID = r.id(rec.ID)
+		if rec.Blob == "" {
+			rec.UserID = r.userID
+			if rec.Blob, err = sealBlob(r.enc, rec); err != nil {
+				return err
+			}
+		}
+		if err := r.store.insertRecurringExpense(r.tx, r.userID, &rec); err != nil {
+			return err
+		}
+		r.owned[rec.ID] = true
+		r.result.Added["recurringExpenses"]++
+	}
+	return nil
+}
+
+func (r *backupRestorer) payees(backup Backup) error {
+	payees := backup.Payees
+	existing, err := r.claim(ownersOf("payees"), fillIDs(len(payees), func(i int) *string { return &payees[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, payee := range payees {
+		if existing[payee.ID] {
+			r.result.Skipped["payees"]++
+			continue
+		}
+		id := r.id(payee.ID)
+		_, err := r.tx.Exec(`
+            INSERT INTO payees (id, user_id, name, aliases, default_category, default_tags, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+        `, id, r.userID, payee.Name, pq.Array(payee.Aliases), payee.DefaultCategory, pq.Array(payee.DefaultTags), payee.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore payee: %v", err)
+		}
+		r.owned[id] = true
+		r.result.Added["payees"]++
+	}
+	return nil
+}
+
+func (r *backupRestorer) contacts(backup Backup) error {
+	contacts := backup.Contacts
+	existing, err := r.claim(ownersOf("contacts"), fillIDs(len(contacts), func(i int) *string { return &contacts[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, contact := range contacts {
+		if existing[contact.ID] {
+			r.result.Skipped["contacts"]++
+			continue
+		}
+		id := r.id(contact.ID)
+		_, err := r.tx.Exec(`
+            INSERT INTO contacts (id, user_id, name, email, phone, note, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+        `, id, r.userID, contact.Name, contact.Email, contact.Phone, contact.Note, contact.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore contact: %v", err)
+		}
+		r.owned[id] = true
+		r.result.Added["contacts"]++
+	}
+	return nil
+}
+
+// expenses restores the expenses with one COPY. Encrypted blobs keep the IDs
+// written inside them, so they can't move to a new ID.
+func (r *backupRestorer) expenses(backup Backup) error {
+	expenses := backup.Expenses
+	existing, err := r.claim(ownersOf("expenses"), fillIDs(len(expenses), func(i int) *string { return &expenses[i].ID }))
+	if err != nil {
+		return err
+	}
+	var restored []Expense
+	for _, expense := range expenses {
+		if existing[expense.ID] {
+			r.result.Skipped["expenses"]++
+			continue
+		}
+		if _, moved := r.remap[expense.ID]; moved && expense.Blob != "" {
+			return fmt.Errorf("encrypted expense %s exists in another account, so it needs a new ID and that needs the encryption key; restore with the key or into the account the backup was taken from", expense.ID)
+		}
+		expense.ID = r.id(expense.ID)
+		expense.RecurringID = r.id(expense.RecurringID)
+		expense.ImportBatchID = r.id(expense.ImportBatchID)
+		if expense.Blob == "" {
+			expense.UserID = r.userID
+			expense.PayeeID = r.id(expense.PayeeID)
+			expense.DuplicateOf = r.id(expense.DuplicateOf)
+			if expense.Blob, err = sealBlob(r.enc, expense); err != nil {
+				return err
+			}
+		}
+		restored = append(restored, expense)
+		r.owned[expense.ID] = true
+	}
+	if len(restored) == 0 {
+		return nil
+	}
+	if err := copyExpenses(r.tx, r.userID, restored); err != nil {
+		return err
+	}
+	r.result.Added["expenses"] += len(restored)
+	return nil
+}
+
+// attachments restores attachment metadata pointing at content that is still
+// in the blob store. An attachment whose ID belongs to another account is
+// skipped instead of sharing that account's content.
+func (r *backupRestorer) attachments(backup Backup) error {
+	attachments := backup.Attachments
+	existing, err := r.claim(ownersOf("attachments"), fillIDs(len(attachments), func(i int) *string { return &attachments[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		expenseID := r.id(attachment.ExpenseID)
+		_, moved := r.remap[attachment.ID]
+		if existing[attachment.ID] || moved || attachment.StorageKey == "" || !r.owned[expenseID] {
+			r.result.Skipped["attachments"]++
+			continue
+		}
+		_, err := r.tx.Exec(`
+            INSERT INTO attachments (id, user_id, expense_id, file_name, content_type, size, storage_key, encrypted, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        `, attachment.ID, r.userID, expenseID, SanitizeFileName(attachment.FileName), attachment.ContentType, attachment.Size, attachment.StorageKey, attachment.Encrypted, attachment.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore attachment: %v", err)
+		}
+		r.result.Added["attachments"]++
+	}
+	return nil
+}
+
+func (r *backupRestorer) debts(backup Backup) error {
+	debts := backup.Debts
+	existing, err := r.claim(ownersOf("debts"), fillIDs(len(debts), func(i int) *string { return &debts[i].ID }))
+	if err != nil {
+		return err
+	}
+	var repayments []DebtRepayment
+	for _, debt := range debts {
+		if existing[debt.ID] {
+			r.result.Skipped["debts"]++
+			continue
+		}
+		id, contactID := r.id(debt.ID), r.id(debt.ContactID)
+		if !r.owned[contactID] {
+			r.result.Skipped["debts"]++ // its contact is neither in the backup nor in the account
+			continue
+		}
+		_, err := r.tx.Exec(`
+            INSERT INTO debts (id, user_id, contact_id, expense_id, direction, amount, currency, description, date, due_date)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        `, id, r.userID, contactID, nullString(r.id(debt.ExpenseID)), debt.Direction, debt.Amount, debt.Currency, debt.Description, debt.Date, debt.DueDate)
+		if err != nil {
+			return fmt.Errorf("failed to restore debt: %v", err)
+		}
+		r.owned[id] = true
+		r.result.Added["debts"]++
+		for _, repayment := range debt.Repayments {
+			repayment.DebtID = id
+			repayments = append(repayments, repayment)
+		}
+	}
+
+	existing, err = r.claim(`
+        SELECT p.id::text, d.user_id::text FROM debt_repayments p JOIN debts d ON d.id = p.debt_id
+        WHERE p.id = ANY($1::uuid[])
+    `, fillIDs(len(repayments), func(i int) *string { return &repayments[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, repayment := range repayments {
+		if existing[repayment.ID] {
+			continue
+		}
+		_, err := r.tx.Exec(`
+            INSERT INTO debt_repayments (id, debt_id, expense_id, amount, date, note)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, r.id(repayment.ID), repayment.DebtID, nullString(r.id(repayment.ExpenseID)), repayment.Amount, repayment.Date, repayment.Note)
+		if err != nil {
+			return fmt.Errorf("failed to restore debt repayment: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *backupRestorer) refundLinks(backup Backup) error {
+	links := backup.RefundLinks
+	existing, err := r.claim(ownersOf("expense_refunds"), fillIDs(len(links), func(i int) *string { return &links[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		expenseID, refundID := r.id(link.ExpenseID), r.id(link.RefundID)
+		if existing[link.ID] || !r.owned[expenseID] || !r.owned[refundID] {
+			r.result.Skipped["refundLinks"]++
+			continue
+		}
+		res, err := r.tx.Exec(`
+            INSERT INTO expense_refunds (id, user_id, expense_id, refund_id, amount, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            ON CONFLICT DO NOTHING
+        `, r.id(link.ID), r.userID, expenseID, refundID, link.Amount, link.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore refund link: %v", err)
+		}
+		if err := r.added("refundLinks", res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupRestorer) reconciliations(backup Backup) error {
+	recs := backup.Reconciliations
+	existing, err := r.claim(ownersOf("reconciliations"), fillIDs(len(recs), func(i int) *string { return &recs[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if existing[rec.ID] {
+			r.result.Skipped["reconciliations"]++
+			continue
+		}
+		id := r.id(rec.ID)
+		_, err := r.tx.Exec(`
+            INSERT INTO reconciliations (id, user_id, account, statement_date, starting_balance, ending_balance, status, created_at, completed_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        `, id, r.userID, rec.Account, rec.StatementDate, rec.StartingBalance, rec.EndingBalance, rec.Status, rec.CreatedAt, rec.CompletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore reconciliation: %v", err)
+		}
+		for _, expenseID := range rec.ExpenseIDs {
+			if expenseID = r.id(expenseID); !r.owned[expenseID] {
+				continue
+			}
+			// an expense merged into the account may already be reconciled elsewhere
+			_, err := r.tx.Exec(`
+                INSERT INTO reconciliation_items (reconciliation_id, expense_id)
+                VALUES ($1, $2)
+                ON CONFLICT DO NOTHING
+            `, id, expenseID)
+			if err != nil {
+				return fmt.Errorf("failed to restore reconciliation item: %v", err)
+			}
+		}
+		r.result.Added["reconciliations"]++
+	}
+	return nil
+}
+
+func (r *backupRestorer) closedPeriods(backup Backup) error {
+	periods := backup.ClosedPeriods
+	existing, err := r.claim(ownersOf("closed_periods"), fillIDs(len(periods), func(i int) *string { return &periods[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, period := range periods {
+		if existing[period.ID] {
+			r.result.Skipped["closedPeriods"]++
+			continue
+		}
+		res, err := r.tx.Exec(`
+            INSERT INTO closed_periods (id, user_id, period_start, period_end, closed_at)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT DO NOTHING
+        `, r.id(period.ID), r.userID, period.PeriodStart, period.PeriodEnd, period.ClosedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore closed period: %v", err)
+		}
+		if err := r.added("closedPeriods", res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupRestorer) statements(backup Backup) error {
+	statements := backup.Statements
+	existing, err := r.claim(ownersOf("statements"), fillIDs(len(statements), func(i int) *string { return &statements[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, st := range statements {
+		if existing[st.ID] {
+			r.result.Skipped["statements"]++
+			continue
+		}
+		_, err := r.tx.Exec(`
+            INSERT INTO statements (id, user_id, source, account, currency, ledger_balance, balance_date, start_date, end_date, transactions, imported, imported_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        `, r.id(st.ID), r.userID, st.Source, st.Account, st.Currency, st.LedgerBalance, st.BalanceDate, st.StartDate, st.EndDate, st.Transactions, st.Imported, st.ImportedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore statement: %v", err)
+		}
+		r.result.Added["statements"]++
+	}
+	return nil
+}
+
+// categorizationRules keeps the order of the backup's rules, after the
+// account's own rules when merging.
+func (r *backupRestorer) categorizationRules(backup Backup) error {
+	rules := slices.Clone(backup.CategorizationRules)
+	slices.SortStableFunc(rules, func(a, b CategorizationRule) int { return a.Position - b.Position })
+	existing, err := r.claim(ownersOf("categorization_rules"), fillIDs(len(rules), func(i int) *string { return &rules[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if existing[rule.ID] {
+			r.result.Skipped["categorizationRules"]++
+			continue
+		}
+		conditions, actions, err := marshalRuleParts(rule)
+		if err != nil {
+			return err
+		}
+		_, err = r.tx.Exec(`
+            INSERT INTO categorization_rules (id, user_id, name, position, enabled, conditions, actions, stop, created_at)
+            VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM categorization_rules WHERE user_id = $2), $4, $5, $6, $7, $8)
+        `, r.id(rule.ID), r.userID, rule.Name, rule.Enabled, conditions, actions, rule.Stop, rule.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore categorization rule: %v", err)
+		}
+		r.result.Added["categorizationRules"]++
+	}
+	return nil
+}
+
+func (r *backupRestorer) importProfiles(backup Backup) error {
+	profiles := backup.ImportProfiles
+	existing, err := r.claim(ownersOf("import_profiles"), fillIDs(len(profiles), func(i int) *string { return &profiles[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		if existing[profile.ID] {
+			r.result.Skipped["importProfiles"]++
+			continue
+		}
+		columns, err := json.Marshal(profile.Columns)
+		if err != nil {
+			return fmt.Errorf("failed to serialize import columns: %v", err)
+		}
+		_, err = r.tx.Exec(`
+            INSERT INTO import_profiles (id, user_id, name, delimiter, decimal_separator, date_format, sign_convention, columns, default_category, skip_rows, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        `, r.id(profile.ID), r.userID, profile.Name, profile.Delimiter, profile.DecimalSeparator, profile.DateFormat, profile.SignConvention, columns, profile.DefaultCategory, profile.SkipRows, profile.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore import profile: %v", err)
+		}
+		r.result.Added["importProfiles"]++
+	}
+	return nil
+}
+
+// telegramLinks restores the active links. A link is skipped while its
+// token, chat or label is in use, e.g. by the account the backup came from.
+func (r *backupRestorer) telegramLinks(backup Backup) error {
+	var links []TelegramLink
+	for _, link := range backup.TelegramLinks {
+		if link.RevokedAt == nil && link.IngestToken != "" {
+			links = append(links, link)
+		}
+	}
+	existing, err := r.claim(ownersOf("telegram_links"), fillIDs(len(links), func(i int) *string { return &links[i].ID }))
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if existing[link.ID] {
+			r.result.Skipped["telegramLinks"]++
+			continue
+		}
+		res, err := r.tx.Exec(`
+            INSERT INTO telegram_links (id, user_id, chat_id, label, link_code, ingest_token, telegram_username, created_at, linked_at)
+            SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+            WHERE NOT EXISTS (SELECT 1 FROM telegram_links WHERE ingest_token = $6 AND revoked_at IS NULL)
+            ON CONFLICT DO NOTHING
+        `, r.id(link.ID), r.userID, link.ChatID, link.Label, nullString(link.LinkCode), link.IngestToken, nullString(link.Username), link.CreatedAt, link.LinkedAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore telegram link: %v", err)
+		}
+		if err := r.added("telegramLinks", res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -83,6 +83,13 @@ ALTER TABLE recurring_expenses
     ADD COLUMN IF NOT EXISTS blob TEXT;
 `
 
+	ensureRecurringInstallmentColumnsSQL = `
+ALTER TABLE recurring_expenses
+    ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'recurring',
+    ADD COLUMN IF NOT EXISTS principal NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS fees NUMERIC(12, 2) NOT NULL DEFAULT 0;
+`
+
 	createTelegramLinksTableSQL = `
 CREATE TABLE IF NOT EXISTS telegram_links (
     id UUID PRIMARY KEY,
@@ -109,6 +116,229 @@ CREATE UNIQUE INDEX IF NOT EXISTS idx_telegram_links_user_label_active
 CREATE UNIQUE INDEX IF NOT EXISTS idx_telegram_links_chat_id_active
     ON telegram_links (chat_id)
     WHERE chat_id IS NOT NULL AND revoked_at IS NULL;
+`
+
+	createContactsTableSQL = `
+CREATE TABLE IF NOT EXISTS contacts (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(320) NOT NULL DEFAULT '',
+    phone VARCHAR(50) NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	createDebtsTableSQL = `
+CREATE TABLE IF NOT EXISTS debts (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id UUID NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    expense_id UUID,
+    direction VARCHAR(20) NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    description TEXT,
+    date TIMESTAMPTZ NOT NULL,
+    due_date TIMESTAMPTZ
+);
+`
+
+	createDebtRepaymentsTableSQL = `
+CREATE TABLE IF NOT EXISTS debt_repayments (
+    id UUID PRIMARY KEY,
+    debt_id UUID NOT NULL REFERENCES debts(id) ON DELETE CASCADE,
+    expense_id UUID,
+    amount NUMERIC(12, 2) NOT NULL,
+    date TIMESTAMPTZ NOT NULL,
+    note TEXT
+);
+`
+
+	createAttachmentsTableSQL = `
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	createExpenseRefundsTableSQL = `
+CREATE TABLE IF NOT EXISTS expense_refunds (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    refund_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (expense_id, refund_id)
+);
+`
+
+	createReconciliationsTableSQL = `
+CREATE TABLE IF NOT EXISTS reconciliations (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account VARCHAR(255) NOT NULL,
+    statement_date TIMESTAMPTZ NOT NULL,
+    starting_balance NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ending_balance NUMERIC(14, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+`
+
+	// Deleting a ticked expense unticks it from its open session. Reconciled
+	// expenses are kept by the lock checks, which refuse every delete path.
+	createReconciliationItemsTableSQL = `
+CREATE TABLE IF NOT EXISTS reconciliation_items (
+    reconciliation_id UUID NOT NULL REFERENCES reconciliations(id) ON DELETE CASCADE,
+    expense_id UUID NOT NULL UNIQUE REFERENCES expenses(id) ON DELETE CASCADE,
+    PRIMARY KEY (reconciliation_id, expense_id)
+);
+`
+
+	ensureReconciliationItemsCascadeSQL = `
+ALTER TABLE reconciliation_items
+    DROP CONSTRAINT IF EXISTS reconciliation_items_expense_id_fkey,
+    ADD CONSTRAINT reconciliation_items_expense_id_fkey FOREIGN KEY (expense_id) REFERENCES expenses(id) ON DELETE CASCADE;
+`
+
+	createClosedPeriodsTableSQL = `
+CREATE TABLE IF NOT EXISTS closed_periods (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, period_start)
+);
+`
+
+	createAnomalyFlagsTableSQL = `
+CREATE TABLE IF NOT EXISTS anomaly_flags (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    expense_id UUID REFERENCES expenses(id) ON DELETE CASCADE,
+    fingerprint VARCHAR(64) NOT NULL,
+    blob TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dismissed_at TIMESTAMPTZ,
+    UNIQUE (user_id, fingerprint)
+);
+`
+
+	createCategorizationRulesTableSQL = `
+CREATE TABLE IF NOT EXISTS categorization_rules (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    position INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    conditions JSONB NOT NULL,
+    actions JSONB NOT NULL,
+    stop BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	ensureExpensesExternalRefColumnSQL = `
+ALTER TABLE expenses
+    ADD COLUMN IF NOT EXISTS external_ref VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS expenses_user_external_ref_idx ON expenses (user_id, external_ref);
+`
+
+	createStatementsTableSQL = `
+CREATE TABLE IF NOT EXISTS statements (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL,
+    account VARCHAR(255) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    ledger_balance NUMERIC(14, 2),
+    balance_date TIMESTAMPTZ,
+    start_date TIMESTAMPTZ,
+    end_date TIMESTAMPTZ,
+    transactions INTEGER NOT NULL DEFAULT 0,
+    imported INTEGER NOT NULL DEFAULT 0,
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	createImportProfilesTableSQL = `
+CREATE TABLE IF NOT EXISTS import_profiles (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    delimiter VARCHAR(4) NOT NULL DEFAULT ',',
+    decimal_separator VARCHAR(1) NOT NULL DEFAULT '.',
+    date_format VARCHAR(50) NOT NULL DEFAULT '',
+    sign_convention VARCHAR(20) NOT NULL DEFAULT 'asis',
+    columns JSONB NOT NULL,
+    default_category VARCHAR(255) NOT NULL DEFAULT '',
+    skip_rows INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	createImportPreviewsTableSQL = `
+CREATE TABLE IF NOT EXISTS import_previews (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(20) NOT NULL,
+    blob TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	createImportBatchesTableSQL = `
+CREATE TABLE IF NOT EXISTS import_batches (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(20) NOT NULL,
+    imported INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+`
+
+	ensureExpensesImportBatchColumnSQL = `
+ALTER TABLE expenses
+    ADD COLUMN IF NOT EXISTS import_batch_id UUID;
+CREATE INDEX IF NOT EXISTS expenses_user_import_batch_idx ON expenses (user_id, import_batch_id);
+`
+
+	ensureUserSettingsImportLocaleColumnsSQL = `
+ALTER TABLE user_settings
+    ADD COLUMN IF NOT EXISTS import_locale VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS import_decimal_separator VARCHAR(1) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS import_date_order VARCHAR(3) NOT NULL DEFAULT '';
+`
+
+	ensureUserSettingsDuplicateColumnsSQL = `
+ALTER TABLE user_settings
+    ADD COLUMN IF NOT EXISTS duplicate_action VARCHAR(20) NOT NULL DEFAULT 'flag',
+    ADD COLUMN IF NOT EXISTS duplicate_window_days INTEGER NOT NULL DEFAULT 3;
+`
+
+	createPayeesTableSQL = `
+CREATE TABLE IF NOT EXISTS payees (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    default_category VARCHAR(255) NOT NULL DEFAULT '',
+    default_tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
 `
 )
 
@@ -142,9 +372,30 @@ func createTables(db *sql.DB) error {
 		ensureExpensesBlobColumnSQL,
 		createRecurringExpensesTableSQL,
 		ensureRecurringBlobColumnSQL,
+		ensureRecurringInstallmentColumnsSQL,
 		createTelegramLinksTableSQL,
 		createTelegramLinksLabelIndexSQL,
 		createTelegramLinksChatIndexSQL,
+		createContactsTableSQL,
+		createDebtsTableSQL,
+		createDebtRepaymentsTableSQL,
+		createAttachmentsTableSQL,
+		createExpenseRefundsTableSQL,
+		createReconciliationsTableSQL,
+		createReconciliationItemsTableSQL,
+		ensureReconciliationItemsCascadeSQL,
+		createClosedPeriodsTableSQL,
+		createAnomalyFlagsTableSQL,
+		createCategorizationRulesTableSQL,
+		createPayeesTableSQL,
+		ensureUserSettingsDuplicateColumnsSQL,
+		ensureExpensesExternalRefColumnSQL,
+		createStatementsTableSQL,
+		createImportProfilesTableSQL,
+		createImportPreviewsTableSQL,
+		createImportBatchesTableSQL,
+		ensureExpensesImportBatchColumnSQL,
+		ensureUserSettingsImportLocaleColumnsSQL,
 	}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
@@ -432,9 +683,9 @@ func (s *databaseStore) AddExpense(userID string, expense Expense) error {
         expense.Blob = string(raw)
     }
 	_, err := s.db.Exec(`
-        INSERT INTO expenses (id, user_id, recurring_id, blob)
-        VALUES ($1, $2, $3, $4)
-    `, expense.ID, userID, nullString(expense.RecurringID), expense.Blob)
+        INSERT INTO expenses (id, user_id, recurring_id, blob, external_ref)
+        VALUES ($1, $2, $3, $4, $5)
+    `, expense.ID, userID, nullString(expense.RecurringID), expense.Blob, nullString(expense.ExternalRef))
 	return err
 }
 
@@ -491,7 +742,15 @@ func (s *databaseStore) AddMultipleExpenses(userID string, expenses []Expense) e
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(pq.CopyIn("expenses", "id", "user_id", "recurring_id", "blob"))
+	if err := copyExpenses(tx, userID, expenses); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyExpenses bulk inserts expenses within tx.
+func copyExpenses(tx *sql.Tx, userID string, expenses []Expense) error {
+	stmt, err := tx.Prepare(pq.CopyIn("expenses", "id", "user_id", "recurring_id", "blob", "external_ref", "import_batch_id"))
 	if err != nil {
 		return fmt.Errorf("failed to prepare bulk insert: %v", err)
 	}
@@ -511,14 +770,14 @@ func (s *databaseStore) AddMultipleExpenses(userID string, expenses []Expense) e
             }
             exp.Blob = string(raw)
         }
-		if _, err := stmt.Exec(exp.ID, userID, nullString(exp.RecurringID), exp.Blob); err != nil {
+		if _, err := stmt.Exec(exp.ID, userID, nullString(exp.RecurringID), exp.Blob, nullString(exp.ExternalRef), nullString(exp.ImportBatchID)); err != nil {
 			return fmt.Errorf("failed to insert expense: %v", err)
 		}
 	}
 	if _, err := stmt.Exec(); err != nil {
 		return fmt.Errorf("failed to finalize bulk insert: %v", err)
 	}
-	return tx.Commit()
+	return nil
 }
 
 func (s *databaseStore) RemoveMultipleExpenses(userID string, ids []string) error {
@@ -533,7 +792,7 @@ func (s *databaseStore) RemoveMultipleExpenses(userID string, ids []string) erro
 
 func (s *databaseStore) GetRecurringExpenses(userID string) ([]RecurringExpense, error) {
 	rows, err := s.db.Query(`
-        SELECT id, user_id, name, amount, currency, category, start_date, interval, occurrences, tags, blob
+        SELECT id, user_id, name, amount, currency, category, start_date, interval, occurrences, kind, principal, fees, tags, blob
         FROM recurring_expenses
         WHERE user_id = $1
         ORDER BY start_date DESC
@@ -548,7 +807,7 @@ func (s *databaseStore) GetRecurringExpenses(userID string) ([]RecurringExpense,
 		var rec RecurringExpense
 		var tagsStr sql.NullString
 		var blob sql.NullString
-		err := rows.Scan(&rec.ID, &rec.UserID, &rec.Name, &rec.Amount, &rec.Currency, &rec.Category, &rec.StartDate, &rec.Interval, &rec.Occurrences, &tagsStr, &blob)
+		err := rows.Scan(&rec.ID, &rec.UserID, &rec.Name, &rec.Amount, &rec.Currency, &rec.Category, &rec.StartDate, &rec.Interval, &rec.Occurrences, &rec.Kind, &rec.Principal, &rec.Fees, &tagsStr, &blob)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan recurring expense: %v", err)
 		}
@@ -570,10 +829,10 @@ func (s *databaseStore) GetRecurringExpense(userID, id string) (RecurringExpense
 	var tagsStr sql.NullString
 	var blob sql.NullString
 	err := s.db.QueryRow(`
-        SELECT id, user_id, name, amount, currency, category, start_date, interval, occurrences, tags, blob
+        SELECT id, user_id, name, amount, currency, category, start_date, interval, occurrences, kind, principal, fees, tags, blob
         FROM recurring_expenses
         WHERE user_id = $1 AND id = $2
-    `, userID, id).Scan(&rec.ID, &rec.UserID, &rec.Name, &rec.Amount, &rec.Currency, &rec.Category, &rec.StartDate, &rec.Interval, &rec.Occurrences, &tagsStr, &blob)
+    `, userID, id).Scan(&rec.ID, &rec.UserID, &rec.Name, &rec.Amount, &rec.Currency, &rec.Category, &rec.StartDate, &rec.Interval, &rec.Occurrences, &rec.Kind, &rec.Principal, &rec.Fees, &tagsStr, &blob)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return RecurringExpense{}, fmt.Errorf("recurring expense with ID %s not found", id)
@@ -604,6 +863,19 @@ func (s *databaseStore) AddRecurringExpense(userID string, recurringExpense Recu
 	if recurringExpense.ID == "" {
 		recurringExpense.ID = uuid.New().String()
 	}
+	if err := s.insertRecurringExpense(tx, userID, &recurringExpense); err != nil {
+		return err
+	}
+
+    expensesToAdd := generateExpensesFromRecurring(userID, recurringExpense, false)
+    if err := bulkInsertExpenses(tx, expensesToAdd, enc); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// insertRecurringExpense stores the rule row only; callers add its expenses.
+func (s *databaseStore) insertRecurringExpense(tx *sql.Tx, userID string, recurringExpense *RecurringExpense) error {
 	recurringExpense.UserID = userID
 	if recurringExpense.Currency == "" {
 		currency, err := s.GetCurrency(userID)
@@ -617,18 +889,13 @@ func (s *databaseStore) AddRecurringExpense(userID string, recurringExpense Recu
 		return err
 	}
 	_, err = tx.Exec(`
-        INSERT INTO recurring_expenses (id, user_id, name, amount, currency, category, start_date, interval, occurrences, tags, blob)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-    `, recurringExpense.ID, userID, recurringExpense.Name, recurringExpense.Amount, recurringExpense.Currency, recurringExpense.Category, recurringExpense.StartDate, recurringExpense.Interval, recurringExpense.Occurrences, string(tagsJSON), nullString(recurringExpense.Blob))
+        INSERT INTO recurring_expenses (id, user_id, name, amount, currency, category, start_date, interval, occurrences, kind, principal, fees, tags, blob)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    `, recurringExpense.ID, userID, recurringExpense.Name, recurringExpense.Amount, recurringExpense.Currency, recurringExpense.Category, recurringExpense.StartDate, recurringExpense.Interval, recurringExpense.Occurrences, recurringKind(*recurringExpense), recurringExpense.Principal, recurringExpense.Fees, string(tagsJSON), nullString(recurringExpense.Blob))
 	if err != nil {
 		return fmt.Errorf("failed to insert recurring expense: %v", err)
 	}
-
-    expensesToAdd := generateExpensesFromRecurring(userID, recurringExpense, false)
-    if err := bulkInsertExpenses(tx, expensesToAdd, enc); err != nil {
-        return err
-    }
-    return tx.Commit()
+	return nil
 }
 
 func (s *databaseStore) UpdateRecurringExpense(userID, id string, recurringExpense RecurringExpense, updateAll bool, enc *encryption.Manager) error {
@@ -653,9 +920,9 @@ func (s *databaseStore) UpdateRecurringExpense(userID, id string, recurringExpen
 	}
 	res, err := tx.Exec(`
         UPDATE recurring_expenses
-        SET name = $1, amount = $2, currency = $3, category = $4, start_date = $5, interval = $6, occurrences = $7, tags = $8, blob = $9
-        WHERE id = $10 AND user_id = $11
-    `, recurringExpense.Name, recurringExpense.Amount, recurringExpense.Currency, recurringExpense.Category, recurringExpense.StartDate, recurringExpense.Interval, recurringExpense.Occurrences, string(tagsJSON), nullString(recurringExpense.Blob), id, userID)
+        SET name = $1, amount = $2, currency = $3, category = $4, start_date = $5, interval = $6, occurrences = $7, kind = $8, principal = $9, fees = $10, tags = $11, blob = $12
+        WHERE id = $13 AND user_id = $14
+    `, recurringExpense.Name, recurringExpense.Amount, recurringExpense.Currency, recurringExpense.Category, recurringExpense.StartDate, recurringExpense.Interval, recurringExpense.Occurrences, recurringKind(recurringExpense), recurringExpense.Principal, recurringExpense.Fees, string(tagsJSON), nullString(recurringExpense.Blob), id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to update recurring expense: %v", err)
 	}
@@ -762,18 +1029,11 @@ func generateExpensesFromRecurring(userID string, recExp RecurringExpense, fromT
 
 	if fromToday {
 		for currentDate.Before(today) && (recExp.Occurrences == 0 || occurrencesToGenerate > 0) {
-			switch recExp.Interval {
-			case "daily":
-				currentDate = currentDate.AddDate(0, 0, 1)
-			case "weekly":
-				currentDate = currentDate.AddDate(0, 0, 7)
-			case "monthly":
-				currentDate = currentDate.AddDate(0, 1, 0)
-			case "yearly":
-				currentDate = currentDate.AddDate(1, 0, 0)
-			default:
+			next, ok := advanceDate(currentDate, recExp.Interval)
+			if !ok {
 				return expenses
 			}
+			currentDate = next
 			if recExp.Occurrences > 0 {
 				occurrencesToGenerate--
 			}
@@ -784,6 +1044,10 @@ func generateExpensesFromRecurring(userID string, recExp RecurringExpense, fromT
 	if recExp.Occurrences == 0 {
 		count = 200
 	}
+	var payments []float64
+	if recExp.IsInstallment() {
+		payments = recExp.InstallmentAmounts()
+	}
 
 	for i := 0; recExp.Occurrences == 0 && i < count || (recExp.Occurrences > 0 && occurrencesToGenerate > 0); i++ {
 		exp := Expense{
@@ -797,19 +1061,17 @@ func generateExpensesFromRecurring(userID string, recExp RecurringExpense, fromT
 			Date:        currentDate,
 			Tags:        recExp.Tags,
 		}
+		if payments != nil {
+			number := recExp.Occurrences - occurrencesToGenerate + 1
+			exp.Amount = payments[number-1]
+			exp.Installment = &InstallmentRef{Number: number, Of: recExp.Occurrences}
+		}
 		expenses = append(expenses, exp)
-		switch recExp.Interval {
-		case "daily":
-			currentDate = currentDate.AddDate(0, 0, 1)
-		case "weekly":
-			currentDate = currentDate.AddDate(0, 0, 7)
-		case "monthly":
-			currentDate = currentDate.AddDate(0, 1, 0)
-		case "yearly":
-			currentDate = currentDate.AddDate(1, 0, 0)
-		default:
+		next, ok := advanceDate(currentDate, recExp.Interval)
+		if !ok {
 			return expenses
 		}
+		currentDate = next
 		if recExp.Occurrences > 0 {
 			occurrencesToGenerate--
 		}
@@ -817,6 +1079,13 @@ func generateExpensesFromRecurring(userID string, recExp RecurringExpense, fromT
 	return expenses
 }
 
+func recurringKind(recExp RecurringExpense) string {
+	if recExp.Kind == "" {
+		return RecurringKindRecurring
+	}
+	return recExp.Kind
+}
+
 func nullString(val string) interface{} {
 	if val == "" {
 		return nil
@@ -0,0 +1,559 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DebtDirectionLent     = "lent"     // the contact owes the user
+	DebtDirectionBorrowed = "borrowed" // the user owes the contact
+)
+
+// Contact is a person or business the user lends to or borrows from. Contacts
+// never log in, so they are kept apart from the users table.
+type Contact struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Debt is a single lend/borrow record with its repayment history.
+type Debt struct {
+	ID          string          `json:"id"`
+	UserID      string          `json:"userId"`
+	ContactID   string          `json:"contactId"`
+	ExpenseID   string          `json:"expenseId"` // optional expense the debt originated from
+	Direction   string          `json:"direction"` // lent, borrowed
+	Amount      float64         `json:"amount"`    // always positive, direction carries the sign
+	Currency    string          `json:"currency"`
+	Description string          `json:"description"`
+	Date        time.Time       `json:"date"`
+	DueDate     *time.Time      `json:"dueDate,omitempty"`
+	Repayments  []DebtRepayment `json:"repayments"`
+	Outstanding float64         `json:"outstanding"`
+}
+
+type DebtRepayment struct {
+	ID        string    `json:"id"`
+	DebtID    string    `json:"debtId"`
+	ExpenseID string    `json:"expenseId"` // optional expense recording the money movement
+	Amount    float64   `json:"amount"`
+	Date      time.Time `json:"date"`
+	Note      string    `json:"note"`
+}
+
+// ContactBalance summarises what is still owed between the user and a contact.
+type ContactBalance struct {
+	ContactID string  `json:"contactId"`
+	Name      string  `json:"name"`
+	Currency  string  `json:"currency"`
+	OwedToMe  float64 `json:"owedToMe"`
+	IOwe      float64 `json:"iOwe"`
+	Net       float64 `json:"net"` // positive when the contact owes the user
+	OpenDebts int     `json:"openDebts"`
+}
+
+// OverdueDebt is a reminder entry for a debt past its due date.
+type OverdueDebt struct {
+	Debt
+	ContactName string `json:"contactName"`
+	DaysOverdue int    `json:"daysOverdue"`
+}
+
+func (c *Contact) Validate() error {
+	c.Name = SanitizeString(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("contact 'name' cannot be empty")
+	}
+	c.Email = strings.TrimSpace(c.Email)
+	c.Phone = strings.TrimSpace(c.Phone)
+	c.Note = strings.TrimSpace(c.Note)
+	return nil
+}
+
+func (d *Debt) Validate() error {
+	if d.ContactID == "" {
+		return fmt.Errorf("debt 'contactId' cannot be empty")
+	}
+	if d.Direction != DebtDirectionLent && d.Direction != DebtDirectionBorrowed {
+		return fmt.Errorf("invalid direction: '%s'. Must be one of '%s' or '%s'", d.Direction, DebtDirectionLent, DebtDirectionBorrowed)
+	}
+	d.Amount = math.Abs(d.Amount)
+	if d.Amount == 0 {
+		return fmt.Errorf("debt 'amount' cannot be 0")
+	}
+	if d.Date.IsZero() {
+		return fmt.Errorf("debt 'date' cannot be empty")
+	}
+	if d.DueDate != nil && d.DueDate.Before(d.Date) {
+		return fmt.Errorf("debt 'dueDate' cannot be before its date")
+	}
+	d.Description = SanitizeString(d.Description)
+	return nil
+}
+
+func (p *DebtRepayment) Validate() error {
+	p.Amount = math.Abs(p.Amount)
+	if p.Amount == 0 {
+		return fmt.Errorf("repayment 'amount' cannot be 0")
+	}
+	if p.Date.IsZero() {
+		return fmt.Errorf("repayment 'date' cannot be empty")
+	}
+	p.Note = strings.TrimSpace(p.Note)
+	return nil
+}
+
+// computeOutstanding fills in the amount still owed after repayments.
+func (d *Debt) computeOutstanding() {
+	repaid := 0.0
+	for _, p := range d.Repayments {
+		repaid += p.Amount
+	}
+	d.Outstanding = math.Max(0, math.Round((d.Amount-repaid)*100)/100)
+}
+
+// ContactBalances aggregates outstanding debts per contact and currency.
+func ContactBalances(contacts []Contact, debts []Debt) []ContactBalance {
+	names := make(map[string]string, len(contacts))
+	for _, c := range contacts {
+		names[c.ID] = c.Name
+	}
+	byKey := make(map[string]*ContactBalance)
+	var keys []string
+	for _, d := range debts {
+		if d.Outstanding == 0 {
+			continue
+		}
+		key := d.ContactID + "|" + d.Currency
+		bal, ok := byKey[key]
+		if !ok {
+			bal = &ContactBalance{ContactID: d.ContactID, Name: names[d.ContactID], Currency: d.Currency}
+			byKey[key] = bal
+			keys = append(keys, key)
+		}
+		if d.Direction == DebtDirectionLent {
+			bal.OwedToMe += d.Outstanding
+		} else {
+			bal.IOwe += d.Outstanding
+		}
+		bal.OpenDebts++
+	}
+	balances := make([]ContactBalance, 0, len(keys))
+	for _, key := range keys {
+		bal := byKey[key]
+		bal.Net = math.Round((bal.OwedToMe-bal.IOwe)*100) / 100
+		balances = append(balances, *bal)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return strings.ToLower(balances[i].Name) < strings.ToLower(balances[j].Name)
+	})
+	return balances
+}
+
+// OverdueDebts lists open debts whose due date has passed, most overdue first.
+func OverdueDebts(contacts []Contact, debts []Debt, now time.Time) []OverdueDebt {
+	names := make(map[string]string, len(contacts))
+	for _, c := range contacts {
+		names[c.ID] = c.Name
+	}
+	var overdue []OverdueDebt
+	for _, d := range debts {
+		if d.Outstanding == 0 || d.DueDate == nil || !d.DueDate.Before(now) {
+			continue
+		}
+		overdue = append(overdue, OverdueDebt{
+			Debt:        d,
+			ContactName: names[d.ContactID],
+			DaysOverdue: int(now.Sub(*d.DueDate).Hours() / 24),
+		})
+	}
+	sort.Slice(overdue, func(i, j int) bool {
+		return overdue[i].DaysOverdue > overdue[j].DaysOverdue
+	})
+	return overdue
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+func (s *databaseStore) GetContacts(userID string) ([]Contact, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, name, email, phone, note, created_at
+        FROM contacts
+        WHERE user_id = $1
+        ORDER BY lower(name)
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %v", err)
+	}
+	defer rows.Close()
+
+	var contacts []Contact
+	for rows.Next() {
+		var c Contact
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Email, &c.Phone, &c.Note, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %v", err)
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+func (s *databaseStore) GetContact(userID, id string) (Contact, error) {
+	var c Contact
+	err := s.db.QueryRow(`
+        SELECT id, user_id, name, email, phone, note, created_at
+        FROM contacts
+        WHERE user_id = $1 AND id = $2
+    `, userID, id).Scan(&c.ID, &c.UserID, &c.Name, &c.Email, &c.Phone, &c.Note, &c.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Contact{}, fmt.Errorf("contact with ID %s not found", id)
+		}
+		return Contact{}, fmt.Errorf("failed to get contact: %v", err)
+	}
+	return c, nil
+}
+
+func (s *databaseStore) AddContact(userID string, contact Contact) (Contact, error) {
+	if userID == "" {
+		return Contact{}, errors.New("userID is required")
+	}
+	if contact.ID == "" {
+		contact.ID = uuid.New().String()
+	}
+	contact.UserID = userID
+	contact.CreatedAt = time.Now()
+	_, err := s.db.Exec(`
+        INSERT INTO contacts (id, user_id, name, email, phone, note, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, contact.ID, userID, contact.Name, contact.Email, contact.Phone, contact.Note, contact.CreatedAt)
+	if err != nil {
+		return Contact{}, fmt.Errorf("failed to insert contact: %v", err)
+	}
+	return contact, nil
+}
+
+func (s *databaseStore) UpdateContact(userID, id string, contact Contact) error {
+	res, err := s.db.Exec(`
+        UPDATE contacts
+        SET name = $1, email = $2, phone = $3, note = $4
+        WHERE id = $5 AND user_id = $6
+    `, contact.Name, contact.Email, contact.Phone, contact.Note, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update contact: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read update result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("contact with ID %s not found", id)
+	}
+	return nil
+}
+
+// RemoveContact deletes a contact together with its debts and repayments.
+func (s *databaseStore) RemoveContact(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM contacts WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("contact with ID %s not found", id)
+	}
+	return nil
+}
+
+func scanDebt(scanner interface{ Scan(...any) error }) (Debt, error) {
+	var d Debt
+	var expenseID, currency, description sql.NullString
+	var dueDate sql.NullTime
+	err := scanner.Scan(&d.ID, &d.UserID, &d.ContactID, &expenseID, &d.Direction, &d.Amount, &currency, &description, &d.Date, &dueDate)
+	if err != nil {
+		return Debt{}, err
+	}
+	d.ExpenseID = expenseID.String
+	d.Currency = currency.String
+	d.Description = description.String
+	if dueDate.Valid {
+		due := dueDate.Time
+		d.DueDate = &due
+	}
+	d.Repayments = []DebtRepayment{}
+	return d, nil
+}
+
+// GetDebts returns the user's debts with repayments, optionally filtered by contact.
+func (s *databaseStore) GetDebts(userID, contactID string) ([]Debt, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, contact_id, expense_id, direction, amount, currency, description, date, due_date
+        FROM debts
+        WHERE user_id = $1 AND ($2 = '' OR contact_id::text = $2)
+        ORDER BY date DESC
+    `, userID, contactID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query debts: %v", err)
+	}
+	defer rows.Close()
+
+	var debts []Debt
+	index := make(map[string]int)
+	for rows.Next() {
+		d, err := scanDebt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan debt: %v", err)
+		}
+		index[d.ID] = len(debts)
+		debts = append(debts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(debts) == 0 {
+		return debts, nil
+	}
+
+	repayments, err := s.db.Query(`
+        SELECT r.id, r.debt_id, r.expense_id, r.amount, r.date, r.note
+        FROM debt_repayments r
+        JOIN debts d ON d.id = r.debt_id
+        WHERE d.user_id = $1
+        ORDER BY r.date
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query debt repayments: %v", err)
+	}
+	defer repayments.Close()
+	for repayments.Next() {
+		p, err := scanRepayment(repayments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan debt repayment: %v", err)
+		}
+		if i, ok := index[p.DebtID]; ok {
+			debts[i].Repayments = append(debts[i].Repayments, p)
+		}
+	}
+	if err := repayments.Err(); err != nil {
+		return nil, err
+	}
+	for i := range debts {
+		debts[i].computeOutstanding()
+	}
+	return debts, nil
+}
+
+func (s *databaseStore) GetDebt(userID, id string) (Debt, error) {
+	d, err := scanDebt(s.db.QueryRow(`
+        SELECT id, user_id, contact_id, expense_id, direction, amount, currency, description, date, due_date
+        FROM debts
+        WHERE user_id = $1 AND id = $2
+    `, userID, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Debt{}, fmt.Errorf("debt with ID %s not found", id)
+		}
+		return Debt{}, fmt.Errorf("failed to get debt: %v", err)
+	}
+	rows, err := s.db.Query(`
+        SELECT id, debt_id, expense_id, amount, date, note
+        FROM debt_repayments
+        WHERE debt_id = $1
+        ORDER BY date
+    `, id)
+	if err != nil {
+		return Debt{}, fmt.Errorf("failed to query debt repayments: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanRepayment(rows)
+		if err != nil {
+			return Debt{}, fmt.Errorf("failed to scan debt repayment: %v", err)
+		}
+		d.Repayments = append(d.Repayments, p)
+	}
+	d.computeOutstanding()
+	return d, rows.Err()
+}
+
+func (s *databaseStore) AddDebt(userID string, debt Debt) (Debt, error) {
+	if userID == "" {
+		return Debt{}, errors.New("userID is required")
+	}
+	if _, err := s.GetContact(userID, debt.ContactID); err != nil {
+		return Debt{}, err
+	}
+	if debt.ID == "" {
+		debt.ID = uuid.New().String()
+	}
+	debt.UserID = userID
+	if debt.Currency == "" {
+		currency, err := s.GetCurrency(userID)
+		if err != nil {
+			return Debt{}, err
+		}
+		debt.Currency = currency
+	}
+	_, err := s.db.Exec(`
+        INSERT INTO debts (id, user_id, contact_id, expense_id, direction, amount, currency, description, date, due_date)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    `, debt.ID, userID, debt.ContactID, nullString(debt.ExpenseID), debt.Direction, debt.Amount, debt.Currency, debt.Description, debt.Date, debt.DueDate)
+	if err != nil {
+		return Debt{}, fmt.Errorf("failed to insert debt: %v", err)
+	}
+	debt.Repayments = []DebtRepayment{}
+	debt.computeOutstanding()
+	return debt, nil
+}
+
+func (s *databaseStore) UpdateDebt(userID, id string, debt Debt) error {
+	if _, err := s.GetContact(userID, debt.ContactID); err != nil {
+		return err
+	}
+	if debt.Currency == "" {
+		currency, err := s.GetCurrency(userID)
+		if err != nil {
+			return err
+		}
+		debt.Currency = currency
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// lock the debt so a repayment can't slip in between the check and the update
+	var locked string
+	err = tx.QueryRow(`SELECT id FROM debts WHERE user_id = $1 AND id = $2 FOR UPDATE`, userID, id).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("debt with ID %s not found", id)
+		}
+		return fmt.Errorf("failed to get debt: %v", err)
+	}
+	var repaid float64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM debt_repayments WHERE debt_id = $1`, id).Scan(&repaid); err != nil {
+		return fmt.Errorf("failed to sum debt repayments: %v", err)
+	}
+	if repaid-debt.Amount > 0.005 {
+		return fmt.Errorf("debt amount %.2f is below the %.2f already repaid", debt.Amount, repaid)
+	}
+	_, err = tx.Exec(`
+        UPDATE debts
+        SET contact_id = $1, expense_id = $2, direction = $3, amount = $4, currency = $5, description = $6, date = $7, due_date = $8
+        WHERE id = $9 AND user_id = $10
+    `, debt.ContactID, nullString(debt.ExpenseID), debt.Direction, debt.Amount, debt.Currency, debt.Description, debt.Date, debt.DueDate, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update debt: %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *databaseStore) RemoveDebt(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM debts WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete debt: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("debt with ID %s not found", id)
+	}
+	return nil
+}
+
+func scanRepayment(scanner interface{ Scan(...any) error }) (DebtRepayment, error) {
+	var p DebtRepayment
+	var expenseID, note sql.NullString
+	if err := scanner.Scan(&p.ID, &p.DebtID, &expenseID, &p.Amount, &p.Date, &note); err != nil {
+		return DebtRepayment{}, err
+	}
+	p.ExpenseID = expenseID.String
+	p.Note = note.String
+	return p, nil
+}
+
+// AddDebtRepayment records a (partial) repayment. Repayments larger than the
+// outstanding amount are rejected so balances never flip sign silently. The
+// debt row stays locked until the insert commits, so concurrent repayments
+// can't both pass the check.
+func (s *databaseStore) AddDebtRepayment(userID, debtID string, repayment DebtRepayment) (DebtRepayment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return DebtRepayment{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var amount float64
+	err = tx.QueryRow(`SELECT amount FROM debts WHERE user_id = $1 AND id = $2 FOR UPDATE`, userID, debtID).Scan(&amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return DebtRepayment{}, fmt.Errorf("debt with ID %s not found", debtID)
+		}
+		return DebtRepayment{}, fmt.Errorf("failed to get debt: %v", err)
+	}
+	var repaid float64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM debt_repayments WHERE debt_id = $1`, debtID).Scan(&repaid); err != nil {
+		return DebtRepayment{}, fmt.Errorf("failed to sum debt repayments: %v", err)
+	}
+	debt := Debt{Amount: amount, Repayments: []DebtRepayment{{Amount: repaid}}}
+	debt.computeOutstanding()
+	if repayment.Amount-debt.Outstanding > 0.005 {
+		return DebtRepayment{}, fmt.Errorf("repayment of %.2f exceeds outstanding amount %.2f", repayment.Amount, debt.Outstanding)
+	}
+	if repayment.ID == "" {
+		repayment.ID = uuid.New().String()
+	}
+	repayment.DebtID = debtID
+	_, err = tx.Exec(`
+        INSERT INTO debt_repayments (id, debt_id, expense_id, amount, date, note)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, repayment.ID, debtID, nullString(repayment.ExpenseID), repayment.Amount, repayment.Date, repayment.Note)
+	if err != nil {
+		return DebtRepayment{}, fmt.Errorf("failed to insert debt repayment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return DebtRepayment{}, fmt.Errorf("failed to commit debt repayment: %v", err)
+	}
+	return repayment, nil
+}
+
+func (s *databaseStore) RemoveDebtRepayment(userID, id string) error {
+	res, err := s.db.Exec(`
+        DELETE FROM debt_repayments r
+        USING debts d
+        WHERE r.debt_id = d.id AND d.user_id = $1 AND r.id = $2
+    `, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete debt repayment: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("debt repayment with ID %s not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// What happens to an incoming expense that looks like one already stored.
+const (
+	DuplicateActionSkip  = "skip"  // drop it
+	DuplicateActionFlag  = "flag"  // keep it with DuplicateOf set for review
+	DuplicateActionAllow = "allow" // keep it as is
+)
+
+// DuplicatePolicy is the per-user duplicate handling for imports and ingest.
+type DuplicatePolicy struct {
+	Action     string `json:"action"`
+	WindowDays int    `json:"windowDays"` // how many days apart two charges may be
+}
+
+// DefaultDuplicatePolicy flags rather than drops, so nothing is lost.
+var DefaultDuplicatePolicy = DuplicatePolicy{Action: DuplicateActionFlag, WindowDays: 3}
+
+func (p *DuplicatePolicy) Validate() error {
+	p.Action = strings.ToLower(strings.TrimSpace(p.Action))
+	switch p.Action {
+	case DuplicateActionSkip, DuplicateActionFlag, DuplicateActionAllow:
+	default:
+		return fmt.Errorf("invalid duplicate action: '%s'. Must be one of '%s', '%s' or '%s'", p.Action, DuplicateActionSkip, DuplicateActionFlag, DuplicateActionAllow)
+	}
+	if p.WindowDays < 0 || p.WindowDays > 31 {
+		return fmt.Errorf("duplicate 'windowDays' must be between 0 and 31")
+	}
+	return nil
+}
+
+// SimilarNames reports whether two expense names likely refer to the same
+// merchant: equal after normalization, one a word-prefix of the other (as in
+// "Amazon" and "Amazon Marketplace"), or sharing most of their words.
+func SimilarNames(a, b string) bool {
+	na, nb := NormalizeName(a), NormalizeName(b)
+	if na == "" || nb == "" {
+		return false
+	}
+	if na == nb || strings.HasPrefix(na, nb+" ") || strings.HasPrefix(nb, na+" ") {
+		return true
+	}
+	wordsA := strings.Fields(na)
+	wordsB := make(map[string]bool)
+	for _, word := range strings.Fields(nb) {
+		wordsB[word] = true
+	}
+	shared := 0
+	for _, word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	union := len(wordsA) + len(wordsB) - shared
+	return float64(shared)/float64(union) >= 0.6
+}
+
+// DuplicateIndex finds stored expenses that an incoming one duplicates: the
+// same amount, dates at most the policy window apart and similar names (or
+// the same payee).
+type DuplicateIndex struct {
+	window   time.Duration
+	byAmount map[int64][]Expense
+}
+
+func NewDuplicateIndex(expenses []Expense, policy DuplicatePolicy) *DuplicateIndex {
+	idx := &DuplicateIndex{
+		window:   time.Duration(policy.WindowDays)*24*time.Hour + 12*time.Hour, // slack for timezones
+		byAmount: make(map[int64][]Expense),
+	}
+	for _, expense := range expenses {
+		key := amountKey(expense.Amount)
+		idx.byAmount[key] = append(idx.byAmount[key], expense)
+	}
+	return idx
+}
+
+// Find returns the closest stored expense the candidate duplicates.
+func (idx *DuplicateIndex) Find(candidate Expense) (Expense, bool) {
+	var best Expense
+	bestGap := time.Duration(math.MaxInt64)
+	for _, existing := range idx.byAmount[amountKey(candidate.Amount)] {
+		if existing.ID == candidate.ID {
+			continue
+		}
+		gap := existing.Date.Sub(candidate.Date)
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap > idx.window || gap >= bestGap {
+			continue
+		}
+		samePayee := candidate.PayeeID != "" && candidate.PayeeID == existing.PayeeID
+		if samePayee || SimilarNames(candidate.Name, existing.Name) {
+			best, bestGap = existing, gap
+		}
+	}
+	return best, bestGap != time.Duration(math.MaxInt64)
+}
+
+func amountKey(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+func (s *databaseStore) GetDuplicatePolicy(userID string) (DuplicatePolicy, error) {
+	if err := s.EnsureUserDefaults(userID); err != nil {
+		return DuplicatePolicy{}, err
+	}
+	var policy DuplicatePolicy
+	err := s.db.QueryRow(`
+        SELECT duplicate_action, duplicate_window_days FROM user_settings WHERE user_id = $1
+    `, userID).Scan(&policy.Action, &policy.WindowDays)
+	if err != nil {
+		return DuplicatePolicy{}, fmt.Errorf("failed to load duplicate policy: %v", err)
+	}
+	return policy, nil
+}
+
+func (s *databaseStore) UpdateDuplicatePolicy(userID string, policy DuplicatePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if err := s.EnsureUserDefaults(userID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+        UPDATE user_settings SET duplicate_action = $1, duplicate_window_days = $2 WHERE user_id = $3
+    `, policy.Action, policy.WindowDays, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update duplicate policy: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ImportBatch is one import of a file. Its expenses carry the batch ID so the
+// whole import can be undone.
+type ImportBatch struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	Format     string    `json:"format"`    // csv, csvold, qif, ofx, camt053 or mt940
+	Imported   int       `json:"imported"`  // expenses stored by the import
+	Remaining  int       `json:"remaining"` // of those, expenses not deleted since
+	ExpenseIDs []string  `json:"expenseIds,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+// AddImportBatch records the batch and stores its expenses in one
+// transaction, so an import is stored completely or not at all. Adding to an
+// existing batch appends the expenses, which lets long imports store their
+// rows in chunks.
+func (s *databaseStore) AddImportBatch(userID string, batch ImportBatch, expenses []Expense) (ImportBatch, error) {
+	if userID == "" {
+		return ImportBatch{}, errors.New("userID is required")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertImportBatch(tx, userID, &batch, expenses); err != nil {
+		return ImportBatch{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to commit import batch: %v", err)
+	}
+	return batch, nil
+}
+
+// insertImportBatch records the batch, or adds to it, and copies its
+// expenses within tx.
+func insertImportBatch(tx *sql.Tx, userID string, batch *ImportBatch, expenses []Expense) error {
+	if batch.ID == "" {
+		batch.ID = uuid.New().String()
+	}
+	batch.UserID = userID
+	batch.Imported = len(expenses)
+	batch.CreatedAt = time.Now()
+	batch.ExpenseIDs = nil
+	for i := range expenses {
+		expenses[i].ImportBatchID = batch.ID
+	}
+
+	err := tx.QueryRow(`
+        INSERT INTO import_batches (id, user_id, format, imported, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (id) DO UPDATE SET imported = import_batches.imported + EXCLUDED.imported
+        WHERE import_batches.user_id = EXCLUDED.user_id
+        RETURNING imported, created_at
+    `, batch.ID, userID, batch.Format, batch.Imported, batch.CreatedAt).Scan(&batch.Imported, &batch.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("import batch with ID %s not found", batch.ID)
+		}
+		return fmt.Errorf("failed to insert import batch: %v", err)
+	}
+	if err := copyExpenses(tx, userID, expenses); err != nil {
+		return err
+	}
+	batch.Remaining = batch.Imported
+	return nil
+}
+
+func (s *databaseStore) GetImportBatches(userID string) ([]ImportBatch, error) {
+	rows, err := s.db.Query(`
+        SELECT b.id, b.user_id, b.format, b.imported, b.created_at,
+            (SELECT COUNT(*) FROM expenses e WHERE e.user_id = b.user_id AND e.import_batch_id = b.id)
+        FROM import_batches b
+        WHERE b.user_id = $1
+        ORDER BY b.created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import batches: %v", err)
+	}
+	defer rows.Close()
+
+	var batches []ImportBatch
+	for rows.Next() {
+		var b ImportBatch
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Format, &b.Imported, &b.CreatedAt, &b.Remaining); err != nil {
+			return nil, fmt.Errorf("failed to scan import batch: %v", err)
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// GetImportBatch returns a batch with the IDs of its remaining expenses.
+func (s *databaseStore) GetImportBatch(userID, id string) (ImportBatch, error) {
+	var b ImportBatch
+	err := s.db.QueryRow(`
+        SELECT id, user_id, format, imported, created_at
+        FROM import_batches
+        WHERE user_id = $1 AND id = $2
+    `, userID, id).Scan(&b.ID, &b.UserID, &b.Format, &b.Imported, &b.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ImportBatch{}, fmt.Errorf("import batch with ID %s not found", id)
+		}
+		return ImportBatch{}, fmt.Errorf("failed to get import batch: %v", err)
+	}
+	var ids pq.StringArray
+	err = s.db.QueryRow(`
+        SELECT COALESCE(array_agg(id::text), '{}') FROM expenses
+        WHERE user_id = $1 AND import_batch_id = $2
+    `, userID, id).Scan(&ids)
+	if err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to get import batch expenses: %v", err)
+	}
+	b.ExpenseIDs = ids
+	b.Remaining = len(ids)
+	return b, nil
+}
+
+// RemoveImportBatch deletes the remaining expenses of a batch together with
+// the batch itself.
+func (s *databaseStore) RemoveImportBatch(userID, id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM import_batches WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete import batch: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("import batch with ID %s not found", id)
+	}
+	if _, err := tx.Exec(`DELETE FROM expenses WHERE user_id = $1 AND import_batch_id = $2`, userID, id); err != nil {
+		return fmt.Errorf("failed to delete imported expenses: %v", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tanq16/expenseowl/internal/importer"
+)
+
+// ImportLocale is how a user's bank files write numbers and dates. Locale is
+// a language tag such as "de-DE"; the other fields override its conventions
+// and are detected per value when neither is set.
+type ImportLocale struct {
+	Locale           string `json:"locale"`
+	DecimalSeparator string `json:"decimalSeparator"` // "." or ","
+	DateOrder        string `json:"dateOrder"`        // dmy or mdy
+}
+
+func (l *ImportLocale) Validate() error {
+	l.Locale = strings.TrimSpace(l.Locale)
+	l.DecimalSeparator = strings.TrimSpace(l.DecimalSeparator)
+	l.DateOrder = strings.ToLower(strings.TrimSpace(l.DateOrder))
+	if l.Locale != "" {
+		if _, ok := importer.LookupLocale(l.Locale); !ok {
+			return fmt.Errorf("unsupported locale: '%s'", l.Locale)
+		}
+	}
+	if l.DecimalSeparator != "" && l.DecimalSeparator != "." && l.DecimalSeparator != "," {
+		return fmt.Errorf("'decimalSeparator' must be '.' or ','")
+	}
+	if l.DateOrder != "" && l.DateOrder != importer.DateOrderDMY && l.DateOrder != importer.DateOrderMDY {
+		return fmt.Errorf("'dateOrder' must be '%s' or '%s'", importer.DateOrderDMY, importer.DateOrderMDY)
+	}
+	return nil
+}
+
+// Resolve returns the parsing conventions, explicit fields winning over the
+// locale's.
+func (l ImportLocale) Resolve() importer.Locale {
+	locale, _ := importer.LookupLocale(l.Locale)
+	if l.DecimalSeparator != "" {
+		locale.DecimalSeparator = l.DecimalSeparator
+	}
+	if l.DateOrder != "" {
+		locale.DateOrder = l.DateOrder
+	}
+	return locale
+}
+
+// Override layers the settings of a single import over l. A locale given
+// for the import replaces l's along with its overrides.
+func (l ImportLocale) Override(o ImportLocale) ImportLocale {
+	if o.Locale == "" {
+		o.Locale = l.Locale
+		if o.DecimalSeparator == "" {
+			o.DecimalSeparator = l.DecimalSeparator
+		}
+		if o.DateOrder == "" {
+			o.DateOrder = l.DateOrder
+		}
+	}
+	return o
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+func (s *databaseStore) GetImportLocale(userID string) (ImportLocale, error) {
+	if err := s.EnsureUserDefaults(userID); err != nil {
+		return ImportLocale{}, err
+	}
+	var locale ImportLocale
+	err := s.db.QueryRow(`
+        SELECT import_locale, import_decimal_separator, import_date_order FROM user_settings WHERE user_id = $1
+    `, userID).Scan(&locale.Locale, &locale.DecimalSeparator, &locale.DateOrder)
+	if err != nil {
+		return ImportLocale{}, fmt.Errorf("failed to load import locale: %v", err)
+	}
+	return locale, nil
+}
+
+func (s *databaseStore) UpdateImportLocale(userID string, locale ImportLocale) error {
+	if err := locale.Validate(); err != nil {
+		return err
+	}
+	if err := s.EnsureUserDefaults(userID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+        UPDATE user_settings SET import_locale = $1, import_decimal_separator = $2, import_date_order = $3 WHERE user_id = $4
+    `, locale.Locale, locale.DecimalSeparator, locale.DateOrder, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update import locale: %v", err)
+	}
+	return nil
+}
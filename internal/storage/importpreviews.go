@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportPreviewTTL is how long a previewed import can be committed.
+const ImportPreviewTTL = 24 * time.Hour
+
+// ImportPreview holds the checked rows of an import dry run until the user
+// commits them. Blob is encrypted like expense blobs when the user has a key.
+type ImportPreview struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Format    string    `json:"format"`
+	Blob      string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+// AddImportPreview stores a preview and drops the expired ones.
+func (s *databaseStore) AddImportPreview(userID string, preview ImportPreview) (ImportPreview, error) {
+	if userID == "" {
+		return ImportPreview{}, errors.New("userID is required")
+	}
+	if _, err := s.db.Exec(`DELETE FROM import_previews WHERE created_at < $1`, time.Now().Add(-ImportPreviewTTL)); err != nil {
+		return ImportPreview{}, fmt.Errorf("failed to delete expired import previews: %v", err)
+	}
+	if preview.ID == "" {
+		preview.ID = uuid.New().String()
+	}
+	preview.UserID = userID
+	preview.CreatedAt = time.Now()
+	_, err := s.db.Exec(`
+        INSERT INTO import_previews (id, user_id, format, blob, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, preview.ID, userID, preview.Format, preview.Blob, preview.CreatedAt)
+	if err != nil {
+		return ImportPreview{}, fmt.Errorf("failed to insert import preview: %v", err)
+	}
+	return preview, nil
+}
+
+func (s *databaseStore) GetImportPreview(userID, id string) (ImportPreview, error) {
+	var p ImportPreview
+	err := s.db.QueryRow(`
+        SELECT id, user_id, format, blob, created_at
+        FROM import_previews
+        WHERE user_id = $1 AND id = $2 AND created_at >= $3
+    `, userID, id, time.Now().Add(-ImportPreviewTTL)).Scan(&p.ID, &p.UserID, &p.Format, &p.Blob, &p.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ImportPreview{}, fmt.Errorf("import preview with ID %s not found", id)
+		}
+		return ImportPreview{}, fmt.Errorf("failed to get import preview: %v", err)
+	}
+	return p, nil
+}
+
+func (s *databaseStore) RemoveImportPreview(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM import_previews WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete import preview: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("import preview with ID %s not found", id)
+	}
+	return nil
+}
+
+// CommitImportPreview deletes a preview and stores the expenses committed
+// from it as one import batch, in one transaction. The delete only succeeds
+// once, so committing the same preview twice imports nothing the second time.
+func (s *databaseStore) CommitImportPreview(userID, previewID string, batch ImportBatch, expenses []Expense) (ImportBatch, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM import_previews WHERE user_id = $1 AND id = $2`, userID, previewID)
+	if err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to delete import preview: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ImportBatch{}, fmt.Errorf("import preview with ID %s not found", previewID)
+	}
+	if err := insertImportBatch(tx, userID, &batch, expenses); err != nil {
+		return ImportBatch{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ImportBatch{}, fmt.Errorf("failed to commit import preview: %v", err)
+	}
+	return batch, nil
+}
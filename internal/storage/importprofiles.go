@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/importer"
+)
+
+// Sign conventions of bank CSV exports.
+const (
+	ImportSignAsIs        = "asis"        // negative amounts are expenses, as in ExpenseOwl
+	ImportSignNegate      = "negate"      // positive amounts are expenses, common in card exports
+	ImportSignDebitCredit = "debitcredit" // separate unsigned debit and credit columns
+)
+
+// ImportProfile describes the CSV layout of one bank's export so the file
+// can be uploaded as is.
+type ImportProfile struct {
+	ID               string        `json:"id"`
+	UserID           string        `json:"userId"`
+	Name             string        `json:"name"`
+	Delimiter        string        `json:"delimiter"`        // single character, "tab" for tab-separated files
+	DecimalSeparator string        `json:"decimalSeparator"` // "." or ","
+	DateFormat       string        `json:"dateFormat"`       // e.g. DD.MM.YYYY, empty for the standard formats
+	SignConvention   string        `json:"signConvention"`
+	Columns          ImportColumns `json:"columns"`
+	DefaultCategory  string        `json:"defaultCategory,omitempty"`
+	SkipRows         int           `json:"skipRows"` // lines before the header row
+	CreatedAt        time.Time     `json:"createdAt"`
+}
+
+// ImportColumns maps expense fields to header names of the bank file.
+// Header names are matched without regard to case.
+type ImportColumns struct {
+	Name     string `json:"name"`
+	Date     string `json:"date"`
+	Amount   string `json:"amount,omitempty"`
+	Debit    string `json:"debit,omitempty"`
+	Credit   string `json:"credit,omitempty"`
+	Category string `json:"category,omitempty"`
+	Tags     string `json:"tags,omitempty"`
+	Note     string `json:"note,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Account  string `json:"account,omitempty"`
+}
+
+func (p *ImportProfile) Validate() error {
+	p.Name = SanitizeString(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("import profile 'name' cannot be empty")
+	}
+	switch strings.ToLower(p.Delimiter) {
+	case "":
+		p.Delimiter = ","
+	case "tab", `\t`:
+		p.Delimiter = "\t"
+	}
+	if r, size := utf8.DecodeRuneInString(p.Delimiter); size != len(p.Delimiter) || r == '"' || r == '\n' || r == '\r' {
+		return fmt.Errorf("'delimiter' must be a single character")
+	}
+	if p.DecimalSeparator == "" {
+		p.DecimalSeparator = "."
+	}
+	if p.DecimalSeparator != "." && p.DecimalSeparator != "," {
+		return fmt.Errorf("'decimalSeparator' must be '.' or ','")
+	}
+	if p.DecimalSeparator == p.Delimiter {
+		return fmt.Errorf("'decimalSeparator' cannot be the same as 'delimiter'")
+	}
+	p.DateFormat = strings.TrimSpace(p.DateFormat)
+	if _, err := p.dateLayout(); err != nil {
+		return err
+	}
+	if p.SignConvention == "" {
+		p.SignConvention = ImportSignAsIs
+	}
+	c := &p.Columns
+	for _, column := range []*string{&c.Name, &c.Date, &c.Amount, &c.Debit, &c.Credit, &c.Category, &c.Tags, &c.Note, &c.Currency, &c.Account} {
+		*column = strings.TrimSpace(*column)
+	}
+	if c.Name == "" || c.Date == "" {
+		return fmt.Errorf("the 'name' and 'date' columns must be mapped")
+	}
+	switch p.SignConvention {
+	case ImportSignAsIs, ImportSignNegate:
+		if c.Amount == "" {
+			return fmt.Errorf("the 'amount' column must be mapped")
+		}
+	case ImportSignDebitCredit:
+		if c.Debit == "" || c.Credit == "" {
+			return fmt.Errorf("the 'debit' and 'credit' columns must be mapped")
+		}
+	default:
+		return fmt.Errorf("'signConvention' must be one of %s, %s or %s", ImportSignAsIs, ImportSignNegate, ImportSignDebitCredit)
+	}
+	p.DefaultCategory = SanitizeString(p.DefaultCategory)
+	if p.SkipRows < 0 {
+		return fmt.Errorf("'skipRows' cannot be negative")
+	}
+	return nil
+}
+
+// Date format tokens, longest first so "YYYY" wins over "YY".
+var importDateTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"}, {"DD", "02"}, {"D", "2"},
+	{"hh", "15"}, {"mm", "04"}, {"ss", "05"},
+}
+
+// dateLayout turns the DateFormat tokens into a Go time layout.
+func (p ImportProfile) dateLayout() (string, error) {
+	var layout strings.Builder
+	rest := p.DateFormat
+	for rest != "" {
+		matched := false
+		for _, t := range importDateTokens {
+			if strings.HasPrefix(rest, t.token) {
+				layout.WriteString(t.layout)
+				rest = rest[len(t.token):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(rest)
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return "", fmt.Errorf("'dateFormat' may only contain YYYY, YY, MMM, MM, M, DD, D, hh, mm, ss and separators")
+		}
+		layout.WriteString(rest[:size])
+		rest = rest[size:]
+	}
+	return layout.String(), nil
+}
+
+// ParseDate reads a date in the profile's format. Profiles without a format
+// return ok false so the caller falls back to the standard formats.
+func (p ImportProfile) ParseDate(value string) (time.Time, bool, error) {
+	if p.DateFormat == "" {
+		return time.Time{}, false, nil
+	}
+	layout, err := p.dateLayout()
+	if err != nil {
+		return time.Time{}, true, err
+	}
+	date, err := time.Parse(layout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("date %q does not match format %s", value, p.DateFormat)
+	}
+	return date, true, nil
+}
+
+// ParseAmount reads a number written with the profile's decimal separator;
+// the other separator is taken as a thousands separator. Currency symbols and
+// parentheses for negative amounts are understood as well.
+func (p ImportProfile) ParseAmount(value string) (float64, error) {
+	return importer.Locale{DecimalSeparator: p.DecimalSeparator}.ParseAmount(value)
+}
+
+// SignedAmount applies the sign convention to the amount cells of a row.
+// debit and credit are only read with ImportSignDebitCredit, where an empty
+// cell counts as zero.
+func (p ImportProfile) SignedAmount(amount, debit, credit string) (float64, error) {
+	if p.SignConvention != ImportSignDebitCredit {
+		value, err := p.ParseAmount(amount)
+		if err != nil {
+			return 0, err
+		}
+		if p.SignConvention == ImportSignNegate {
+			value = -value
+		}
+		return value, nil
+	}
+	var out, in float64
+	var err error
+	if strings.TrimSpace(debit) != "" {
+		if out, err = p.ParseAmount(debit); err != nil {
+			return 0, err
+		}
+	}
+	if strings.TrimSpace(credit) != "" {
+		if in, err = p.ParseAmount(credit); err != nil {
+			return 0, err
+		}
+	}
+	return math.Abs(in) - math.Abs(out), nil
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+func (s *databaseStore) GetImportProfiles(userID string) ([]ImportProfile, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, name, delimiter, decimal_separator, date_format, sign_convention, columns, default_category, skip_rows, created_at
+        FROM import_profiles
+        WHERE user_id = $1
+        ORDER BY lower(name)
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import profiles: %v", err)
+	}
+	defer rows.Close()
+
+	var profiles []ImportProfile
+	for rows.Next() {
+		profile, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+func (s *databaseStore) GetImportProfile(userID, id string) (ImportProfile, error) {
+	profile, err := scanImportProfile(s.db.QueryRow(`
+        SELECT id, user_id, name, delimiter, decimal_separator, date_format, sign_convention, columns, default_category, skip_rows, created_at
+        FROM import_profiles
+        WHERE user_id = $1 AND id = $2
+    `, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ImportProfile{}, fmt.Errorf("import profile with ID %s not found", id)
+	}
+	return profile, err
+}
+
+func (s *databaseStore) AddImportProfile(userID string, profile ImportProfile) (ImportProfile, error) {
+	if userID == "" {
+		return ImportProfile{}, errors.New("userID is required")
+	}
+	if profile.ID == "" {
+		profile.ID = uuid.New().String()
+	}
+	profile.UserID = userID
+	profile.CreatedAt = time.Now()
+	columns, err := json.Marshal(profile.Columns)
+	if err != nil {
+		return ImportProfile{}, fmt.Errorf("failed to serialize import columns: %v", err)
+	}
+	_, err = s.db.Exec(`
+        INSERT INTO import_profiles (id, user_id, name, delimiter, decimal_separator, date_format, sign_convention, columns, default_category, skip_rows, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+    `, profile.ID, userID, profile.Name, profile.Delimiter, profile.DecimalSeparator, profile.DateFormat, profile.SignConvention, columns, profile.DefaultCategory, profile.SkipRows, profile.CreatedAt)
+	if err != nil {
+		return ImportProfile{}, fmt.Errorf("failed to insert import profile: %v", err)
+	}
+	return profile, nil
+}
+
+func (s *databaseStore) UpdateImportProfile(userID, id string, profile ImportProfile) error {
+	columns, err := json.Marshal(profile.Columns)
+	if err != nil {
+		return fmt.Errorf("failed to serialize import columns: %v", err)
+	}
+	res, err := s.db.Exec(`
+        UPDATE import_profiles
+        SET name = $1, delimiter = $2, decimal_separator = $3, date_format = $4, sign_convention = $5, columns = $6, default_category = $7, skip_rows = $8
+        WHERE id = $9 AND user_id = $10
+    `, profile.Name, profile.Delimiter, profile.DecimalSeparator, profile.DateFormat, profile.SignConvention, columns, profile.DefaultCategory, profile.SkipRows, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update import profile: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read update result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("import profile with ID %s not found", id)
+	}
+	return nil
+}
+
+func (s *databaseStore) RemoveImportProfile(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM import_profiles WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete import profile: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("import profile with ID %s not found", id)
+	}
+	return nil
+}
+
+func scanImportProfile(row rowScanner) (ImportProfile, error) {
+	var p ImportProfile
+	var columns []byte
+	if err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Delimiter, &p.DecimalSeparator, &p.DateFormat, &p.SignConvention, &columns, &p.DefaultCategory, &p.SkipRows, &p.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return p, err
+		}
+		return p, fmt.Errorf("failed to scan import profile: %v", err)
+	}
+	if err := json.Unmarshal(columns, &p.Columns); err != nil {
+		return p, fmt.Errorf("failed to parse import columns: %v", err)
+	}
+	return p, nil
+}
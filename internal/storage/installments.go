@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	RecurringKindRecurring   = "recurring"
+	RecurringKindInstallment = "installment"
+)
+
+// InstallmentRef marks a generated expense as one payment of an installment
+// plan; the plan itself is the expense's RecurringID.
+type InstallmentRef struct {
+	Number int `json:"number"`
+	Of     int `json:"of"`
+}
+
+// InstallmentStatus summarises the progress of an installment plan.
+type InstallmentStatus struct {
+	Plan              RecurringExpense `json:"plan"`
+	Total             float64          `json:"total"` // principal plus fees
+	PaymentAmount     float64          `json:"paymentAmount"`
+	PaymentsMade      int              `json:"paymentsMade"`
+	PaymentsRemaining int              `json:"paymentsRemaining"`
+	AmountPaid        float64          `json:"amountPaid"`
+	AmountRemaining   float64          `json:"amountRemaining"`
+	NextPaymentDate   *time.Time       `json:"nextPaymentDate,omitempty"`
+	FinalPaymentDate  time.Time        `json:"finalPaymentDate"`
+}
+
+// IsInstallment reports whether the recurring rule is an installment plan.
+func (e RecurringExpense) IsInstallment() bool {
+	return e.Kind == RecurringKindInstallment
+}
+
+func (e *RecurringExpense) validateInstallment() error {
+	e.Principal = math.Abs(e.Principal)
+	if e.Principal == 0 {
+		return fmt.Errorf("installment plan requires a 'principal'")
+	}
+	if e.Fees < 0 {
+		return fmt.Errorf("installment 'fees' cannot be negative")
+	}
+	if e.Occurrences < 2 {
+		return fmt.Errorf("installment plan requires at least 2 payments")
+	}
+	payments := e.InstallmentAmounts()
+	e.Amount = payments[0]
+	return nil
+}
+
+// InstallmentAmounts splits principal plus fees into Occurrences payments,
+// booked as negative amounts. The last payment absorbs the rounding remainder.
+func (e RecurringExpense) InstallmentAmounts() []float64 {
+	if e.Occurrences <= 0 {
+		return nil
+	}
+	totalCents := int64(math.Round((e.Principal + e.Fees) * 100))
+	base := totalCents / int64(e.Occurrences)
+	payments := make([]float64, e.Occurrences)
+	for i := range payments {
+		cents := base
+		if i == len(payments)-1 {
+			cents = totalCents - base*int64(e.Occurrences-1)
+		}
+		payments[i] = -float64(cents) / 100
+	}
+	return payments
+}
+
+// InstallmentStatusAt reports how much of the plan is paid as of now. A
+// payment counts as made once its date has passed.
+func InstallmentStatusAt(plan RecurringExpense, now time.Time) InstallmentStatus {
+	payments := plan.InstallmentAmounts()
+	status := InstallmentStatus{
+		Plan:  plan,
+		Total: roundCents(plan.Principal + plan.Fees),
+	}
+	if len(payments) > 0 {
+		status.PaymentAmount = -payments[0]
+	}
+	date := plan.StartDate
+	for i, payment := range payments {
+		if date.After(now) {
+			if status.NextPaymentDate == nil {
+				next := date
+				status.NextPaymentDate = &next
+			}
+			status.PaymentsRemaining++
+			status.AmountRemaining -= payment
+		} else {
+			status.PaymentsMade++
+			status.AmountPaid -= payment
+		}
+		status.FinalPaymentDate = date
+		if i < len(payments)-1 {
+			next, ok := advanceDate(date, plan.Interval)
+			if !ok {
+				break
+			}
+			date = next
+		}
+	}
+	status.AmountPaid = roundCents(status.AmountPaid)
+	status.AmountRemaining = roundCents(status.AmountRemaining)
+	return status
+}
+
+// advanceDate moves a date forward by one recurrence interval.
+func advanceDate(date time.Time, interval string) (time.Time, bool) {
+	switch interval {
+	case "daily":
+		return date.AddDate(0, 0, 1), true
+	case "weekly":
+		return date.AddDate(0, 0, 7), true
+	case "monthly":
+		return date.AddDate(0, 1, 0), true
+	case "yearly":
+		return date.AddDate(1, 0, 0), true
+	}
+	return date, false
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
     "fmt"
+    "time"
+
     "github.com/tanq16/expenseowl/internal/encryption"
 )
 
@@ -53,6 +55,12 @@ func (s *jsonStore) RemoveRecurringExpense(userID, id string, removeAll bool) er
 func (s *jsonStore) UpdateRecurringExpense(userID, id string, recurringExpense RecurringExpense, updateAll bool, enc *encryption.Manager) error {
     return fmt.Errorf("json backend not available")
 }
+func (s *jsonStore) AdoptRecurringExpense(userID string, recurringExpense RecurringExpense, linked []Expense, enc *encryption.Manager) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetRecurringExpenseIDs(userID, recurringID string) ([]string, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
 func (s *jsonStore) GetAllExpenses(userID string) ([]Expense, error) {
 	return nil, fmt.Errorf("json backend not available")
 }
@@ -74,3 +82,205 @@ func (s *jsonStore) RemoveMultipleExpenses(userID string, ids []string) error {
 func (s *jsonStore) UpdateExpense(userID, id string, expense Expense) error {
 	return fmt.Errorf("json backend not available")
 }
+
+func (s *jsonStore) GetContacts(userID string) ([]Contact, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetContact(userID, id string) (Contact, error) {
+	return Contact{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddContact(userID string, contact Contact) (Contact, error) {
+	return Contact{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdateContact(userID, id string, contact Contact) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveContact(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetDebts(userID, contactID string) ([]Debt, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetDebt(userID, id string) (Debt, error) {
+	return Debt{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddDebt(userID string, debt Debt) (Debt, error) {
+	return Debt{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdateDebt(userID, id string, debt Debt) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveDebt(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddDebtRepayment(userID, debtID string, repayment DebtRepayment) (DebtRepayment, error) {
+	return DebtRepayment{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveDebtRepayment(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetAttachments(userID, expenseID string) ([]Attachment, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetAttachment(userID, id string) (Attachment, error) {
+	return Attachment{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddAttachment(userID string, attachment Attachment) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveAttachment(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetRefundLinks(userID string) ([]RefundLink, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddRefundLink(userID string, link RefundLink) (RefundLink, error) {
+	return RefundLink{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveRefundLink(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetReconciliations(userID string) ([]Reconciliation, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetReconciliation(userID, id string) (Reconciliation, error) {
+	return Reconciliation{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) LastReconciledBalance(userID, account string) (float64, error) {
+	return 0, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddReconciliation(userID string, rec Reconciliation) (Reconciliation, error) {
+	return Reconciliation{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveReconciliation(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddReconciliationItem(userID, reconciliationID, expenseID string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveReconciliationItem(userID, reconciliationID, expenseID string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) CompleteReconciliation(userID, id string, reconciled []Expense) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetLockedExpenseIDs(userID string, ids []string) ([]string, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetClosedPeriods(userID string) ([]ClosedPeriod, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) ClosePeriod(userID string, date time.Time) (ClosedPeriod, error) {
+	return ClosedPeriod{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) ReopenPeriod(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetAnomalyFlags(userID string, includeDismissed bool) ([]AnomalyFlag, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddAnomalyFlag(userID string, flag AnomalyFlag) (bool, error) {
+	return false, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) DismissAnomalyFlag(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetCategorizationRules(userID string) ([]CategorizationRule, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetCategorizationRule(userID, id string) (CategorizationRule, error) {
+	return CategorizationRule{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddCategorizationRule(userID string, rule CategorizationRule) (CategorizationRule, error) {
+	return CategorizationRule{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdateCategorizationRule(userID, id string, rule CategorizationRule) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveCategorizationRule(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) ReorderCategorizationRules(userID string, ids []string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetPayees(userID string) ([]Payee, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetPayee(userID, id string) (Payee, error) {
+	return Payee{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddPayee(userID string, payee Payee) (Payee, error) {
+	return Payee{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdatePayee(userID, id string, payee Payee, renamed []Expense) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemovePayee(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetDuplicatePolicy(userID string) (DuplicatePolicy, error) {
+	return DuplicatePolicy{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdateDuplicatePolicy(userID string, policy DuplicatePolicy) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetExternalRefs(userID string) (map[string]string, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetStatements(userID string) ([]Statement, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddStatement(userID string, statement Statement) (Statement, error) {
+	return Statement{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetImportProfiles(userID string) ([]ImportProfile, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetImportProfile(userID, id string) (ImportProfile, error) {
+	return ImportProfile{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddImportProfile(userID string, profile ImportProfile) (ImportProfile, error) {
+	return ImportProfile{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdateImportProfile(userID, id string, profile ImportProfile) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveImportProfile(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddImportPreview(userID string, preview ImportPreview) (ImportPreview, error) {
+	return ImportPreview{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetImportPreview(userID, id string) (ImportPreview, error) {
+	return ImportPreview{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveImportPreview(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) CommitImportPreview(userID, previewID string, batch ImportBatch, expenses []Expense) (ImportBatch, error) {
+	return ImportBatch{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) AddImportBatch(userID string, batch ImportBatch, expenses []Expense) (ImportBatch, error) {
+	return ImportBatch{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetImportBatches(userID string) ([]ImportBatch, error) {
+	return nil, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetImportBatch(userID, id string) (ImportBatch, error) {
+	return ImportBatch{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RemoveImportBatch(userID, id string) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetImportLocale(userID string) (ImportLocale, error) {
+	return ImportLocale{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) UpdateImportLocale(userID string, locale ImportLocale) error {
+	return fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) GetBackup(userID string) (Backup, error) {
+	return Backup{}, fmt.Errorf("json backend not available")
+}
+func (s *jsonStore) RestoreBackup(userID string, backup Backup, merge bool, enc *encryption.Manager) (BackupRestore, error) {
+	return BackupRestore{}, fmt.Errorf("json backend not available")
+}
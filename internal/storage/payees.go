@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Payee is a merchant or person money is paid to. Aliases are the raw names
+// banks and ingest sources use for it, such as "AMZN MKTP" for Amazon.
+type Payee struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"userId"`
+	Name            string    `json:"name"`
+	Aliases         []string  `json:"aliases"`
+	DefaultCategory string    `json:"defaultCategory,omitempty"`
+	DefaultTags     []string  `json:"defaultTags,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+func (p *Payee) Validate() error {
+	p.Name = SanitizeString(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("payee 'name' cannot be empty")
+	}
+	aliases := []string{}
+	seen := map[string]bool{NormalizeName(p.Name): true}
+	for _, alias := range p.Aliases {
+		alias = SanitizeString(alias)
+		key := NormalizeName(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		aliases = append(aliases, alias)
+	}
+	p.Aliases = aliases
+	p.DefaultCategory = SanitizeString(p.DefaultCategory)
+	var tags []string
+	for _, tag := range p.DefaultTags {
+		if tag = SanitizeString(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	p.DefaultTags = tags
+	return nil
+}
+
+// NormalizeName reduces a merchant or expense name to lowercase words so
+// variants like "NETFLIX.COM 4821" and "Netflix.com" compare equal. Digits
+// are dropped because banks append references and card numbers to names.
+func NormalizeName(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// MatchPayee finds the payee for a raw expense name. The payee's name or an
+// alias matches when the normalized expense name starts with it on a word
+// boundary, so the alias "AMZN MKTP" covers "AMZN MKTP US*2K4L". The longest
+// match wins.
+func MatchPayee(payees []Payee, name string) (Payee, bool) {
+	normalized := NormalizeName(name)
+	if normalized == "" {
+		return Payee{}, false
+	}
+	var best Payee
+	bestLen := 0
+	for _, payee := range payees {
+		for _, candidate := range append([]string{payee.Name}, payee.Aliases...) {
+			key := NormalizeName(candidate)
+			if key == "" || len(key) <= bestLen {
+				continue
+			}
+			if normalized == key || strings.HasPrefix(normalized, key+" ") {
+				best, bestLen = payee, len(key)
+			}
+		}
+	}
+	return best, bestLen > 0
+}
+
+// AssignPayee maps the expense to a matching payee: the payee's name replaces
+// the raw name and its default category and tags are applied. It reports
+// whether a payee matched.
+func AssignPayee(payees []Payee, expense *Expense) bool {
+	payee, ok := MatchPayee(payees, expense.Name)
+	if !ok {
+		return false
+	}
+	expense.PayeeID = payee.ID
+	expense.Name = payee.Name
+	if payee.DefaultCategory != "" {
+		expense.Category = payee.DefaultCategory
+	}
+	for _, tag := range payee.DefaultTags {
+		present := false
+		for _, existing := range expense.Tags {
+			present = present || strings.EqualFold(existing, tag)
+		}
+		if !present {
+			expense.Tags = append(expense.Tags, tag)
+		}
+	}
+	return true
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+func (s *databaseStore) GetPayees(userID string) ([]Payee, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, name, aliases, default_category, default_tags, created_at
+        FROM payees
+        WHERE user_id = $1
+        ORDER BY lower(name)
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payees: %v", err)
+	}
+	defer rows.Close()
+
+	var payees []Payee
+	for rows.Next() {
+		var p Payee
+		var aliases, tags pq.StringArray
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &aliases, &p.DefaultCategory, &tags, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan payee: %v", err)
+		}
+		p.Aliases = []string(aliases)
+		p.DefaultTags = []string(tags)
+		payees = append(payees, p)
+	}
+	return payees, rows.Err()
+}
+
+func (s *databaseStore) GetPayee(userID, id string) (Payee, error) {
+	var p Payee
+	var aliases, tags pq.StringArray
+	err := s.db.QueryRow(`
+        SELECT id, user_id, name, aliases, default_category, default_tags, created_at
+        FROM payees
+        WHERE user_id = $1 AND id = $2
+    `, userID, id).Scan(&p.ID, &p.UserID, &p.Name, &aliases, &p.DefaultCategory, &tags, &p.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Payee{}, fmt.Errorf("payee with ID %s not found", id)
+		}
+		return Payee{}, fmt.Errorf("failed to get payee: %v", err)
+	}
+	p.Aliases = []string(aliases)
+	p.DefaultTags = []string(tags)
+	return p, nil
+}
+
+func (s *databaseStore) AddPayee(userID string, payee Payee) (Payee, error) {
+	if userID == "" {
+		return Payee{}, errors.New("userID is required")
+	}
+	if payee.ID == "" {
+		payee.ID = uuid.New().String()
+	}
+	payee.UserID = userID
+	payee.CreatedAt = time.Now()
+	_, err := s.db.Exec(`
+        INSERT INTO payees (id, user_id, name, aliases, default_category, default_tags, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, payee.ID, userID, payee.Name, pq.Array(payee.Aliases), payee.DefaultCategory, pq.Array(payee.DefaultTags), payee.CreatedAt)
+	if err != nil {
+		return Payee{}, fmt.Errorf("failed to insert payee: %v", err)
+	}
+	return payee, nil
+}
+
+// UpdatePayee updates a payee and, in the same transaction, stores its
+// renamed expenses.
+func (s *databaseStore) UpdatePayee(userID, id string, payee Payee, renamed []Expense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        UPDATE payees
+        SET name = $1, aliases = $2, default_category = $3, default_tags = $4
+        WHERE id = $5 AND user_id = $6
+    `, payee.Name, pq.Array(payee.Aliases), payee.DefaultCategory, pq.Array(payee.DefaultTags), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update payee: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read update result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("payee with ID %s not found", id)
+	}
+	for _, expense := range renamed {
+		if expense.Blob == "" {
+			raw, err := json.Marshal(expense)
+			if err != nil {
+				return fmt.Errorf("failed to serialize expense: %v", err)
+			}
+			expense.Blob = string(raw)
+		}
+		if _, err := tx.Exec(`UPDATE expenses SET blob = $1 WHERE id = $2 AND user_id = $3`, expense.Blob, expense.ID, userID); err != nil {
+			return fmt.Errorf("failed to rename expense %s: %v", expense.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *databaseStore) RemovePayee(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM payees WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete payee: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("payee with ID %s not found", id)
+	}
+	return nil
+}
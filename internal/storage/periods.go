@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClosedPeriod is a reviewed budgeting period. Expenses dated within
+// [PeriodStart, PeriodEnd) cannot be added, changed or removed until it is
+// reopened.
+type ClosedPeriod struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	ClosedAt    time.Time `json:"closedAt"`
+}
+
+// PeriodBounds returns the budgeting period containing t. Periods start on
+// the user's start day of the month; in shorter months the start day is
+// clamped to the last day.
+func PeriodBounds(t time.Time, startDay int) (time.Time, time.Time) {
+	t = t.UTC()
+	periodStartIn := func(year int, month time.Month) time.Time {
+		day := startDay
+		if last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+			day = last
+		}
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	start := periodStartIn(t.Year(), t.Month())
+	if t.Before(start) {
+		prev := time.Date(t.Year(), t.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+		start = periodStartIn(prev.Year(), prev.Month())
+	}
+	next := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return start, periodStartIn(next.Year(), next.Month())
+}
+
+// Contains reports whether t falls within the closed period.
+func (p ClosedPeriod) Contains(t time.Time) bool {
+	return !t.Before(p.PeriodStart) && t.Before(p.PeriodEnd)
+}
+
+// ClosedPeriodFor returns the closed period covering t, if any.
+func ClosedPeriodFor(periods []ClosedPeriod, t time.Time) (ClosedPeriod, bool) {
+	for _, p := range periods {
+		if p.Contains(t) {
+			return p, true
+		}
+	}
+	return ClosedPeriod{}, false
+}
+
+// RecurringOccurrenceDates lists the dates a recurring rule generates when
+// regenerated from its start.
+func RecurringOccurrenceDates(rec RecurringExpense) []time.Time {
+	generated := generateExpensesFromRecurring(rec.UserID, rec, false)
+	dates := make([]time.Time, len(generated))
+	for i, exp := range generated {
+		dates[i] = exp.Date
+	}
+	return dates
+}
+
+func (s *databaseStore) GetClosedPeriods(userID string) ([]ClosedPeriod, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, period_start, period_end, closed_at
+        FROM closed_periods
+        WHERE user_id = $1
+        ORDER BY period_start DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query closed periods: %v", err)
+	}
+	defer rows.Close()
+
+	var periods []ClosedPeriod
+	for rows.Next() {
+		var p ClosedPeriod
+		if err := rows.Scan(&p.ID, &p.UserID, &p.PeriodStart, &p.PeriodEnd, &p.ClosedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan closed period: %v", err)
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// ClosePeriod closes the period containing date. Only periods that have
+// already ended can be closed.
+func (s *databaseStore) ClosePeriod(userID string, date time.Time) (ClosedPeriod, error) {
+	if userID == "" {
+		return ClosedPeriod{}, errors.New("userID is required")
+	}
+	startDay, err := s.GetStartDate(userID)
+	if err != nil {
+		return ClosedPeriod{}, err
+	}
+	start, end := PeriodBounds(date, startDay)
+	if end.After(time.Now()) {
+		return ClosedPeriod{}, fmt.Errorf("period %s to %s has not ended yet", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	var overlapping int
+	if err := s.db.QueryRow(`
+        SELECT COUNT(*) FROM closed_periods
+        WHERE user_id = $1 AND period_start < $3 AND period_end > $2
+    `, userID, start, end).Scan(&overlapping); err != nil {
+		return ClosedPeriod{}, fmt.Errorf("failed to check closed periods: %v", err)
+	}
+	if overlapping > 0 {
+		return ClosedPeriod{}, fmt.Errorf("period %s to %s is already closed", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	period := ClosedPeriod{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		ClosedAt:    time.Now(),
+	}
+	_, err = s.db.Exec(`
+        INSERT INTO closed_periods (id, user_id, period_start, period_end, closed_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, period.ID, userID, period.PeriodStart, period.PeriodEnd, period.ClosedAt)
+	if err != nil {
+		return ClosedPeriod{}, fmt.Errorf("failed to close period: %v", err)
+	}
+	return period, nil
+}
+
+func (s *databaseStore) ReopenPeriod(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM closed_periods WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to reopen period: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("closed period with ID %s not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Expense statuses, from entered by hand to matched against a bank statement.
+const (
+	ExpenseStatusUncleared  = "uncleared"
+	ExpenseStatusCleared    = "cleared"
+	ExpenseStatusReconciled = "reconciled"
+)
+
+const (
+	ReconciliationOpen      = "open"
+	ReconciliationCompleted = "completed"
+)
+
+// Reconciliation is a session matching an account's expenses against a bank
+// statement. Once completed, its expenses are locked.
+type Reconciliation struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"userId"`
+	Account         string     `json:"account"`
+	StatementDate   time.Time  `json:"statementDate"`
+	StartingBalance float64    `json:"startingBalance"`
+	EndingBalance   float64    `json:"endingBalance"`
+	Status          string     `json:"status"`
+	ExpenseIDs      []string   `json:"expenseIds"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+}
+
+// ReconciliationSummary adds the running totals of the ticked expenses.
+type ReconciliationSummary struct {
+	Reconciliation
+	ClearedTotal   float64 `json:"clearedTotal"`
+	ClearedBalance float64 `json:"clearedBalance"`
+	Difference     float64 `json:"difference"` // ending balance minus cleared balance
+	Balanced       bool    `json:"balanced"`
+}
+
+func (e *Expense) validateStatus() error {
+	e.Account = SanitizeString(e.Account)
+	switch strings.ToLower(strings.TrimSpace(e.Status)) {
+	case "":
+		e.Status = ExpenseStatusUncleared
+	case ExpenseStatusUncleared, ExpenseStatusCleared, ExpenseStatusReconciled:
+		e.Status = strings.ToLower(strings.TrimSpace(e.Status))
+	default:
+		return fmt.Errorf("invalid status: '%s'. Must be one of 'uncleared', 'cleared' or 'reconciled'", e.Status)
+	}
+	return nil
+}
+
+func (r *Reconciliation) Validate() error {
+	r.Account = SanitizeString(r.Account)
+	if r.Account == "" {
+		return fmt.Errorf("reconciliation 'account' cannot be empty")
+	}
+	if r.StatementDate.IsZero() {
+		return fmt.Errorf("reconciliation 'statementDate' cannot be empty")
+	}
+	return nil
+}
+
+// Summarize computes the cleared balance from the ticked expenses.
+func (r Reconciliation) Summarize(ticked []Expense) ReconciliationSummary {
+	summary := ReconciliationSummary{Reconciliation: r}
+	for _, expense := range ticked {
+		summary.ClearedTotal += expense.Amount
+	}
+	summary.ClearedTotal = roundCents(summary.ClearedTotal)
+	summary.ClearedBalance = roundCents(r.StartingBalance + summary.ClearedTotal)
+	summary.Difference = roundCents(r.EndingBalance - summary.ClearedBalance)
+	summary.Balanced = summary.Difference == 0
+	return summary
+}
+
+func (s *databaseStore) GetReconciliations(userID string) ([]Reconciliation, error) {
+	rows, err := s.db.Query(`
+        SELECT r.id, r.user_id, r.account, r.statement_date, r.starting_balance, r.ending_balance, r.status, r.created_at, r.completed_at,
+               COALESCE(array_agg(i.expense_id::text) FILTER (WHERE i.expense_id IS NOT NULL), '{}')
+        FROM reconciliations r
+        LEFT JOIN reconciliation_items i ON i.reconciliation_id = r.id
+        WHERE r.user_id = $1
+        GROUP BY r.id
+        ORDER BY r.statement_date DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliations: %v", err)
+	}
+	defer rows.Close()
+
+	var results []Reconciliation
+	for rows.Next() {
+		rec, err := scanReconciliation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation: %v", err)
+		}
+		results = append(results, rec)
+	}
+	return results, rows.Err()
+}
+
+func (s *databaseStore) GetReconciliation(userID, id string) (Reconciliation, error) {
+	rec, err := scanReconciliation(s.db.QueryRow(`
+        SELECT r.id, r.user_id, r.account, r.statement_date, r.starting_balance, r.ending_balance, r.status, r.created_at, r.completed_at,
+               COALESCE(array_agg(i.expense_id::text) FILTER (WHERE i.expense_id IS NOT NULL), '{}')
+        FROM reconciliations r
+        LEFT JOIN reconciliation_items i ON i.reconciliation_id = r.id
+        WHERE r.user_id = $1 AND r.id = $2
+        GROUP BY r.id
+    `, userID, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Reconciliation{}, fmt.Errorf("reconciliation with ID %s not found", id)
+		}
+		return Reconciliation{}, fmt.Errorf("failed to get reconciliation: %v", err)
+	}
+	return rec, nil
+}
+
+func scanReconciliation(scanner interface{ Scan(...any) error }) (Reconciliation, error) {
+	var rec Reconciliation
+	var completedAt sql.NullTime
+	var ids pq.StringArray
+	if err := scanner.Scan(&rec.ID, &rec.UserID, &rec.Account, &rec.StatementDate, &rec.StartingBalance, &rec.EndingBalance, &rec.Status, &rec.CreatedAt, &completedAt, &ids); err != nil {
+		return Reconciliation{}, err
+	}
+	if completedAt.Valid {
+		rec.CompletedAt = &completedAt.Time
+	}
+	rec.ExpenseIDs = []string(ids)
+	return rec, nil
+}
+
+// LastReconciledBalance returns the ending balance of the latest completed
+// reconciliation of an account, which is where the next statement starts.
+func (s *databaseStore) LastReconciledBalance(userID, account string) (float64, error) {
+	var balance float64
+	err := s.db.QueryRow(`
+        SELECT ending_balance FROM reconciliations
+        WHERE user_id = $1 AND account = $2 AND status = $3
+        ORDER BY statement_date DESC
+        LIMIT 1
+    `, userID, account, ReconciliationCompleted).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last reconciled balance: %v", err)
+	}
+	return balance, nil
+}
+
+func (s *databaseStore) AddReconciliation(userID string, rec Reconciliation) (Reconciliation, error) {
+	if userID == "" {
+		return Reconciliation{}, errors.New("userID is required")
+	}
+	var open int
+	if err := s.db.QueryRow(`
+        SELECT COUNT(*) FROM reconciliations WHERE user_id = $1 AND account = $2 AND status = $3
+    `, userID, rec.Account, ReconciliationOpen).Scan(&open); err != nil {
+		return Reconciliation{}, fmt.Errorf("failed to check open reconciliations: %v", err)
+	}
+	if open > 0 {
+		return Reconciliation{}, fmt.Errorf("account '%s' already has an open reconciliation", rec.Account)
+	}
+	rec.ID = uuid.New().String()
+	rec.UserID = userID
+	rec.Status = ReconciliationOpen
+	rec.ExpenseIDs = []string{}
+	rec.CreatedAt = time.Now()
+	rec.CompletedAt = nil
+	_, err := s.db.Exec(`
+        INSERT INTO reconciliations (id, user_id, account, statement_date, starting_balance, ending_balance, status, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, rec.ID, userID, rec.Account, rec.StatementDate, rec.StartingBalance, rec.EndingBalance, rec.Status, rec.CreatedAt)
+	if err != nil {
+		return Reconciliation{}, fmt.Errorf("failed to insert reconciliation: %v", err)
+	}
+	return rec, nil
+}
+
+// RemoveReconciliation discards an open session; completed ones are permanent.
+func (s *databaseStore) RemoveReconciliation(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM reconciliations WHERE user_id = $1 AND id = $2 AND status = $3`, userID, id, ReconciliationOpen)
+	if err != nil {
+		return fmt.Errorf("failed to delete reconciliation: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("open reconciliation with ID %s not found", id)
+	}
+	return nil
+}
+
+func (s *databaseStore) AddReconciliationItem(userID, reconciliationID, expenseID string) error {
+	var owner string
+	err := s.db.QueryRow(`
+        SELECT reconciliation_id FROM reconciliation_items i
+        JOIN reconciliations r ON r.id = i.reconciliation_id
+        WHERE r.user_id = $1 AND i.expense_id = $2
+    `, userID, expenseID).Scan(&owner)
+	if err == nil {
+		if owner == reconciliationID {
+			return nil
+		}
+		return fmt.Errorf("expense %s is already part of another reconciliation", expenseID)
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check reconciliation items: %v", err)
+	}
+	_, err = s.db.Exec(`
+        INSERT INTO reconciliation_items (reconciliation_id, expense_id)
+        SELECT id, $3 FROM reconciliations WHERE user_id = $1 AND id = $2 AND status = $4
+    `, userID, reconciliationID, expenseID, ReconciliationOpen)
+	if err != nil {
+		return fmt.Errorf("failed to add reconciliation item: %v", err)
+	}
+	return nil
+}
+
+func (s *databaseStore) RemoveReconciliationItem(userID, reconciliationID, expenseID string) error {
+	res, err := s.db.Exec(`
+        DELETE FROM reconciliation_items i
+        USING reconciliations r
+        WHERE r.id = i.reconciliation_id AND r.user_id = $1 AND r.id = $2 AND r.status = $3 AND i.expense_id = $4
+    `, userID, reconciliationID, ReconciliationOpen, expenseID)
+	if err != nil {
+		return fmt.Errorf("failed to remove reconciliation item: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("expense %s is not part of open reconciliation %s", expenseID, reconciliationID)
+	}
+	return nil
+}
+
+// CompleteReconciliation stores the ticked expenses, already marked as
+// reconciled, and completes the session in one transaction.
+func (s *databaseStore) CompleteReconciliation(userID, id string, reconciled []Expense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        UPDATE reconciliations SET status = $1, completed_at = $2
+        WHERE user_id = $3 AND id = $4 AND status = $5
+    `, ReconciliationCompleted, time.Now(), userID, id, ReconciliationOpen)
+	if err != nil {
+		return fmt.Errorf("failed to complete reconciliation: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read update result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("open reconciliation with ID %s not found", id)
+	}
+
+	for _, expense := range reconciled {
+		if expense.Blob == "" {
+			raw, err := json.Marshal(expense)
+			if err != nil {
+				return fmt.Errorf("failed to serialize expense: %v", err)
+			}
+			expense.Blob = string(raw)
+		}
+		res, err := tx.Exec(`
+            UPDATE expenses e SET blob = $1
+            FROM reconciliation_items i
+            WHERE i.expense_id = e.id AND i.reconciliation_id = $2 AND e.id = $3 AND e.user_id = $4
+        `, expense.Blob, id, expense.ID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to mark expense as reconciled: %v", err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to read update result: %v", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("expense %s is not part of reconciliation %s", expense.ID, id)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetLockedExpenseIDs returns which of the given expenses belong to a
+// completed reconciliation and therefore must not change.
+func (s *databaseStore) GetLockedExpenseIDs(userID string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(`
+        SELECT i.expense_id FROM reconciliation_items i
+        JOIN reconciliations r ON r.id = i.reconciliation_id
+        WHERE r.user_id = $1 AND r.status = $2 AND i.expense_id::text = ANY($3)
+    `, userID, ReconciliationCompleted, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query locked expenses: %v", err)
+	}
+	defer rows.Close()
+
+	var locked []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan locked expense: %v", err)
+		}
+		locked = append(locked, id)
+	}
+	return locked, rows.Err()
+}
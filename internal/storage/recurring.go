@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/expenseowl/internal/encryption"
+)
+
+// ProjectRecurring returns the occurrences a rule produces within [from, to),
+// computed from the rule itself rather than from materialised expenses, so
+// open-ended rules are projected beyond their generated horizon.
+func ProjectRecurring(rec RecurringExpense, from, to time.Time) []Expense {
+	var payments []float64
+	if rec.IsInstallment() {
+		payments = rec.InstallmentAmounts()
+	}
+	var projected []Expense
+	date := rec.StartDate
+	for n := 0; date.Before(to); n++ {
+		if rec.Occurrences > 0 && n >= rec.Occurrences {
+			break
+		}
+		if !date.Before(from) {
+			exp := Expense{
+				UserID:      rec.UserID,
+				RecurringID: rec.ID,
+				Name:        rec.Name,
+				Category:    rec.Category,
+				Amount:      rec.Amount,
+				Currency:    rec.Currency,
+				Date:        date,
+				Tags:        rec.Tags,
+			}
+			if payments != nil {
+				exp.Amount = payments[n]
+				exp.Installment = &InstallmentRef{Number: n + 1, Of: rec.Occurrences}
+			}
+			projected = append(projected, exp)
+		}
+		next, ok := advanceDate(date, rec.Interval)
+		if !ok {
+			break
+		}
+		date = next
+	}
+	return projected
+}
+
+// AdoptRecurringExpense creates a rule for expenses that already exist, such
+// as a subscription found in imported history. The linked expenses are
+// pointed at the rule and only occurrences after the last of them are
+// generated, so the history isn't duplicated.
+func (s *databaseStore) AdoptRecurringExpense(userID string, rec RecurringExpense, linked []Expense, enc *encryption.Manager) error {
+	if userID == "" {
+		return errors.New("userID is required")
+	}
+	if len(linked) == 0 {
+		return errors.New("at least one expense must be linked")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if rec.ID == "" {
+		rec.ID = uuid.New().String()
+	}
+	if err := s.insertRecurringExpense(tx, userID, &rec); err != nil {
+		return err
+	}
+
+	var last time.Time
+	for _, expense := range linked {
+		expense.RecurringID = rec.ID
+		if expense.Blob == "" {
+			raw, err := json.Marshal(expense)
+			if err != nil {
+				return fmt.Errorf("failed to serialize expense: %v", err)
+			}
+			expense.Blob = string(raw)
+		}
+		res, err := tx.Exec(`
+            UPDATE expenses SET blob = $1, recurring_id = $2
+            WHERE id = $3 AND user_id = $4
+        `, expense.Blob, rec.ID, expense.ID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to link expense: %v", err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to read update result: %v", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("expense with ID %s not found", expense.ID)
+		}
+		if expense.Date.After(last) {
+			last = expense.Date
+		}
+	}
+
+	future := rec
+	if rec.Occurrences > 0 {
+		future.Occurrences = rec.Occurrences - len(linked)
+		if future.Occurrences <= 0 {
+			return tx.Commit()
+		}
+	}
+	next, ok := advanceDate(last, rec.Interval)
+	if !ok {
+		return fmt.Errorf("invalid interval %q", rec.Interval)
+	}
+	future.StartDate = next
+	if err := bulkInsertExpenses(tx, generateExpensesFromRecurring(userID, future, false), enc); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetRecurringExpenseIDs lists the expenses generated by or linked to a
+// recurring expense, which updateAll and removeAll delete.
+func (s *databaseStore) GetRecurringExpenseIDs(userID, recurringID string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM expenses WHERE user_id = $1 AND recurring_id = $2`, userID, recurringID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring expense occurrences: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recurring expense occurrence: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
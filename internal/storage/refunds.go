@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefundLink ties a refunding transaction (a positive expense) to the expense
+// it pays back. Amount is the part of the refund applied to that expense; a
+// zero amount on creation applies as much as both sides still allow.
+type RefundLink struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	ExpenseID string    `json:"expenseId"`
+	RefundID  string    `json:"refundId"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (l *RefundLink) Validate() error {
+	if l.ExpenseID == "" || l.RefundID == "" {
+		return fmt.Errorf("refund link requires 'expenseId' and 'refundId'")
+	}
+	if l.ExpenseID == l.RefundID {
+		return fmt.Errorf("an expense cannot refund itself")
+	}
+	if l.Amount < 0 {
+		return fmt.Errorf("refund link 'amount' cannot be negative")
+	}
+	return nil
+}
+
+func (s *databaseStore) GetRefundLinks(userID string) ([]RefundLink, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, expense_id, refund_id, amount, created_at
+        FROM expense_refunds
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refund links: %v", err)
+	}
+	defer rows.Close()
+
+	var links []RefundLink
+	for rows.Next() {
+		var l RefundLink
+		if err := rows.Scan(&l.ID, &l.UserID, &l.ExpenseID, &l.RefundID, &l.Amount, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan refund link: %v", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+func (s *databaseStore) AddRefundLink(userID string, link RefundLink) (RefundLink, error) {
+	if userID == "" {
+		return RefundLink{}, errors.New("userID is required")
+	}
+	if link.ID == "" {
+		link.ID = uuid.New().String()
+	}
+	link.UserID = userID
+	link.CreatedAt = time.Now()
+	_, err := s.db.Exec(`
+        INSERT INTO expense_refunds (id, user_id, expense_id, refund_id, amount, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, link.ID, userID, link.ExpenseID, link.RefundID, link.Amount, link.CreatedAt)
+	if err != nil {
+		return RefundLink{}, fmt.Errorf("failed to insert refund link: %v", err)
+	}
+	return link, nil
+}
+
+func (s *databaseStore) RemoveRefundLink(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM expense_refunds WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete refund link: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("refund link with ID %s not found", id)
+	}
+	return nil
+}
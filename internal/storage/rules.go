@@ -0,0 +1,425 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Expense sources, matched by rules with the "source" field.
+const (
+	ExpenseSourceManual = "manual" // entered in the UI
+	ExpenseSourceAPI    = "api"    // ingested through the external API (Telegram/n8n)
+	ExpenseSourceImport = "import" // imported from a file
+)
+
+// Rule condition fields and operators.
+const (
+	RuleFieldName     = "name"
+	RuleFieldCategory = "category"
+	RuleFieldTags     = "tags"
+	RuleFieldSource   = "source"
+	RuleFieldAmount   = "amount"
+
+	RuleOpContains = "contains"
+	RuleOpEquals   = "equals"
+	RuleOpRegex    = "regex"
+	RuleOpRange    = "range"
+)
+
+// CategorizationRule rewrites matching expenses as they are added. All
+// conditions must match. Rules run in Position order and each one sees the
+// changes of the rules before it; Stop ends processing after a match.
+type CategorizationRule struct {
+	ID         string          `json:"id"`
+	UserID     string          `json:"userId"`
+	Name       string          `json:"name"`
+	Position   int             `json:"position"`
+	Enabled    bool            `json:"enabled"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    RuleActions     `json:"actions"`
+	Stop       bool            `json:"stop"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// RuleCondition tests one field. Text comparisons ignore case; range
+// compares the absolute amount against Min and Max, either of which may be
+// left out.
+type RuleCondition struct {
+	Field string   `json:"field"`
+	Op    string   `json:"op"`
+	Value string   `json:"value,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+
+	re *regexp.Regexp // compiled Value of regex conditions, set by validate and scan
+}
+
+// RuleActions are applied to a matching expense. Empty values are left alone.
+// Tags replaces the expense's tags, an empty list clears them; AddTags is
+// applied after it.
+type RuleActions struct {
+	Category string    `json:"category,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"`
+	AddTags  []string  `json:"addTags,omitempty"`
+}
+
+var ruleFieldOps = map[string][]string{
+	RuleFieldName:     {RuleOpContains, RuleOpEquals, RuleOpRegex},
+	RuleFieldCategory: {RuleOpContains, RuleOpEquals, RuleOpRegex},
+	RuleFieldTags:     {RuleOpContains, RuleOpEquals, RuleOpRegex},
+	RuleFieldSource:   {RuleOpEquals},
+	RuleFieldAmount:   {RuleOpRange},
+}
+
+func (r *CategorizationRule) Validate() error {
+	r.Name = SanitizeString(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("rule 'name' cannot be empty")
+	}
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("rule needs at least one condition")
+	}
+	for i := range r.Conditions {
+		if err := r.Conditions[i].validate(); err != nil {
+			return fmt.Errorf("condition %d: %v", i+1, err)
+		}
+	}
+	r.Actions.Category = SanitizeString(r.Actions.Category)
+	r.Actions.Name = SanitizeString(r.Actions.Name)
+	r.Actions.AddTags = sanitizeTags(r.Actions.AddTags)
+	if r.Actions.Tags != nil {
+		tags := sanitizeTags(*r.Actions.Tags)
+		if tags == nil {
+			tags = []string{}
+		}
+		r.Actions.Tags = &tags
+	}
+	if r.Actions.Category == "" && r.Actions.Name == "" && r.Actions.Tags == nil && len(r.Actions.AddTags) == 0 {
+		return fmt.Errorf("rule needs at least one action")
+	}
+	return nil
+}
+
+func (c *RuleCondition) validate() error {
+	c.Field = strings.ToLower(strings.TrimSpace(c.Field))
+	c.Op = strings.ToLower(strings.TrimSpace(c.Op))
+	ops, ok := ruleFieldOps[c.Field]
+	if !ok {
+		return fmt.Errorf("invalid field: '%s'", c.Field)
+	}
+	valid := false
+	for _, op := range ops {
+		valid = valid || op == c.Op
+	}
+	if !valid {
+		return fmt.Errorf("operator '%s' is not supported for '%s'. Must be one of: %s", c.Op, c.Field, strings.Join(ops, ", "))
+	}
+	switch c.Op {
+	case RuleOpRange:
+		if c.Min == nil && c.Max == nil {
+			return fmt.Errorf("range needs 'min' or 'max'")
+		}
+		if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+			return fmt.Errorf("range 'min' cannot be greater than 'max'")
+		}
+	case RuleOpRegex:
+		re, err := compileRuleRegex(c.Value)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %v", err)
+		}
+		c.re = re
+	default:
+		if strings.TrimSpace(c.Value) == "" {
+			return fmt.Errorf("'value' cannot be empty")
+		}
+	}
+	return nil
+}
+
+// Matches reports whether all conditions hold for the expense.
+func (r CategorizationRule) Matches(expense Expense) bool {
+	for _, c := range r.Conditions {
+		if !c.matches(expense) {
+			return false
+		}
+	}
+	return len(r.Conditions) > 0
+}
+
+func (c RuleCondition) matches(expense Expense) bool {
+	switch c.Field {
+	case RuleFieldAmount:
+		amount := math.Abs(expense.Amount)
+		return (c.Min == nil || amount >= *c.Min) && (c.Max == nil || amount <= *c.Max)
+	case RuleFieldTags:
+		for _, tag := range expense.Tags {
+			if c.matchText(tag) {
+				return true
+			}
+		}
+		return false
+	case RuleFieldName:
+		return c.matchText(expense.Name)
+	case RuleFieldCategory:
+		return c.matchText(expense.Category)
+	case RuleFieldSource:
+		return c.matchText(expense.Source)
+	}
+	return false
+}
+
+func (c RuleCondition) matchText(text string) bool {
+	switch c.Op {
+	case RuleOpContains:
+		return strings.Contains(strings.ToLower(text), strings.ToLower(c.Value))
+	case RuleOpEquals:
+		return strings.EqualFold(strings.TrimSpace(text), strings.TrimSpace(c.Value))
+	case RuleOpRegex:
+		re := c.re
+		if re == nil {
+			var err error
+			if re, err = compileRuleRegex(c.Value); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(text)
+	}
+	return false
+}
+
+func compileRuleRegex(value string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + value)
+}
+
+// apply changes the expense and reports whether anything changed.
+func (a RuleActions) apply(expense *Expense) bool {
+	changed := false
+	if a.Category != "" && a.Category != expense.Category {
+		expense.Category = a.Category
+		changed = true
+	}
+	if a.Name != "" && a.Name != expense.Name {
+		expense.Name = a.Name
+		changed = true
+	}
+	if a.Tags != nil && !slices.Equal(expense.Tags, *a.Tags) {
+		expense.Tags = append([]string{}, *a.Tags...)
+		changed = true
+	}
+	for _, tag := range a.AddTags {
+		present := false
+		for _, existing := range expense.Tags {
+			present = present || strings.EqualFold(existing, tag)
+		}
+		if !present {
+			expense.Tags = append(expense.Tags, tag)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ApplyRules runs the enabled rules in order against the expense and returns
+// the IDs of the rules that matched.
+func ApplyRules(rules []CategorizationRule, expense *Expense) []string {
+	var matched []string
+	for _, rule := range rules {
+		if !rule.Enabled || !rule.Matches(*expense) {
+			continue
+		}
+		rule.Actions.apply(expense)
+		matched = append(matched, rule.ID)
+		if rule.Stop {
+			break
+		}
+	}
+	return matched
+}
+
+// ------------------------------------------------------------
+// PostgreSQL implementation
+// ------------------------------------------------------------
+
+func (s *databaseStore) GetCategorizationRules(userID string) ([]CategorizationRule, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, name, position, enabled, conditions, actions, stop, created_at
+        FROM categorization_rules
+        WHERE user_id = $1
+        ORDER BY position, created_at
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categorization rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []CategorizationRule
+	for rows.Next() {
+		rule, err := scanCategorizationRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *databaseStore) GetCategorizationRule(userID, id string) (CategorizationRule, error) {
+	rule, err := scanCategorizationRule(s.db.QueryRow(`
+        SELECT id, user_id, name, position, enabled, conditions, actions, stop, created_at
+        FROM categorization_rules
+        WHERE user_id = $1 AND id = $2
+    `, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return CategorizationRule{}, fmt.Errorf("rule with ID %s not found", id)
+	}
+	return rule, err
+}
+
+// AddCategorizationRule appends the rule after the user's existing rules.
+func (s *databaseStore) AddCategorizationRule(userID string, rule CategorizationRule) (CategorizationRule, error) {
+	if userID == "" {
+		return CategorizationRule{}, errors.New("userID is required")
+	}
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+	rule.UserID = userID
+	rule.CreatedAt = time.Now()
+	conditions, actions, err := marshalRuleParts(rule)
+	if err != nil {
+		return CategorizationRule{}, err
+	}
+	err = s.db.QueryRow(`
+        INSERT INTO categorization_rules (id, user_id, name, position, enabled, conditions, actions, stop, created_at)
+        VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM categorization_rules WHERE user_id = $2), $4, $5, $6, $7, $8)
+        RETURNING position
+    `, rule.ID, userID, rule.Name, rule.Enabled, conditions, actions, rule.Stop, rule.CreatedAt).Scan(&rule.Position)
+	if err != nil {
+		return CategorizationRule{}, fmt.Errorf("failed to insert categorization rule: %v", err)
+	}
+	return rule, nil
+}
+
+func (s *databaseStore) UpdateCategorizationRule(userID, id string, rule CategorizationRule) error {
+	conditions, actions, err := marshalRuleParts(rule)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`
+        UPDATE categorization_rules
+        SET name = $1, enabled = $2, conditions = $3, actions = $4, stop = $5
+        WHERE id = $6 AND user_id = $7
+    `, rule.Name, rule.Enabled, conditions, actions, rule.Stop, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update categorization rule: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read update result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("rule with ID %s not found", id)
+	}
+	return nil
+}
+
+func (s *databaseStore) RemoveCategorizationRule(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM categorization_rules WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete categorization rule: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read delete result: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("rule with ID %s not found", id)
+	}
+	return nil
+}
+
+// ReorderCategorizationRules sets the rule order. ids must list every rule
+// of the user exactly once.
+func (s *databaseStore) ReorderCategorizationRules(userID string, ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM categorization_rules WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count categorization rules: %v", err)
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	if len(ids) != count || len(seen) != count {
+		return fmt.Errorf("rule order must list each of the %d rules once", count)
+	}
+	for i, id := range ids {
+		res, err := tx.Exec(`UPDATE categorization_rules SET position = $1 WHERE user_id = $2 AND id = $3`, i+1, userID, id)
+		if err != nil {
+			return fmt.Errorf("failed to reorder categorization rules: %v", err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to read update result: %v", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("rule with ID %s not found", id)
+		}
+	}
+	return tx.Commit()
+}
+
+func marshalRuleParts(rule CategorizationRule) ([]byte, []byte, error) {
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize rule conditions: %v", err)
+	}
+	actions, err := json.Marshal(rule.Actions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize rule actions: %v", err)
+	}
+	return conditions, actions, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCategorizationRule(row rowScanner) (CategorizationRule, error) {
+	var rule CategorizationRule
+	var conditions, actions []byte
+	if err := row.Scan(&rule.ID, &rule.UserID, &rule.Name, &rule.Position, &rule.Enabled, &conditions, &actions, &rule.Stop, &rule.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rule, err
+		}
+		return rule, fmt.Errorf("failed to scan categorization rule: %v", err)
+	}
+	if err := json.Unmarshal(conditions, &rule.Conditions); err != nil {
+		return rule, fmt.Errorf("failed to parse rule conditions: %v", err)
+	}
+	for i, c := range rule.Conditions {
+		if c.Op == RuleOpRegex {
+			// stored rules were validated, a pattern that fails now never matches
+			rule.Conditions[i].re, _ = compileRuleRegex(c.Value)
+		}
+	}
+	if err := json.Unmarshal(actions, &rule.Actions); err != nil {
+		return rule, fmt.Errorf("failed to parse rule actions: %v", err)
+	}
+	return rule, nil
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestRuleConditionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition RuleCondition
+		wantErr   bool
+	}{
+		{"contains", RuleCondition{Field: " Name ", Op: "CONTAINS", Value: "coffee"}, false},
+		{"regex", RuleCondition{Field: "name", Op: "regex", Value: `^uber\s+eats`}, false},
+		{"range with min", RuleCondition{Field: "amount", Op: "range", Min: floatPtr(10)}, false},
+		{"source equals", RuleCondition{Field: "source", Op: "equals", Value: ExpenseSourceImport}, false},
+		{"unknown field", RuleCondition{Field: "date", Op: "equals", Value: "x"}, true},
+		{"unsupported operator", RuleCondition{Field: "source", Op: "contains", Value: "api"}, true},
+		{"empty value", RuleCondition{Field: "name", Op: "equals", Value: "  "}, true},
+		{"invalid regex", RuleCondition{Field: "name", Op: "regex", Value: "("}, true},
+		{"open range", RuleCondition{Field: "amount", Op: "range"}, true},
+		{"inverted range", RuleCondition{Field: "amount", Op: "range", Min: floatPtr(10), Max: floatPtr(5)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.condition.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.condition.Op == RuleOpRegex && tt.condition.re == nil {
+				t.Error("validate() should keep the compiled regex")
+			}
+		})
+	}
+}
+
+func TestCategorizationRuleValidate(t *testing.T) {
+	rule := CategorizationRule{
+		Name:       " Coffee ",
+		Conditions: []RuleCondition{{Field: "name", Op: "contains", Value: "coffee"}},
+		Actions:    RuleActions{AddTags: []string{" ", "drinks"}},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if rule.Name != "Coffee" || !reflect.DeepEqual(rule.Actions.AddTags, []string{"drinks"}) {
+		t.Errorf("rule = %+v", rule)
+	}
+
+	tests := []struct {
+		name string
+		rule CategorizationRule
+	}{
+		{"no name", CategorizationRule{Conditions: rule.Conditions, Actions: rule.Actions}},
+		{"no conditions", CategorizationRule{Name: "x", Actions: rule.Actions}},
+		{"no actions", CategorizationRule{Name: "x", Conditions: rule.Conditions, Actions: RuleActions{AddTags: []string{" "}}}},
+		{"bad condition", CategorizationRule{Name: "x", Conditions: []RuleCondition{{Field: "name", Op: "range"}}, Actions: rule.Actions}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	expense := Expense{
+		Name:     "Starbucks Coffee #42",
+		Category: "Food",
+		Tags:     []string{"Work", "Morning"},
+		Source:   ExpenseSourceImport,
+		Amount:   -4.5,
+	}
+	tests := []struct {
+		name       string
+		conditions []RuleCondition
+		want       bool
+	}{
+		{"contains ignores case", []RuleCondition{{Field: "name", Op: "contains", Value: "COFFEE"}}, true},
+		{"contains misses", []RuleCondition{{Field: "name", Op: "contains", Value: "tea"}}, false},
+		{"equals ignores case and spaces", []RuleCondition{{Field: "category", Op: "equals", Value: " food "}}, true},
+		{"equals needs the whole value", []RuleCondition{{Field: "category", Op: "equals", Value: "foo"}}, false},
+		{"regex", []RuleCondition{{Field: "name", Op: "regex", Value: `^starbucks.*#\d+$`}}, true},
+		{"regex misses", []RuleCondition{{Field: "name", Op: "regex", Value: `^coffee`}}, false},
+		{"any tag", []RuleCondition{{Field: "tags", Op: "equals", Value: "morning"}}, true},
+		{"no tag", []RuleCondition{{Field: "tags", Op: "equals", Value: "evening"}}, false},
+		{"source", []RuleCondition{{Field: "source", Op: "equals", Value: "import"}}, true},
+		{"range uses the absolute amount", []RuleCondition{{Field: "amount", Op: "range", Min: floatPtr(4), Max: floatPtr(5)}}, true},
+		{"range min only", []RuleCondition{{Field: "amount", Op: "range", Min: floatPtr(5)}}, false},
+		{"range max only", []RuleCondition{{Field: "amount", Op: "range", Max: floatPtr(4.5)}}, true},
+		{"all conditions must hold", []RuleCondition{
+			{Field: "name", Op: "contains", Value: "coffee"},
+			{Field: "source", Op: "equals", Value: "manual"},
+		}, false},
+		{"no conditions", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := CategorizationRule{Conditions: tt.conditions}
+			for i := range rule.Conditions {
+				if err := rule.Conditions[i].validate(); err != nil {
+					t.Fatalf("validate: %v", err)
+				}
+			}
+			if got := rule.Matches(expense); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatchesWithoutCompiledRegex(t *testing.T) {
+	rule := CategorizationRule{Conditions: []RuleCondition{{Field: "name", Op: "regex", Value: "^rent"}}}
+	if !rule.Matches(Expense{Name: "RENT March"}) {
+		t.Error("a rule built without validate should still match")
+	}
+	rule.Conditions[0].Value = "("
+	if rule.Matches(Expense{Name: "("}) {
+		t.Error("an invalid regex should never match")
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	rules := []CategorizationRule{
+		{ID: "disabled", Enabled: false, Conditions: []RuleCondition{{Field: "name", Op: "contains", Value: "uber"}}, Actions: RuleActions{Category: "Ignored"}},
+		{ID: "rename", Enabled: true, Conditions: []RuleCondition{{Field: "name", Op: "contains", Value: "uber"}}, Actions: RuleActions{Name: "Uber Eats", AddTags: []string{"delivery"}}},
+		{ID: "retag", Enabled: true, Conditions: []RuleCondition{{Field: "tags", Op: "equals", Value: "card"}}, Actions: RuleActions{Tags: &[]string{"food", "delivery"}}},
+		// sees the name set by the rule before it
+		{ID: "categorize", Enabled: true, Stop: true, Conditions: []RuleCondition{{Field: "name", Op: "equals", Value: "uber eats"}}, Actions: RuleActions{Category: "Food", AddTags: []string{"Delivery"}}},
+		{ID: "after-stop", Enabled: true, Conditions: []RuleCondition{{Field: "category", Op: "equals", Value: "food"}}, Actions: RuleActions{Category: "Groceries"}},
+	}
+	expense := Expense{Name: "UBER *EATS 1234", Category: "Other", Tags: []string{"card"}}
+	matched := ApplyRules(rules, &expense)
+
+	if want := []string{"rename", "retag", "categorize"}; !reflect.DeepEqual(matched, want) {
+		t.Errorf("matched = %v, want %v", matched, want)
+	}
+	want := Expense{Name: "Uber Eats", Category: "Food", Tags: []string{"food", "delivery"}}
+	if !reflect.DeepEqual(expense, want) {
+		t.Errorf("expense = %+v, want %+v", expense, want)
+	}
+}
+
+func TestRuleActionsTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		actions     RuleActions
+		tags        []string
+		want        []string
+		wantChanged bool
+	}{
+		{"replace", RuleActions{Tags: &[]string{"travel"}}, []string{"card", "work"}, []string{"travel"}, true},
+		{"clear", RuleActions{Tags: &[]string{}}, []string{"card"}, []string{}, true},
+		{"replace then add", RuleActions{Tags: &[]string{"travel"}, AddTags: []string{"work"}}, []string{"card"}, []string{"travel", "work"}, true},
+		{"already set", RuleActions{Tags: &[]string{"card"}}, []string{"card"}, []string{"card"}, false},
+		{"left alone", RuleActions{Category: "Food"}, []string{"card"}, []string{"card"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expense := Expense{Category: "Other", Tags: tt.tags}
+			changed := tt.actions.apply(&expense)
+			if !reflect.DeepEqual(expense.Tags, tt.want) || changed != tt.wantChanged {
+				t.Errorf("apply = %v, tags %v; want %v, tags %v", changed, expense.Tags, tt.wantChanged, tt.want)
+			}
+		})
+	}
+
+	rule := CategorizationRule{
+		Name:       "Clear tags",
+		Conditions: []RuleCondition{{Field: "source", Op: "equals", Value: "api"}},
+		Actions:    RuleActions{Tags: &[]string{" "}},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("a rule that only clears tags should be valid: %v", err)
+	}
+	if rule.Actions.Tags == nil || len(*rule.Actions.Tags) != 0 {
+		t.Errorf("tags = %v, want an empty list", rule.Actions.Tags)
+	}
+}
+
+type fakeRuleRow struct {
+	values []any
+}
+
+func (r fakeRuleRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+func TestScanCategorizationRuleCompilesRegex(t *testing.T) {
+	conditions, _ := json.Marshal([]RuleCondition{
+		{Field: "name", Op: "regex", Value: "^netflix"},
+		{Field: "amount", Op: "range", Max: floatPtr(20)},
+	})
+	actions, _ := json.Marshal(RuleActions{Category: "Subscriptions"})
+	row := fakeRuleRow{values: []any{"id", "user", "Streaming", 1, true, conditions, actions, false, time.Now()}}
+
+	rule, err := scanCategorizationRule(row)
+	if err != nil {
+		t.Fatalf("scanCategorizationRule: %v", err)
+	}
+	if rule.Conditions[0].re == nil {
+		t.Fatal("regex condition should be compiled when scanned")
+	}
+	if !rule.Matches(Expense{Name: "NETFLIX.COM", Amount: -15.99}) {
+		t.Error("scanned rule should match")
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statement records an imported bank statement: which account and period it
+// covered and the balance the bank reported, for later reconciliation.
+type Statement struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"userId"`
+	Source        string     `json:"source"` // file format: ofx, camt053 or mt940
+	Account       string     `json:"account"`
+	Currency      string     `json:"currency"`
+	LedgerBalance *float64   `json:"ledgerBalance,omitempty"`
+	BalanceDate   *time.Time `json:"balanceDate,omitempty"`
+	StartDate     *time.Time `json:"startDate,omitempty"`
+	EndDate       *time.Time `json:"endDate,omitempty"`
+	Transactions  int        `json:"transactions"` // lines in the file
+	Imported      int        `json:"imported"`     // lines stored as expenses
+	ImportedAt    time.Time  `json:"importedAt"`
+}
+
+func (s *databaseStore) GetExternalRefs(userID string) (map[string]string, error) {
+	rows, err := s.db.Query(`
+        SELECT external_ref, id FROM expenses
+        WHERE user_id = $1 AND external_ref IS NOT NULL
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query external references: %v", err)
+	}
+	defer rows.Close()
+
+	refs := make(map[string]string)
+	for rows.Next() {
+		var ref, id string
+		if err := rows.Scan(&ref, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan external reference: %v", err)
+		}
+		refs[ref] = id
+	}
+	return refs, rows.Err()
+}
+
+func (s *databaseStore) GetStatements(userID string) ([]Statement, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, source, account, currency, ledger_balance, balance_date, start_date, end_date, transactions, imported, imported_at
+        FROM statements
+        WHERE user_id = $1
+        ORDER BY imported_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query statements: %v", err)
+	}
+	defer rows.Close()
+
+	var statements []Statement
+	for rows.Next() {
+		var st Statement
+		var balance sql.NullFloat64
+		var balanceDate, startDate, endDate sql.NullTime
+		if err := rows.Scan(&st.ID, &st.UserID, &st.Source, &st.Account, &st.Currency, &balance, &balanceDate, &startDate, &endDate, &st.Transactions, &st.Imported, &st.ImportedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan statement: %v", err)
+		}
+		if balance.Valid {
+			st.LedgerBalance = &balance.Float64
+		}
+		st.BalanceDate = nullTimePtr(balanceDate)
+		st.StartDate = nullTimePtr(startDate)
+		st.EndDate = nullTimePtr(endDate)
+		statements = append(statements, st)
+	}
+	return statements, rows.Err()
+}
+
+func (s *databaseStore) AddStatement(userID string, statement Statement) (Statement, error) {
+	if userID == "" {
+		return Statement{}, errors.New("userID is required")
+	}
+	if statement.ID == "" {
+		statement.ID = uuid.New().String()
+	}
+	statement.UserID = userID
+	statement.ImportedAt = time.Now()
+	_, err := s.db.Exec(`
+        INSERT INTO statements (id, user_id, source, account, currency, ledger_balance, balance_date, start_date, end_date, transactions, imported, imported_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+    `, statement.ID, userID, statement.Source, statement.Account, statement.Currency, statement.LedgerBalance, statement.BalanceDate, statement.StartDate, statement.EndDate, statement.Transactions, statement.Imported, statement.ImportedAt)
+	if err != nil {
+		return Statement{}, fmt.Errorf("failed to insert statement: %v", err)
+	}
+	return statement, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
     "fmt"
+    "math"
     "os"
     "regexp"
     "strings"
@@ -32,6 +33,8 @@ type Storage interface {
     AddRecurringExpense(userID string, recurringExpense RecurringExpense, enc *encryption.Manager) error
 	RemoveRecurringExpense(userID, id string, removeAll bool) error
     UpdateRecurringExpense(userID, id string, recurringExpense RecurringExpense, updateAll bool, enc *encryption.Manager) error
+	AdoptRecurringExpense(userID string, recurringExpense RecurringExpense, linked []Expense, enc *encryption.Manager) error
+	GetRecurringExpenseIDs(userID, recurringID string) ([]string, error)
 
 	// Expenses
 	GetAllExpenses(userID string) ([]Expense, error)
@@ -42,6 +45,103 @@ type Storage interface {
 	RemoveMultipleExpenses(userID string, ids []string) error
 	UpdateExpense(userID, id string, expense Expense) error
 
+	// Contacts and IOUs
+	GetContacts(userID string) ([]Contact, error)
+	GetContact(userID, id string) (Contact, error)
+	AddContact(userID string, contact Contact) (Contact, error)
+	UpdateContact(userID, id string, contact Contact) error
+	RemoveContact(userID, id string) error
+	GetDebts(userID, contactID string) ([]Debt, error)
+	GetDebt(userID, id string) (Debt, error)
+	AddDebt(userID string, debt Debt) (Debt, error)
+	UpdateDebt(userID, id string, debt Debt) error
+	RemoveDebt(userID, id string) error
+	AddDebtRepayment(userID, debtID string, repayment DebtRepayment) (DebtRepayment, error)
+	RemoveDebtRepayment(userID, id string) error
+
+	// Attachments (metadata only, content lives in the blob store)
+	GetAttachments(userID, expenseID string) ([]Attachment, error)
+	GetAttachment(userID, id string) (Attachment, error)
+	AddAttachment(userID string, attachment Attachment) error
+	RemoveAttachment(userID, id string) error
+
+	// Refund and reimbursement links
+	GetRefundLinks(userID string) ([]RefundLink, error)
+	AddRefundLink(userID string, link RefundLink) (RefundLink, error)
+	RemoveRefundLink(userID, id string) error
+
+	// Statement reconciliation
+	GetReconciliations(userID string) ([]Reconciliation, error)
+	GetReconciliation(userID, id string) (Reconciliation, error)
+	LastReconciledBalance(userID, account string) (float64, error)
+	AddReconciliation(userID string, rec Reconciliation) (Reconciliation, error)
+	RemoveReconciliation(userID, id string) error
+	AddReconciliationItem(userID, reconciliationID, expenseID string) error
+	RemoveReconciliationItem(userID, reconciliationID, expenseID string) error
+	CompleteReconciliation(userID, id string, reconciled []Expense) error
+	GetLockedExpenseIDs(userID string, ids []string) ([]string, error)
+
+	// Period close
+	GetClosedPeriods(userID string) ([]ClosedPeriod, error)
+	ClosePeriod(userID string, date time.Time) (ClosedPeriod, error)
+	ReopenPeriod(userID, id string) error
+
+	// Anomaly flags
+	GetAnomalyFlags(userID string, includeDismissed bool) ([]AnomalyFlag, error)
+	AddAnomalyFlag(userID string, flag AnomalyFlag) (bool, error)
+	DismissAnomalyFlag(userID, id string) error
+
+	// Categorization rules
+	GetCategorizationRules(userID string) ([]CategorizationRule, error)
+	GetCategorizationRule(userID, id string) (CategorizationRule, error)
+	AddCategorizationRule(userID string, rule CategorizationRule) (CategorizationRule, error)
+	UpdateCategorizationRule(userID, id string, rule CategorizationRule) error
+	RemoveCategorizationRule(userID, id string) error
+	ReorderCategorizationRules(userID string, ids []string) error
+
+	// Payees
+	GetPayees(userID string) ([]Payee, error)
+	GetPayee(userID, id string) (Payee, error)
+	AddPayee(userID string, payee Payee) (Payee, error)
+	UpdatePayee(userID, id string, payee Payee, renamed []Expense) error
+	RemovePayee(userID, id string) error
+
+	// Duplicate detection
+	GetDuplicatePolicy(userID string) (DuplicatePolicy, error)
+	UpdateDuplicatePolicy(userID string, policy DuplicatePolicy) error
+
+	// Bank statements
+	GetExternalRefs(userID string) (map[string]string, error)
+	GetStatements(userID string) ([]Statement, error)
+	AddStatement(userID string, statement Statement) (Statement, error)
+
+	// CSV import profiles
+	GetImportProfiles(userID string) ([]ImportProfile, error)
+	GetImportProfile(userID, id string) (ImportProfile, error)
+	AddImportProfile(userID string, profile ImportProfile) (ImportProfile, error)
+	UpdateImportProfile(userID, id string, profile ImportProfile) error
+	RemoveImportProfile(userID, id string) error
+
+	// Import previews
+	AddImportPreview(userID string, preview ImportPreview) (ImportPreview, error)
+	GetImportPreview(userID, id string) (ImportPreview, error)
+	RemoveImportPreview(userID, id string) error
+	CommitImportPreview(userID, previewID string, batch ImportBatch, expenses []Expense) (ImportBatch, error)
+
+	// Import number and date conventions
+	GetImportLocale(userID string) (ImportLocale, error)
+	UpdateImportLocale(userID string, locale ImportLocale) error
+
+	// Import batches
+	AddImportBatch(userID string, batch ImportBatch, expenses []Expense) (ImportBatch, error)
+	GetImportBatches(userID string) ([]ImportBatch, error)
+	GetImportBatch(userID, id string) (ImportBatch, error)
+	RemoveImportBatch(userID, id string) error
+
+	// JSON backup and restore
+	GetBackup(userID string) (Backup, error)
+	RestoreBackup(userID string, backup Backup, merge bool, enc *encryption.Manager) (BackupRestore, error)
+
 	// Potential Future Feature: Multi-currency
 	// GetConversions(userID string) (map[string]float64, error)
 	// UpdateConversions(userID string, conversions map[string]float64) error
@@ -64,9 +164,12 @@ type RecurringExpense struct {
 	Currency    string    `json:"currency"`
 	Tags        []string  `json:"tags"`
 	Category    string    `json:"category"`
-	StartDate   time.Time `json:"startDate"`   // date of the first occurrence
-	Interval    string    `json:"interval"`    // daily, weekly, monthly, yearly
-	Occurrences int       `json:"occurrences"` // 0 for 3000 occurrences (heuristic)
+	StartDate   time.Time `json:"startDate"`           // date of the first occurrence
+	Interval    string    `json:"interval"`            // daily, weekly, monthly, yearly
+	Occurrences int       `json:"occurrences"`         // 0 for 3000 occurrences (heuristic)
+	Kind        string    `json:"kind,omitempty"`      // recurring (default) or installment
+	Principal   float64   `json:"principal,omitempty"` // installment purchase price
+	Fees        float64   `json:"fees,omitempty"`      // installment interest and fees, spread over the payments
 	Blob        string    `json:"blob,omitempty"`
 }
 
@@ -88,16 +191,36 @@ type SystemConfig struct {
 
 // expense struct
 type Expense struct {
-	ID          string    `json:"id"`
-	UserID      string    `json:"userId"`
-	RecurringID string    `json:"recurringID"`
-	Name        string    `json:"name"`
-	Tags        []string  `json:"tags"`
-	Category    string    `json:"category"`
-	Amount      float64   `json:"amount"`
-	Currency    string    `json:"currency"`
-	Date        time.Time `json:"date"`
-	Blob        string    `json:"blob,omitempty"`
+	ID            string          `json:"id"`
+	UserID        string          `json:"userId"`
+	RecurringID   string          `json:"recurringID"`
+	Name          string          `json:"name"`
+	Tags          []string        `json:"tags"`
+	Category      string          `json:"category"`
+	Amount        float64         `json:"amount"`
+	Currency      string          `json:"currency"`
+	Date          time.Time       `json:"date"`
+	Splits        []ExpenseSplit  `json:"splits,omitempty"`        // line items, must sum to Amount
+	Reimbursable  bool            `json:"reimbursable,omitempty"`  // expected to be paid back (e.g. by an employer)
+	Installment   *InstallmentRef `json:"installment,omitempty"`   // set on payments generated by an installment plan
+	TaxClass      string          `json:"taxClass,omitempty"`      // tax deduction class, empty when not tax relevant
+	Status        string          `json:"status,omitempty"`        // uncleared, cleared or reconciled
+	Account       string          `json:"account,omitempty"`       // bank or card account, used for reconciliation
+	Source        string          `json:"source,omitempty"`        // how the expense entered the system: manual, api or import
+	PayeeID       string          `json:"payeeId,omitempty"`       // normalized merchant, Name then holds the payee's name
+	DuplicateOf   string          `json:"duplicateOf,omitempty"`   // expense this one may duplicate, pending review
+	ExternalRef   string          `json:"externalRef,omitempty"`   // idempotency key of an imported bank transaction
+	Note          string          `json:"note,omitempty"`          // free text, e.g. the remittance information of a bank transfer
+	ImportBatchID string          `json:"importBatchId,omitempty"` // import that created the expense, used to undo it
+	Blob          string          `json:"blob,omitempty"`
+}
+
+// line item of an expense that covers several categories (e.g. one supermarket receipt)
+type ExpenseSplit struct {
+	Category string   `json:"category"`
+	Amount   float64  `json:"amount"`
+	Tags     []string `json:"tags"`
+	Note     string   `json:"note"`
 }
 
 func (c *Config) SetBaseConfig() {
@@ -179,6 +302,9 @@ func (e *Expense) Validate() error {
 	if e.Name == "" {
 		return fmt.Errorf("expense 'name' cannot be empty")
 	}
+	if err := e.validateSplits(); err != nil {
+		return err
+	}
 	if e.Category == "" {
 		return fmt.Errorf("expense 'category' cannot be empty")
 	}
@@ -189,14 +315,12 @@ func (e *Expense) Validate() error {
 	// 	return fmt.Errorf("expense 'currency' cannot be empty")
 	// }
 	if len(e.Tags) > 0 {
-		var cleanedTags []string
-		for _, tag := range e.Tags {
-			sanitizedTag := SanitizeString(tag)
-			if sanitizedTag != "" {
-				cleanedTags = append(cleanedTags, sanitizedTag)
-			}
-		}
-		e.Tags = cleanedTags
+		e.Tags = sanitizeTags(e.Tags)
+	}
+	e.Note = strings.TrimSpace(e.Note)
+	e.TaxClass = NormalizeTaxClass(e.TaxClass)
+	if err := e.validateStatus(); err != nil {
+		return err
 	}
 	if e.Date.IsZero() {
 		return fmt.Errorf("expense 'date' cannot be empty")
@@ -204,6 +328,63 @@ func (e *Expense) Validate() error {
 	return nil
 }
 
+// validateSplits checks that line items are complete and add up to the total.
+// A split expense without a category takes the category of its largest split.
+func (e *Expense) validateSplits() error {
+	if len(e.Splits) == 0 {
+		e.Splits = nil
+		return nil
+	}
+	if len(e.Splits) < 2 {
+		return fmt.Errorf("a split expense needs at least 2 splits")
+	}
+	total := 0.0
+	largest := 0
+	for i := range e.Splits {
+		split := &e.Splits[i]
+		split.Category = strings.TrimSpace(split.Category)
+		if split.Category == "" {
+			return fmt.Errorf("split %d: 'category' cannot be empty", i+1)
+		}
+		if split.Amount == 0 {
+			return fmt.Errorf("split %d: 'amount' cannot be 0", i+1)
+		}
+		split.Tags = sanitizeTags(split.Tags)
+		split.Note = strings.TrimSpace(split.Note)
+		total += split.Amount
+		if math.Abs(split.Amount) > math.Abs(e.Splits[largest].Amount) {
+			largest = i
+		}
+	}
+	if math.Abs(total-e.Amount) > 0.005 {
+		return fmt.Errorf("splits sum to %.2f but the expense amount is %.2f", total, e.Amount)
+	}
+	if e.Category == "" {
+		e.Category = e.Splits[largest].Category
+	}
+	return nil
+}
+
+// Allocations returns the (category, amount, tags) portions of an expense:
+// its splits when present, otherwise the expense itself as a single portion.
+func (e Expense) Allocations() []ExpenseSplit {
+	if len(e.Splits) > 0 {
+		return e.Splits
+	}
+	return []ExpenseSplit{{Category: e.Category, Amount: e.Amount, Tags: e.Tags}}
+}
+
+func sanitizeTags(tags []string) []string {
+	var cleanedTags []string
+	for _, tag := range tags {
+		sanitizedTag := SanitizeString(tag)
+		if sanitizedTag != "" {
+			cleanedTags = append(cleanedTags, sanitizedTag)
+		}
+	}
+	return cleanedTags
+}
+
 func (e *RecurringExpense) Validate() error {
 	e.Name = SanitizeString(e.Name)
 	if e.Name == "" {
@@ -213,14 +394,7 @@ func (e *RecurringExpense) Validate() error {
 		return fmt.Errorf("recurring expense 'category' cannot be empty")
 	}
 	if len(e.Tags) > 0 {
-		var cleanedTags []string
-		for _, tag := range e.Tags {
-			sanitizedTag := SanitizeString(tag)
-			if sanitizedTag != "" {
-				cleanedTags = append(cleanedTags, sanitizedTag)
-			}
-		}
-		e.Tags = cleanedTags
+		e.Tags = sanitizeTags(e.Tags)
 	}
     // Allow 0 (interpreted as open-ended/heuristic) or >= 2 occurrences.
     if e.Occurrences != 0 && e.Occurrences < 2 {
@@ -238,6 +412,14 @@ func (e *RecurringExpense) Validate() error {
 	if !validIntervals[e.Interval] {
 		return fmt.Errorf("invalid interval: '%s'. Must be one of 'daily', 'weekly', 'monthly', or 'yearly'", e.Interval)
 	}
+	switch e.Kind {
+	case "", RecurringKindRecurring:
+		e.Kind = RecurringKindRecurring
+	case RecurringKindInstallment:
+		return e.validateInstallment()
+	default:
+		return fmt.Errorf("invalid kind: '%s'. Must be 'recurring' or 'installment'", e.Kind)
+	}
 	return nil
 }
 
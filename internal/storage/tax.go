@@ -0,0 +1,22 @@
+package storage
+
+import "strings"
+
+// TaxClasses lists the suggested deduction classes. Other values are accepted
+// and normalised the same way, so users can track jurisdiction specific ones.
+var TaxClasses = []string{
+	"medical",
+	"charity",
+	"home_office",
+	"education",
+	"business",
+	"childcare",
+	"other",
+}
+
+// NormalizeTaxClass lowercases a deduction class and joins words with
+// underscores ("Home Office" becomes "home_office").
+func NormalizeTaxClass(class string) string {
+	class = strings.ToLower(SanitizeString(strings.NewReplacer("-", " ", "_", " ").Replace(class)))
+	return strings.ReplaceAll(class, " ", "_")
+}